@@ -0,0 +1,175 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"kalco/pkg/deprecations"
+	"kalco/pkg/dumper"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	analyzeDir           string
+	analyzeTarget        string
+	analyzeOutput        string
+	analyzeFailOnRemoved bool
+)
+
+var analyzeCmd = &cobra.Command{
+	Use:   "analyze",
+	Short: "Analyze exported cluster snapshots",
+	Long: formatLongDescription(`
+Analyze the objects of an export without connecting to the cluster again.
+`),
+}
+
+var analyzeDeprecationsCmd = &cobra.Command{
+	Use:   "deprecations",
+	Short: "Find objects using deprecated or removed Kubernetes API versions",
+	Long: formatLongDescription(`
+Check every exported object against a bundled table of deprecated and removed
+Kubernetes API versions. Both the object's own apiVersion and the apiVersion of its
+kubectl.kubernetes.io/last-applied-configuration annotation are checked: the server
+converts stored objects to a served version, but the next "kubectl apply" of the
+original manifest still uses the version it was written with.
+
+Findings are evaluated against --target-version, the release you plan to upgrade
+to. It defaults to the minor release after the cluster version recorded in the
+export's manifest, since the objects themselves always use versions the cluster
+still serves; APIs removed at the target version are reported first. Without a
+target or manifest, every known deprecation is reported.
+
+The export of the active context's output directory is analyzed unless --dir is
+given. Use --fail-on-removed in CI to exit non-zero when a removed API is in use.
+`),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runAnalyzeDeprecations()
+	},
+}
+
+func runAnalyzeDeprecations() error {
+	if analyzeOutput != "table" && analyzeOutput != "json" {
+		return fmt.Errorf("invalid output '%s' (expected table or json)", analyzeOutput)
+	}
+
+	dir := analyzeDir
+	if dir == "" {
+		activeContext, err := getActiveContext()
+		if err != nil {
+			return fmt.Errorf("no active context found (or pass --dir): %w", err)
+		}
+		if activeContext.OutputDir == "" {
+			return fmt.Errorf("context must have an output directory configured (or pass --dir)")
+		}
+		dir = activeContext.OutputDir
+	}
+
+	clusterVersion := ""
+	if manifest, err := dumper.ReadManifest(dir); err == nil {
+		clusterVersion = manifest.ClusterVersion
+	}
+	target, err := deprecationTarget(analyzeTarget, clusterVersion)
+	if err != nil {
+		return err
+	}
+
+	findings, err := deprecations.Scan(dir, target)
+	if err != nil {
+		return err
+	}
+	removed := deprecations.CountRemoved(findings)
+
+	if analyzeOutput == "json" {
+		if findings == nil {
+			findings = []deprecations.Finding{}
+		}
+		data, err := json.MarshalIndent(findings, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal findings: %w", err)
+		}
+		fmt.Println(string(data))
+	} else {
+		printDeprecations(target, findings)
+	}
+
+	if analyzeFailOnRemoved && removed > 0 {
+		return fmt.Errorf("%s", removedMessage(removed, target))
+	}
+	return nil
+}
+
+// deprecationTarget resolves the release deprecations are checked against: the given
+// version, else the release after the cluster's version, else none to report every
+// known deprecation. The cluster's own version is no target: it serves every apiVersion
+// its objects were read with.
+func deprecationTarget(value, clusterVersion string) (deprecations.Version, error) {
+	if value != "" {
+		return deprecations.ParseVersion(value)
+	}
+	if clusterVersion == "" {
+		return deprecations.Version{}, nil
+	}
+	target, err := deprecations.ParseVersion(clusterVersion)
+	if err != nil {
+		// An unusual cluster version must not hide the findings
+		return deprecations.Version{}, nil
+	}
+	return target.Next(), nil
+}
+
+// removedMessage describes how many objects use removed API versions
+func removedMessage(removed int, target deprecations.Version) string {
+	if target.IsZero() {
+		return fmt.Sprintf("%d objects use removed API versions", removed)
+	}
+	return fmt.Sprintf("%d objects use API versions removed in %s", removed, target)
+}
+
+// printDeprecations prints deprecation findings as a table
+func printDeprecations(target deprecations.Version, findings []deprecations.Finding) {
+	if target.IsZero() {
+		printSubHeader("API Deprecations (all known)")
+	} else {
+		printSubHeader(fmt.Sprintf("API Deprecations (target %s)", target))
+	}
+	if len(findings) == 0 {
+		printSuccess("No deprecated API versions are in use")
+		return
+	}
+
+	printTableHeader(fmt.Sprintf("%-45s", "API VERSION"), fmt.Sprintf("%-30s", "KIND"), fmt.Sprintf("%-40s", "OBJECT"), fmt.Sprintf("%-12s", "SOURCE"), "STATUS")
+	for _, finding := range findings {
+		printTableRow(fmt.Sprintf("%-45s", finding.APIVersion), fmt.Sprintf("%-30s", finding.Kind), fmt.Sprintf("%-40s", finding.Object()), fmt.Sprintf("%-12s", finding.Source), finding.Status())
+	}
+
+	// One migration hint per deprecated API version and Kind
+	seen := make(map[string]bool)
+	for _, finding := range findings {
+		key := finding.APIVersion + " " + finding.Kind
+		if seen[key] || finding.Replacement == "" {
+			continue
+		}
+		seen[key] = true
+		printInfo(fmt.Sprintf("%s %s: migrate to %s", finding.Kind, finding.APIVersion, finding.Replacement))
+	}
+
+	removed := deprecations.CountRemoved(findings)
+	if removed > 0 {
+		printError(removedMessage(removed, target))
+	}
+	if deprecated := len(findings) - removed; deprecated > 0 {
+		printWarning(fmt.Sprintf("%d objects use deprecated API versions", deprecated))
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(analyzeCmd)
+	analyzeCmd.AddCommand(analyzeDeprecationsCmd)
+
+	analyzeDeprecationsCmd.Flags().StringVar(&analyzeDir, "dir", "", "export directory to analyze (default from context)")
+	analyzeDeprecationsCmd.Flags().StringVar(&analyzeTarget, "target-version", "", "Kubernetes version to check against, e.g. v1.29 (default: the release after the exported cluster's version)")
+	analyzeDeprecationsCmd.Flags().StringVarP(&analyzeOutput, "output", "o", "table", "output format: table or json")
+	analyzeDeprecationsCmd.Flags().BoolVar(&analyzeFailOnRemoved, "fail-on-removed", false, "exit non-zero when an object uses an API version removed at the target version")
+}
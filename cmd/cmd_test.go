@@ -21,7 +21,7 @@ func TestRootCommand(t *testing.T) {
 	}
 
 	// Test that root command has the expected subcommands
-	expectedSubcommands := []string{"context", "export", "version"}
+	expectedSubcommands := []string{"analyze", "context", "export", "rbac", "version"}
 	actualSubcommands := make([]string, 0, len(rootCmd.Commands()))
 	for _, cmd := range rootCmd.Commands() {
 		actualSubcommands = append(actualSubcommands, cmd.Name())
@@ -94,6 +94,41 @@ func TestContextCommand(t *testing.T) {
 	}
 }
 
+func TestRBACCommand(t *testing.T) {
+	// Find rbac command
+	var rbacCmd *cobra.Command
+	for _, cmd := range rootCmd.Commands() {
+		if cmd.Name() == "rbac" {
+			rbacCmd = cmd
+			break
+		}
+	}
+
+	if rbacCmd == nil {
+		t.Fatal("RBAC command not found")
+	}
+
+	// Test that rbac command has the generate subcommand
+	var generateCmd *cobra.Command
+	for _, cmd := range rbacCmd.Commands() {
+		if cmd.Name() == "generate" {
+			generateCmd = cmd
+			break
+		}
+	}
+	if generateCmd == nil {
+		t.Fatal("Expected rbac subcommand 'generate' not found")
+	}
+
+	// Test that generate has the expected flags
+	expectedFlags := []string{"name", "namespace", "output", "secrets", "resources", "exclude-resources"}
+	for _, expected := range expectedFlags {
+		if generateCmd.Flags().Lookup(expected) == nil {
+			t.Errorf("Expected rbac generate command to have flag '%s'", expected)
+		}
+	}
+}
+
 func TestExportCommand(t *testing.T) {
 	// Find export command
 	var exportCmd *cobra.Command
@@ -197,3 +232,24 @@ func TestFlagConsistency(t *testing.T) {
 		t.Error("Root command missing no-color persistent flag")
 	}
 }
+
+func TestDeprecationTarget(t *testing.T) {
+	// Without --target-version the release after the cluster's is checked
+	target, err := deprecationTarget("", "v1.28.4")
+	if err != nil || target.String() != "v1.29" {
+		t.Errorf("expected target v1.29, got %s (%v)", target, err)
+	}
+
+	target, err = deprecationTarget("v1.32", "v1.28.4")
+	if err != nil || target.String() != "v1.32" {
+		t.Errorf("expected target v1.32, got %s (%v)", target, err)
+	}
+
+	target, err = deprecationTarget("", "")
+	if err != nil || !target.IsZero() {
+		t.Errorf("expected no target without a cluster version, got %s (%v)", target, err)
+	}
+	if message := removedMessage(2, target); message != "2 objects use removed API versions" {
+		t.Errorf("unexpected message without a target: %q", message)
+	}
+}
@@ -5,9 +5,13 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
 	"kalco/pkg/context"
+	"kalco/pkg/dumper"
+	"kalco/pkg/git"
 
 	"github.com/spf13/cobra"
 )
@@ -37,8 +41,34 @@ Required fields:
 
 Optional fields:
 - --kubeconfig: Path to kubeconfig file
+- --kube-context: Context to use from the kubeconfig (default: its current-context)
+- --cluster: Kubeconfig cluster to use instead of the context's cluster
+- --user: Kubeconfig user to use instead of the context's user
+- --namespace: Namespace to export unless --namespaces is given to export
+- --as, --as-group: User and groups to impersonate
+- --qps, --burst: Client-side rate limit for API requests
+- --request-timeout: Timeout of a single API request (e.g. 60s)
+- --retries: Retries for transient API errors
+- --timeout: Deadline for a whole export (e.g. 30m)
 - --description: Description of the context
 - --labels: Labels in format key=value (can be specified multiple times)
+- --secrets: Default secret mode for exports (include, redact, omit, encrypt)
+- --age-recipient: age recipient used to encrypt Secrets (can be specified multiple times)
+- --cleanup-profile: Cleanup profile for exports (minimal, reapply, full or a profile file)
+- --layout: File layout for exports (tree, namespace, kind, single, flat)
+- --format: File format for exports (yaml, json)
+- --api-version: Pin the exported version of an API group, as group=version (can be specified multiple times)
+- --additional-version: Also export objects in this API version, as group/version (can be specified multiple times)
+- --git-backend: Git implementation for the output directory (go-git, exec)
+- --git-remote, --git-remote-url: Git remote to push to (default: origin) and its URL
+- --git-repository: Repository shared with other contexts; the output directory becomes a
+  work tree of it, committing to the context's own branch
+- --git-branch: Branch to push to, and to commit to in a shared repository (default: the
+  current branch, clusters/<name> in a shared repository)
+- --git-ssh-key: SSH private key used to push to SSH remotes
+- --git-token-env, --git-username: Environment variable holding a token (and the username
+  sent with it) used to push to HTTPS remotes
+- --git-rebase: Rebase onto the remote branch and retry when a push is rejected
 
 The context will be saved and can be used for future operations.`,
 		Args: cobra.ExactArgs(1),
@@ -98,6 +128,39 @@ This is useful for importing contexts from existing kalco exports or for team co
 	contextOutputDir   string
 	contextDescription string
 	contextLabels      []string
+	contextSecretMode  string
+	contextRecipients  []string
+	contextCleanup     string
+	contextLayout      string
+	contextFormat      string
+	contextAPIVersions []string
+	contextAddVersions []string
+
+	// Git settings for context set
+	contextGitBackend    string
+	contextGitRepository string
+	contextGitRemote     string
+	contextGitRemoteURL  string
+	contextGitBranch     string
+	contextGitSSHKey     string
+	contextGitTokenEnv   string
+	contextGitUsername   string
+	contextGitRebase     bool
+
+	// Kube settings for context set
+	contextKubeContext string
+	contextCluster     string
+	contextUser        string
+	contextNamespace   string
+	contextAs          string
+	contextAsGroups    []string
+
+	// Client tuning for context set
+	contextQPS            float32
+	contextBurst          int
+	contextRequestTimeout string
+	contextRetries        int
+	contextTimeout        string
 )
 
 func init() {
@@ -117,6 +180,33 @@ func init() {
 	contextSetCmd.Flags().StringVar(&contextOutputDir, "output", "", "Output directory for exports (required)")
 	contextSetCmd.Flags().StringVar(&contextDescription, "description", "", "Description of the context")
 	contextSetCmd.Flags().StringArrayVar(&contextLabels, "labels", []string{}, "Labels in format key=value (can be specified multiple times)")
+	contextSetCmd.Flags().StringVar(&contextSecretMode, "secrets", "", "Default secret mode for exports (include, redact, omit, encrypt)")
+	contextSetCmd.Flags().StringArrayVar(&contextRecipients, "age-recipient", []string{}, "age recipient for Secret encryption (can be specified multiple times)")
+	contextSetCmd.Flags().StringVar(&contextCleanup, "cleanup-profile", "", "Cleanup profile for exports (minimal, reapply, full or path to a profile file)")
+	contextSetCmd.Flags().StringVar(&contextLayout, "layout", "", "File layout for exports (tree, namespace, kind, single, flat)")
+	contextSetCmd.Flags().StringVar(&contextFormat, "format", "", "File format for exports (yaml, json)")
+	contextSetCmd.Flags().StringArrayVar(&contextAPIVersions, "api-version", []string{}, "Pin the exported version of an API group, as group=version (can be specified multiple times)")
+	contextSetCmd.Flags().StringArrayVar(&contextAddVersions, "additional-version", []string{}, "Also export objects in this API version, as group/version (can be specified multiple times)")
+	contextSetCmd.Flags().StringVar(&contextGitBackend, "git-backend", "", "Git implementation for the output directory (go-git, exec)")
+	contextSetCmd.Flags().StringVar(&contextGitRepository, "git-repository", "", "Repository shared with other contexts; the output directory becomes a work tree of it on the context's branch")
+	contextSetCmd.Flags().StringVar(&contextGitRemote, "git-remote", "", "Git remote to push to (default: origin)")
+	contextSetCmd.Flags().StringVar(&contextGitRemoteURL, "git-remote-url", "", "URL of the Git remote, added or updated before pushing")
+	contextSetCmd.Flags().StringVar(&contextGitBranch, "git-branch", "", "Branch to push to, and to commit to in a shared repository (default: the current branch, clusters/<name> when shared)")
+	contextSetCmd.Flags().StringVar(&contextGitSSHKey, "git-ssh-key", "", "SSH private key used to push to SSH remotes")
+	contextSetCmd.Flags().StringVar(&contextGitTokenEnv, "git-token-env", "", "Environment variable holding a token used to push to HTTPS remotes")
+	contextSetCmd.Flags().StringVar(&contextGitUsername, "git-username", "", "Username sent with the token (default: kalco)")
+	contextSetCmd.Flags().BoolVar(&contextGitRebase, "git-rebase", false, "Rebase onto the remote branch and retry when a push is rejected")
+	contextSetCmd.Flags().StringVar(&contextKubeContext, "kube-context", "", "Context to use from the kubeconfig (default: its current-context)")
+	contextSetCmd.Flags().StringVar(&contextCluster, "cluster", "", "Kubeconfig cluster to use instead of the context's cluster")
+	contextSetCmd.Flags().StringVar(&contextUser, "user", "", "Kubeconfig user to use instead of the context's user")
+	contextSetCmd.Flags().StringVar(&contextNamespace, "namespace", "", "Namespace to export by default")
+	contextSetCmd.Flags().StringVar(&contextAs, "as", "", "User to impersonate")
+	contextSetCmd.Flags().StringArrayVar(&contextAsGroups, "as-group", []string{}, "Group to impersonate (can be specified multiple times)")
+	contextSetCmd.Flags().Float32Var(&contextQPS, "qps", 0, "Maximum requests per second to the API server")
+	contextSetCmd.Flags().IntVar(&contextBurst, "burst", 0, "Maximum burst of requests to the API server")
+	contextSetCmd.Flags().StringVar(&contextRequestTimeout, "request-timeout", "", "Timeout of a single API request (e.g. 60s)")
+	contextSetCmd.Flags().IntVar(&contextRetries, "retries", 0, "Retries for transient API errors")
+	contextSetCmd.Flags().StringVar(&contextTimeout, "timeout", "", "Deadline for a whole export (e.g. 30m)")
 }
 
 func runContextSet(cmd *cobra.Command, args []string) error {
@@ -152,15 +242,165 @@ func runContextSet(cmd *cobra.Command, args []string) error {
 		labels[parts[0]] = parts[1]
 	}
 
+	// Validate export settings
+	if cmd.Flags().Changed("secrets") {
+		if _, err := dumper.ParseSecretMode(contextSecretMode); err != nil {
+			return err
+		}
+	}
+	if cmd.Flags().Changed("cleanup-profile") {
+		if _, err := dumper.LoadCleanupProfile(contextCleanup); err != nil {
+			return err
+		}
+	}
+	if cmd.Flags().Changed("layout") {
+		if _, err := dumper.ParseLayout(contextLayout); err != nil {
+			return err
+		}
+	}
+	if cmd.Flags().Changed("format") {
+		if _, err := dumper.ParseFormat(contextFormat); err != nil {
+			return err
+		}
+	}
+	apiVersions, err := dumper.ParseVersionPins(contextAPIVersions)
+	if err != nil {
+		return err
+	}
+	if _, err := dumper.ParseGroupVersions(contextAddVersions); err != nil {
+		return err
+	}
+	if cmd.Flags().Changed("git-backend") {
+		if _, err := git.NewBackend(contextGitBackend, ""); err != nil {
+			return err
+		}
+	}
+	for flag, value := range map[string]string{"request-timeout": contextRequestTimeout, "timeout": contextTimeout} {
+		if cmd.Flags().Changed(flag) && value != "" {
+			if _, err := time.ParseDuration(value); err != nil {
+				return fmt.Errorf("invalid --%s: %w", flag, err)
+			}
+		}
+	}
+
+	// A context sharing a repository gets its work tree before the output directory is
+	// initialized, so the output directory does not become a standalone repository
+	pending := &context.Context{Name: name, OutputDir: contextOutputDir}
+	if existing, err := cm.GetContext(name); err == nil {
+		pending.Git = existing.Git
+	}
+	applyGitFlags(cmd, &pending.Git)
+	if pending.Git.Repository != "" {
+		repo, err := contextGitRepo(pending, "")
+		if err != nil {
+			return err
+		}
+		if err := repo.Init(); err != nil {
+			return fmt.Errorf("failed to set up the shared repository: %w", err)
+		}
+	}
+
 	// Set context
 	if err := cm.SetContext(name, contextKubeConfig, contextOutputDir, contextDescription, labels); err != nil {
 		return fmt.Errorf("failed to set context: %w", err)
 	}
 
+	// Apply kube and export settings that were explicitly provided
+	if err := cm.UpdateContext(name, func(ctx *context.Context) {
+		if cmd.Flags().Changed("kube-context") {
+			ctx.Kube.Context = contextKubeContext
+		}
+		if cmd.Flags().Changed("cluster") {
+			ctx.Kube.Cluster = contextCluster
+		}
+		if cmd.Flags().Changed("user") {
+			ctx.Kube.User = contextUser
+		}
+		if cmd.Flags().Changed("namespace") {
+			ctx.Kube.Namespace = contextNamespace
+		}
+		if cmd.Flags().Changed("as") {
+			ctx.Kube.Impersonate = contextAs
+		}
+		if cmd.Flags().Changed("as-group") {
+			ctx.Kube.ImpersonateGroups = contextAsGroups
+		}
+		if cmd.Flags().Changed("qps") {
+			ctx.Kube.QPS = contextQPS
+		}
+		if cmd.Flags().Changed("burst") {
+			ctx.Kube.Burst = contextBurst
+		}
+		if cmd.Flags().Changed("request-timeout") {
+			ctx.Kube.RequestTimeout = contextRequestTimeout
+		}
+		if cmd.Flags().Changed("retries") {
+			ctx.Kube.Retries = contextRetries
+		}
+		if cmd.Flags().Changed("timeout") {
+			ctx.Export.Timeout = contextTimeout
+		}
+		if cmd.Flags().Changed("secrets") {
+			ctx.Export.SecretMode = contextSecretMode
+		}
+		if cmd.Flags().Changed("age-recipient") {
+			ctx.Export.SecretRecipients = contextRecipients
+		}
+		if cmd.Flags().Changed("cleanup-profile") {
+			ctx.Export.CleanupProfile = contextCleanup
+		}
+		if cmd.Flags().Changed("layout") {
+			ctx.Export.Layout = contextLayout
+		}
+		if cmd.Flags().Changed("format") {
+			ctx.Export.Format = contextFormat
+		}
+		if cmd.Flags().Changed("api-version") {
+			ctx.Export.APIVersions = contextVersionPins(apiVersions)
+		}
+		if cmd.Flags().Changed("additional-version") {
+			ctx.Export.AdditionalVersions = contextAddVersions
+		}
+		applyGitFlags(cmd, &ctx.Git)
+	}); err != nil {
+		return fmt.Errorf("failed to set context: %w", err)
+	}
+
 	fmt.Printf("Context '%s' set successfully\n", name)
 	return nil
 }
 
+// applyGitFlags copies the Git settings given on the command line to settings
+func applyGitFlags(cmd *cobra.Command, settings *context.GitSettings) {
+	if cmd.Flags().Changed("git-backend") {
+		settings.Backend = contextGitBackend
+	}
+	if cmd.Flags().Changed("git-repository") {
+		settings.Repository = contextGitRepository
+	}
+	if cmd.Flags().Changed("git-remote") {
+		settings.Remote = contextGitRemote
+	}
+	if cmd.Flags().Changed("git-remote-url") {
+		settings.RemoteURL = contextGitRemoteURL
+	}
+	if cmd.Flags().Changed("git-branch") {
+		settings.Branch = contextGitBranch
+	}
+	if cmd.Flags().Changed("git-ssh-key") {
+		settings.SSHKey = contextGitSSHKey
+	}
+	if cmd.Flags().Changed("git-token-env") {
+		settings.TokenEnv = contextGitTokenEnv
+	}
+	if cmd.Flags().Changed("git-username") {
+		settings.Username = contextGitUsername
+	}
+	if cmd.Flags().Changed("git-rebase") {
+		settings.Rebase = contextGitRebase
+	}
+}
+
 func runContextList(cmd *cobra.Command, args []string) error {
 	// Get config directory
 	configDir, err := getConfigDir()
@@ -300,6 +540,8 @@ func runContextShow(cmd *cobra.Command, args []string) error {
 	fmt.Printf("Description: %s\n", ctx.Description)
 	fmt.Printf("Kubeconfig: %s\n", ctx.KubeConfig)
 	fmt.Printf("Output Directory: %s\n", ctx.OutputDir)
+	printKubeSettings(ctx)
+	printExportSettings(ctx)
 
 	if len(ctx.Labels) > 0 {
 		fmt.Println("Labels:")
@@ -338,6 +580,8 @@ func runContextCurrent(cmd *cobra.Command, args []string) error {
 	fmt.Printf("Description: %s\n", current.Description)
 	fmt.Printf("Kubeconfig: %s\n", current.KubeConfig)
 	fmt.Printf("Output Directory: %s\n", current.OutputDir)
+	printKubeSettings(current)
+	printExportSettings(current)
 
 	if len(current.Labels) > 0 {
 		fmt.Println("Labels:")
@@ -432,3 +676,111 @@ func runContextLoad(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+// printKubeSettings displays the kubeconfig selection stored in a context
+func printKubeSettings(ctx *context.Context) {
+	if ctx.Kube.Context != "" {
+		fmt.Printf("Kube Context: %s\n", ctx.Kube.Context)
+	}
+	if ctx.Kube.Cluster != "" {
+		fmt.Printf("Cluster: %s\n", ctx.Kube.Cluster)
+	}
+	if ctx.Kube.User != "" {
+		fmt.Printf("User: %s\n", ctx.Kube.User)
+	}
+	if ctx.Kube.Namespace != "" {
+		fmt.Printf("Namespace: %s\n", ctx.Kube.Namespace)
+	}
+	if ctx.Kube.Impersonate != "" {
+		fmt.Printf("Impersonate: %s\n", ctx.Kube.Impersonate)
+	}
+	if len(ctx.Kube.ImpersonateGroups) > 0 {
+		fmt.Printf("Impersonate Groups: %s\n", strings.Join(ctx.Kube.ImpersonateGroups, ", "))
+	}
+	if ctx.Kube.QPS > 0 || ctx.Kube.Burst > 0 {
+		fmt.Printf("Rate Limit: %g QPS, burst %d\n", ctx.Kube.QPS, ctx.Kube.Burst)
+	}
+	if ctx.Kube.RequestTimeout != "" {
+		fmt.Printf("Request Timeout: %s\n", ctx.Kube.RequestTimeout)
+	}
+	if ctx.Kube.Retries > 0 {
+		fmt.Printf("Retries: %d\n", ctx.Kube.Retries)
+	}
+}
+
+// printExportSettings displays the export defaults stored in a context
+func printExportSettings(ctx *context.Context) {
+	if ctx.Export.CleanupProfile != "" {
+		fmt.Printf("Cleanup Profile: %s\n", ctx.Export.CleanupProfile)
+	}
+	if ctx.Export.SecretMode != "" {
+		fmt.Printf("Secret Mode: %s\n", ctx.Export.SecretMode)
+	}
+	if len(ctx.Export.SecretRecipients) > 0 {
+		fmt.Printf("Secret Recipients: %s\n", strings.Join(ctx.Export.SecretRecipients, ", "))
+	}
+	if ctx.Export.Timeout != "" {
+		fmt.Printf("Export Timeout: %s\n", ctx.Export.Timeout)
+	}
+	if ctx.Export.Layout != "" {
+		fmt.Printf("Layout: %s\n", ctx.Export.Layout)
+	}
+	if ctx.Export.Format != "" {
+		fmt.Printf("Format: %s\n", ctx.Export.Format)
+	}
+	if len(ctx.Export.APIVersions) > 0 {
+		pins := make([]string, 0, len(ctx.Export.APIVersions))
+		for group, version := range ctx.Export.APIVersions {
+			pins = append(pins, group+"="+version)
+		}
+		sort.Strings(pins)
+		fmt.Printf("API Versions: %s\n", strings.Join(pins, ", "))
+	}
+	if len(ctx.Export.AdditionalVersions) > 0 {
+		fmt.Printf("Additional Versions: %s\n", strings.Join(ctx.Export.AdditionalVersions, ", "))
+	}
+	if ctx.Git.Backend != "" {
+		fmt.Printf("Git Backend: %s\n", ctx.Git.Backend)
+	}
+	if ctx.Git.Repository != "" {
+		branch := ctx.Git.Branch
+		if branch == "" {
+			branch = git.ClusterBranchPrefix + ctx.Name
+		}
+		fmt.Printf("Git Repository: %s (branch %s)\n", ctx.Git.Repository, branch)
+	}
+	if ctx.Git.Remote != "" || ctx.Git.RemoteURL != "" {
+		remote := ctx.Git.Remote
+		if remote == "" {
+			remote = git.DefaultRemote
+		}
+		if ctx.Git.RemoteURL != "" {
+			remote += " (" + ctx.Git.RemoteURL + ")"
+		}
+		fmt.Printf("Git Remote: %s\n", remote)
+	}
+	if ctx.Git.Branch != "" {
+		fmt.Printf("Git Branch: %s\n", ctx.Git.Branch)
+	}
+	if ctx.Git.SSHKey != "" {
+		fmt.Printf("Git SSH Key: %s\n", ctx.Git.SSHKey)
+	}
+	if ctx.Git.TokenEnv != "" {
+		fmt.Printf("Git Token: $%s\n", ctx.Git.TokenEnv)
+	}
+	if ctx.Git.Rebase {
+		fmt.Println("Git Rebase: enabled")
+	}
+}
+
+// contextVersionPins stores parsed version pins with the core group spelled out
+func contextVersionPins(pins map[string]string) map[string]string {
+	stored := make(map[string]string, len(pins))
+	for group, version := range pins {
+		if group == "" {
+			group = "core"
+		}
+		stored[group] = version
+	}
+	return stored
+}
@@ -1,11 +1,17 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
 	"time"
 
+	"kalco/pkg/deprecations"
 	"kalco/pkg/dumper"
-	"kalco/pkg/git"
 	"kalco/pkg/kube"
 	"kalco/pkg/reports"
 
@@ -16,6 +22,38 @@ var (
 	exportGitPush       bool
 	exportCommitMessage string
 	exportDryRun        bool
+	exportConcurrency   int
+	exportPageSize      int64
+	exportSecretMode    string
+	exportCleanup       string
+	exportStatusMode    string
+	exportLayout        string
+	exportFormat        string
+	exportAPIVersions   []string
+	exportAddVersions   []string
+	exportCommitStatus  bool
+	exportNoPrune       bool
+	exportAllowPartial  bool
+	exportFailOnError   bool
+	exportDeprecations  bool
+	exportTargetVersion string
+	exportGitBackend    string
+
+	// Client tuning flags
+	exportQPS            float32
+	exportBurst          int
+	exportRequestTimeout time.Duration
+	exportRetries        int
+	exportTimeout        time.Duration
+	exportAs             string
+	exportAsGroups       []string
+
+	// Filter flags
+	exportNamespaces        []string
+	exportExcludeNamespaces []string
+	exportResources         []string
+	exportExcludeResources  []string
+	exportSkipResources     []string
 )
 
 var exportCmd = &cobra.Command{
@@ -30,33 +68,167 @@ The export creates an intuitive directory structure:
   • Namespaced resources: <output>/<namespace>/<kind>/<name>.yaml
   • Cluster resources: <output>/_cluster/<kind>/<name>.yaml
 
-Includes automatic Git integration for version control and change tracking.
+Object names are escaped for the filesystem (e.g. system:controller:job becomes
+system%3Acontroller%3Ajob.yaml), and Kinds served by several API groups get
+group-qualified directories (e.g. Event.events.k8s.io next to the core Event).
+
+Use --layout (or the context's layout) to organize files differently:
+  • tree (default): the directory structure above
+  • namespace: one multi-document <namespace>.yaml per namespace (_cluster.yaml)
+  • kind: one multi-document <kind>.yaml per Kind
+  • single: every object in a single multi-document all.yaml
+  • flat: one <namespace>_<kind>_<name>.yaml per object in the output directory
+Objects in multi-document files are sorted by Kind, namespace and name, and
+reports describe changes per object whatever the layout.
+
+Files are written as YAML by default, or as JSON with --format json (multi-object
+files then hold a List). Both use a canonical serializer: keys are sorted,
+resource quantities of core Kinds are normalized (e.g. 1000m becomes 1) and
+multi-line strings are always written the same way, so unchanged objects produce
+byte-identical files.
+
+Each export writes kalco-manifest.json, an index of every exported object with
+its apiVersion, kind, namespace, name, uid, resourceVersion, file and content
+SHA-256, plus the cluster version and export time. The CRDs of exported custom
+resources are written to _crds/<name>/ with the OpenAPI v3 schema of each served
+version, and the manifest links every custom resource to its CRD revision.
+
+Objects are exported in the server's preferred version of their API group. Use
+--api-version group=version to pin a group so an upgrade changing the preferred
+version does not rewrite its objects, and --additional-version group/version to
+also write objects in another version to <name>@<version> files (tree and flat
+layouts only). Reports flag objects whose apiVersion changed between exports.
+
+Secrets are written verbatim by default. Use --secrets to redact them (values
+replaced by a stable hash), omit them, or encrypt them for the age recipients
+configured on the context. Hashes are keyed with a per-context secret kept in
+~/.kalco/secret-keys, or KALCO_SECRET_HASH_KEY.
+
+Fields stripped from each object are controlled by a cleanup profile: the
+built-in "minimal", "reapply" (default) and "full" profiles, or a YAML file
+listing fields to remove per API group and Kind.
+
+Object status is dropped by default. Use --status inline to keep it in each
+object, or --status separate to write it to a sibling <name>.status.yaml file;
+add --commit-status=false to keep those status files out of the Git commit.
+
+Resources are exported into a staging directory next to the output directory
+and only swapped into the repository once the export completed. If listing any
+resource kind failed, nothing is changed and no commit is made; use
+--allow-partial to commit anyway (files of failed kinds are kept as they were,
+and the commit message and report are marked as partial).
+
+A summary table of exported, forbidden and failed resources is printed after
+each export and saved as JSON next to the report in kalco-reports. Use
+--fail-on-error to exit non-zero when any resource could not be fully exported.
+
+Files of resources that no longer exist in the cluster are pruned after each
+export so deletions are recorded in Git; use --no-prune to keep them.
+
+Use --namespaces/--exclude-namespaces and --resources/--exclude-resources to
+narrow the export. Resource patterns match the Kind, plural name or API group
+and support globs (e.g. --exclude-resources endpointslices).
+
+Resources that cannot be listed (e.g. TokenReview, Binding) are skipped, as are
+ephemeral resources such as Events and Leases. Override the ephemeral list with
+--skip-resources (e.g. --skip-resources events,leases,endpointslices), pass
+--skip-resources "" to export them all, or select one with --resources.
+
+API requests are rate limited (--qps, --burst), time out after --request-timeout
+and 429 and 5xx responses are retried with exponential backoff (--retries),
+unless client-go already retries them after a Retry-After. --timeout sets a
+deadline for the whole export; an export that times out or is interrupted with
+Ctrl-C leaves the output directory untouched.
+
+Use --check-deprecations to add the deprecated and removed API versions in use,
+checked against the release after the cluster's version or --target-version, to
+the report (see "kalco analyze deprecations").
+
+Use --as/--as-group to run the export with another identity, e.g. to verify a
+role generated with "kalco rbac generate" before deploying it.
+
+Includes automatic Git integration for version control and change tracking. Git
+is driven natively by go-git, so no git binary is needed; use --git-backend exec
+(or the context's Git backend) to run the git binary instead, e.g. to honour hooks,
+credential helpers or commit signing configured in Git.
+
+With --git-push, HEAD is pushed to the context's Git remote and branch (default:
+origin and the current branch), which becomes the upstream on the first push. A
+push rejected because the remote has newer commits is reported with the command
+to integrate them, or rebased and retried when the context enables --git-rebase.
+
+Contexts sharing a repository (kalco context set --git-repository) export into
+a work tree of it on their own branch, clusters/<context> by default, so one
+repository and remote hold every cluster and "git diff clusters/staging
+clusters/prod" compares them.
 `),
 
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return runExport()
+		return runExport(cmd)
 	},
 }
 
-func runExport() error {
+func runExport(cmd *cobra.Command) error {
 	// Require active context
 	requireActiveContext()
 
 	// Create Kubernetes clients
 	printInfo("Connecting to Kubernetes cluster...")
 
-	// Use context kubeconfig (always required)
+	// Use the cluster selected by the context (a kubeconfig is always required)
 	activeContext, err := getActiveContext()
 	if err != nil {
 		return fmt.Errorf("failed to get active context: %w", err)
 	}
 
-	kubeconfigPath := activeContext.KubeConfig
-	if kubeconfigPath == "" {
-		return fmt.Errorf("context must have a kubeconfig configured")
+	opts, err := clientOptions(activeContext)
+	if err != nil {
+		return err
+	}
+	if opts.KubeConfig == "" {
+		return fmt.Errorf("context must have a kubeconfig configured (or pass --kubeconfig)")
+	}
+
+	// Client tuning flags override the context settings
+	if cmd.Flags().Changed("qps") {
+		opts.QPS = exportQPS
+	}
+	if cmd.Flags().Changed("burst") {
+		opts.Burst = exportBurst
+	}
+	if cmd.Flags().Changed("request-timeout") {
+		opts.RequestTimeout = exportRequestTimeout
+	}
+	if cmd.Flags().Changed("retries") {
+		opts.MaxRetries = exportRetries
+	}
+	if cmd.Flags().Changed("as") {
+		opts.Impersonate = exportAs
+	}
+	if cmd.Flags().Changed("as-group") {
+		opts.ImpersonateGroups = exportAsGroups
+	}
+	if opts.Impersonate != "" {
+		printInfo(fmt.Sprintf("Impersonating: %s", opts.Impersonate))
 	}
 
-	clientset, discoveryClient, dynamicClient, err := kube.NewClients(kubeconfigPath)
+	// Resolve the overall export deadline: flag first, then context default
+	timeout := exportTimeout
+	if !cmd.Flags().Changed("timeout") && activeContext.Export.Timeout != "" {
+		if timeout, err = time.ParseDuration(activeContext.Export.Timeout); err != nil {
+			return fmt.Errorf("invalid export timeout '%s' in context: %w", activeContext.Export.Timeout, err)
+		}
+	}
+
+	// Validate the deprecation target before anything is exported or committed
+	var targetVersion deprecations.Version
+	if exportTargetVersion != "" {
+		if targetVersion, err = deprecations.ParseVersion(exportTargetVersion); err != nil {
+			return fmt.Errorf("invalid --target-version: %w", err)
+		}
+	}
+
+	clientset, discoveryClient, dynamicClient, err := kube.NewClientsWithOptions(opts)
 	if err != nil {
 		return fmt.Errorf("failed to create Kubernetes clients: %w", err)
 	}
@@ -72,6 +244,121 @@ func runExport() error {
 	// Create dumper instance
 	d := dumper.NewDumper(clientset, discoveryClient)
 	d.SetDynamicClient(dynamicClient)
+	// Resolve the secret mode: flag first, then context default
+	secretMode := exportSecretMode
+	if secretMode == "" {
+		secretMode = activeContext.Export.SecretMode
+	}
+	mode, err := dumper.ParseSecretMode(secretMode)
+	if err != nil {
+		return err
+	}
+	if err := d.SetSecretMode(mode, activeContext.Export.SecretRecipients); err != nil {
+		return fmt.Errorf("failed to configure secret handling: %w", err)
+	}
+	if mode == dumper.SecretsRedact || mode == dumper.SecretsEncrypt {
+		key, err := secretHashKey(activeContext)
+		switch {
+		case err == nil:
+			d.SetSecretHashKey(key)
+		case mode == dumper.SecretsRedact:
+			return fmt.Errorf("failed to load secret hash key: %w", err)
+		default:
+			printWarning(fmt.Sprintf("No secret hash key (%v); encrypted Secrets are exported without checksums", err))
+		}
+	}
+	if mode != dumper.SecretsInclude {
+		printInfo(fmt.Sprintf("Secret mode: %s", mode))
+	}
+
+	// Resolve the cleanup profile: flag first, then context default
+	cleanupProfile := exportCleanup
+	if cleanupProfile == "" {
+		cleanupProfile = activeContext.Export.CleanupProfile
+	}
+	profile, err := dumper.LoadCleanupProfile(cleanupProfile)
+	if err != nil {
+		return err
+	}
+	d.SetCleanupProfile(profile)
+
+	// Resolve status capture
+	statusMode, err := dumper.ParseStatusMode(exportStatusMode)
+	if err != nil {
+		return err
+	}
+	if !exportCommitStatus && statusMode != dumper.StatusSeparate {
+		return fmt.Errorf("--commit-status=false requires --status separate")
+	}
+	d.SetStatusMode(statusMode)
+
+	// Resolve the file layout: flag first, then context default
+	layoutName := exportLayout
+	if layoutName == "" {
+		layoutName = activeContext.Export.Layout
+	}
+	layout, err := dumper.ParseLayout(layoutName)
+	if err != nil {
+		return err
+	}
+	d.SetLayout(layout)
+	if layout.Name() != dumper.LayoutTree {
+		printInfo(fmt.Sprintf("Layout: %s", layout.Name()))
+	}
+
+	// Resolve the file format: flag first, then context default
+	formatName := exportFormat
+	if formatName == "" {
+		formatName = activeContext.Export.Format
+	}
+	format, err := dumper.ParseFormat(formatName)
+	if err != nil {
+		return err
+	}
+	d.SetFormat(format)
+	if format != dumper.FormatYAML {
+		printInfo(fmt.Sprintf("Format: %s", format))
+	}
+
+	// Resolve API versions: pins from the flags override the context's per group,
+	// additional versions from the flag replace the context's
+	pins := make([]string, 0, len(activeContext.Export.APIVersions)+len(exportAPIVersions))
+	for group, version := range activeContext.Export.APIVersions {
+		pins = append(pins, group+"="+version)
+	}
+	sort.Strings(pins)
+	versionPins, err := dumper.ParseVersionPins(append(pins, exportAPIVersions...))
+	if err != nil {
+		return err
+	}
+	d.SetVersionPins(versionPins)
+	addVersions := activeContext.Export.AdditionalVersions
+	if cmd.Flags().Changed("additional-version") {
+		addVersions = exportAddVersions
+	}
+	additionalVersions, err := dumper.ParseGroupVersions(addVersions)
+	if err != nil {
+		return err
+	}
+	if len(additionalVersions) > 0 && layout.Aggregated() {
+		return fmt.Errorf("--additional-version requires the %s or %s layout", dumper.LayoutTree, dumper.LayoutFlat)
+	}
+	d.SetAdditionalVersions(additionalVersions)
+
+	d.SetConcurrency(exportConcurrency)
+	d.SetPageSize(exportPageSize)
+	d.SetSkipResources(exportSkipResources)
+	// The context namespace narrows the export unless namespaces are given explicitly
+	namespaces := exportNamespaces
+	if len(namespaces) == 0 && activeContext.Kube.Namespace != "" {
+		namespaces = []string{activeContext.Kube.Namespace}
+	}
+	d.SetFilter(dumper.ResourceFilter{
+		Namespaces:        namespaces,
+		ExcludeNamespaces: exportExcludeNamespaces,
+		Resources:         exportResources,
+		ExcludeResources:  exportExcludeResources,
+	})
 
 	// Set output callback for resource export
 	d.SetOutputCallback(func(level, message string) {
@@ -93,6 +380,12 @@ func runExport() error {
 		return fmt.Errorf("context must have an output directory configured")
 	}
 
+	// Resolve the Git repository; the backend flag overrides the context's
+	gitRepo, err := contextGitRepo(activeContext, exportGitBackend)
+	if err != nil {
+		return err
+	}
+
 	if exportDryRun {
 		printWarning("Dry run mode - no files will be written")
 		printInfo(fmt.Sprintf("Would export to %s", outputDir))
@@ -101,33 +394,115 @@ func runExport() error {
 
 	printSeparator()
 
+	// The work tree of a shared repository must exist before files are moved into it
+	if gitRepo.IsShared() {
+		if err := gitRepo.Init(); err != nil {
+			return fmt.Errorf("failed to prepare the shared repository: %w", err)
+		}
+	}
+
+	// Export into a staging directory so a failed export never touches the repository
+	stagingDir, err := dumper.CreateStagingDir(outputDir)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(stagingDir)
+	d.SetReferenceDir(outputDir)
+
+	// Cancel the export on Ctrl-C or when the deadline passes; the staging
+	// directory is discarded so the repository is left untouched
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
 	// Execute the main dump function
-	if err := d.DumpAllResources(outputDir); err != nil {
+	result, err := d.DumpAllResources(ctx, stagingDir)
+	if err != nil {
 		return fmt.Errorf("failed to export resources: %w", err)
 	}
+	printSeparator()
+	printExportResult(result)
+
+	// Verify completeness before touching the repository
+	failedKinds := d.FailedKinds()
+	if len(failedKinds) > 0 {
+		if !exportAllowPartial {
+			return fmt.Errorf("export incomplete, failed to list: %s (output directory left unchanged, use --allow-partial to commit anyway)", strings.Join(failedKinds, ", "))
+		}
+		printWarning(fmt.Sprintf("Export incomplete, failed to list: %s", strings.Join(failedKinds, ", ")))
+	}
+
+	// Swap the staged export in, removing files of resources that no longer exist in the cluster
+	pruned, err := d.PromoteStaging(stagingDir, outputDir, !exportNoPrune)
+	if err != nil {
+		return fmt.Errorf("failed to update output directory: %w", err)
+	}
 
 	printSuccess("Resource export completed")
 
+	if len(pruned) > 0 {
+		printInfo(fmt.Sprintf("Pruned %d stale resources:", len(pruned)))
+		for _, file := range pruned {
+			printInfo(fmt.Sprintf("   %s", file))
+		}
+	}
+
 	// Handle Git repository operations (always commit)
 	printSeparator()
 	commitMsg := exportCommitMessage
 	if commitMsg == "" {
 		commitMsg = fmt.Sprintf("Kalco export: %s", time.Now().Format("2006-01-02 15:04:05"))
 	}
+	gitCommitMsg := commitMsg
+	if len(failedKinds) > 0 {
+		commitMsg = "[PARTIAL] " + commitMsg
+		gitCommitMsg = fmt.Sprintf("%s\n\nFailed to list: %s", commitMsg, strings.Join(failedKinds, ", "))
+	}
 
-	gitRepo := git.NewGitRepo(outputDir)
-	if err := gitRepo.SetupAndCommit(commitMsg, exportGitPush); err != nil {
+	if exportGitPush && activeContext.Git.TokenEnv != "" && os.Getenv(activeContext.Git.TokenEnv) == "" {
+		printWarning(fmt.Sprintf("Git token variable %s is not set, pushing without it", activeContext.Git.TokenEnv))
+	}
+	if !exportCommitStatus {
+		// Status files stay on disk as an archive but never enter the Git history
+		gitRepo.SetExcludePatterns([]string{"*" + dumper.StatusFileSuffix, "*" + dumper.StatusFileSuffixJSON})
+	}
+	// The manifest records export times and resource versions, which alone are no change
+	gitRepo.SetVolatileFiles([]string{dumper.ManifestFileName})
+	if err := gitRepo.SetupAndCommit(gitCommitMsg, exportGitPush); err != nil {
 		printWarning(fmt.Sprintf("Git operations failed: %v", err))
 	} else {
 		printSuccess("Git repository updated")
-		if exportGitPush {
-			printSuccess("Changes pushed to remote origin")
+		if exportGitPush && gitRepo.HasRemote() {
+			printSuccess(fmt.Sprintf("Changes pushed to %s", gitRepo.RemoteName()))
 		}
 	}
 
 	// Generate change report
 	printSeparator()
 	reportGen := reports.NewReportGenerator(outputDir)
+	reportGen.SetGitBackend(gitRepo.Backend())
+	reportGen.SetPartial(failedKinds)
+	reportGen.SetExportResult(result)
+	reportGen.SetLayout(layout)
+	if exportDeprecations || exportTargetVersion != "" {
+		target := targetVersion
+		if target.IsZero() && serverVersion != nil {
+			target, _ = deprecationTarget("", serverVersion.GitVersion)
+		}
+		findings, err := deprecations.Scan(outputDir, target)
+		if err != nil {
+			printWarning(fmt.Sprintf("Deprecation check failed: %v", err))
+		} else {
+			reportGen.SetDeprecations(target, findings)
+			if removed := deprecations.CountRemoved(findings); removed > 0 {
+				printWarning(removedMessage(removed, target))
+			}
+		}
+	}
 	if err := reportGen.GenerateReport(commitMsg); err != nil {
 		printWarning(fmt.Sprintf("Report generation failed: %v", err))
 	} else {
@@ -135,18 +510,94 @@ func runExport() error {
 	}
 
 	// Success summary
+	if exportFailOnError && result.HasErrors() {
+		return fmt.Errorf("export finished with errors: %d forbidden, %d failed resources, %d object errors",
+			result.Totals.Forbidden, result.Totals.Failed, result.Totals.Errors)
+	}
+	if len(failedKinds) > 0 {
+		printWarning(fmt.Sprintf("Partial export committed to %s", outputDir))
+		return nil
+	}
 	printSuccess(fmt.Sprintf("Export completed successfully to %s", outputDir))
 
 	return nil
 }
 
+// printExportResult prints the per-resource outcome of an export as a summary table
+func printExportResult(result *dumper.ExportResult) {
+	printSubHeader("Export Summary")
+	printTableHeader(fmt.Sprintf("%-50s", "RESOURCE"), fmt.Sprintf("%-30s", "KIND"), fmt.Sprintf("%8s", "OBJECTS"), "STATUS")
+	for _, res := range result.Resources {
+		printTableRow(fmt.Sprintf("%-50s", res.GVR()), fmt.Sprintf("%-30s", res.Kind), fmt.Sprintf("%8d", res.Objects), res.Status)
+	}
+
+	totals := result.Totals
+	printInfo(fmt.Sprintf("%d objects from %d resources across %d namespaces in %s",
+		totals.Objects, totals.Resources, result.Namespaces, result.Duration.Round(time.Millisecond)))
+	if totals.Skipped > 0 {
+		printInfo(fmt.Sprintf("%d resources skipped", totals.Skipped))
+	}
+
+	for _, failure := range result.FailedGroups {
+		printWarning(fmt.Sprintf("Not discovered: %s - %s", failure.GroupVersion, failure.Error))
+	}
+	for _, failure := range result.Forbidden {
+		printWarning(fmt.Sprintf("Forbidden: %s - %s", resourceErrorTarget(failure), failure.Error))
+	}
+	for _, failure := range result.Failed {
+		printError(fmt.Sprintf("Failed: %s - %s", resourceErrorTarget(failure), failure.Error))
+	}
+}
+
+// resourceErrorTarget describes what a resource error refers to
+func resourceErrorTarget(failure dumper.ResourceError) string {
+	target := failure.Resource
+	if failure.Namespace != "" {
+		target += " in " + failure.Namespace
+	}
+	if failure.Name != "" {
+		target += " (" + failure.Name + ")"
+	}
+	return target
+}
+
 func init() {
 	rootCmd.AddCommand(exportCmd)
 
 	// Add flags
-	exportCmd.Flags().BoolVar(&exportGitPush, "git-push", false, "automatically push changes to remote origin")
+	exportCmd.Flags().BoolVar(&exportGitPush, "git-push", false, "automatically push changes to the context's Git remote (default origin)")
 	exportCmd.Flags().StringVarP(&exportCommitMessage, "commit-message", "m", "", "custom Git commit message")
 	exportCmd.Flags().BoolVar(&exportDryRun, "dry-run", false, "show what would be exported without writing files")
+	exportCmd.Flags().IntVar(&exportConcurrency, "concurrency", dumper.DefaultConcurrency, "number of resource list operations to run in parallel")
+	exportCmd.Flags().StringVar(&exportSecretMode, "secrets", "", "how to export Secret data: include, redact, omit or encrypt (default from context, else include)")
+	exportCmd.Flags().StringVar(&exportCleanup, "cleanup-profile", "", "cleanup profile: minimal, reapply, full or path to a profile file (default from context, else reapply)")
+	exportCmd.Flags().StringVar(&exportStatusMode, "status", "none", "status capture: none, inline or separate (<name>.status.yaml)")
+	exportCmd.Flags().StringVar(&exportLayout, "layout", "", "file layout: tree, namespace, kind, single or flat (default from context, else tree)")
+	exportCmd.Flags().StringVar(&exportFormat, "format", "", "file format: yaml or json (default from context, else yaml)")
+	exportCmd.Flags().StringArrayVar(&exportAPIVersions, "api-version", nil, "export this version of an API group instead of the preferred one, as group=version, e.g. autoscaling=v2 (repeatable, adds to the context's pins)")
+	exportCmd.Flags().StringArrayVar(&exportAddVersions, "additional-version", nil, "also export objects in this API version to <name>@<version> files, as group/version (repeatable, default from context)")
+	exportCmd.Flags().BoolVar(&exportCommitStatus, "commit-status", true, "include separately captured status files in the Git commit")
+	exportCmd.Flags().BoolVar(&exportNoPrune, "no-prune", false, "keep files of resources that no longer exist in the cluster")
+	exportCmd.Flags().BoolVar(&exportAllowPartial, "allow-partial", false, "commit the export even if listing some resource kinds failed")
+	exportCmd.Flags().BoolVar(&exportDeprecations, "check-deprecations", false, "add deprecated and removed API versions in use to the report")
+	exportCmd.Flags().StringVar(&exportTargetVersion, "target-version", "", "Kubernetes version deprecations are checked against, e.g. v1.29 (implies --check-deprecations; default: the release after the cluster's version)")
+	exportCmd.Flags().StringVar(&exportGitBackend, "git-backend", "", "Git implementation: go-git or exec to run the git binary (default from context, else go-git)")
+	exportCmd.Flags().BoolVar(&exportFailOnError, "fail-on-error", false, "exit non-zero when any resource could not be fully exported")
+	exportCmd.Flags().Float32Var(&exportQPS, "qps", kube.DefaultQPS, "maximum requests per second to the API server (default from context)")
+	exportCmd.Flags().IntVar(&exportBurst, "burst", kube.DefaultBurst, "maximum burst of requests to the API server (default from context)")
+	exportCmd.Flags().DurationVar(&exportRequestTimeout, "request-timeout", kube.DefaultRequestTimeout, "timeout of a single API request (default from context)")
+	exportCmd.Flags().IntVar(&exportRetries, "retries", kube.DefaultMaxRetries, "retries with exponential backoff for transient API errors (default from context)")
+	exportCmd.Flags().DurationVar(&exportTimeout, "timeout", 0, "deadline for the whole export, e.g. 30m (0 means no deadline; default from context)")
+	exportCmd.Flags().StringVar(&exportAs, "as", "", "user to impersonate, e.g. system:serviceaccount:kalco:kalco-reader (default from context)")
+	exportCmd.Flags().StringArrayVar(&exportAsGroups, "as-group", []string{}, "group to impersonate, can be repeated (default from context)")
+	exportCmd.Flags().Int64Var(&exportPageSize, "page-size", dumper.DefaultPageSize, "number of objects requested per List call (0 disables paging)")
+
+	// Filter flags
+	exportCmd.Flags().StringSliceVar(&exportNamespaces, "namespaces", nil, "only export these namespaces (comma-separated, globs allowed)")
+	exportCmd.Flags().StringSliceVar(&exportExcludeNamespaces, "exclude-namespaces", nil, "namespaces to skip (comma-separated, globs allowed)")
+	exportCmd.Flags().StringSliceVar(&exportResources, "resources", nil, "only export these resource kinds, plural names or groups (comma-separated, globs allowed)")
+	exportCmd.Flags().StringSliceVar(&exportExcludeResources, "exclude-resources", nil, "resource kinds, plural names or groups to skip (comma-separated, globs allowed)")
+	exportCmd.Flags().StringSliceVar(&exportSkipResources, "skip-resources", dumper.DefaultSkipResources, "ephemeral resources skipped unless selected with --resources (empty to export all)")
 
 	// Add aliases
 	exportCmd.Aliases = []string{"dump", "backup"}
@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"kalco/pkg/dumper"
+	"kalco/pkg/kube"
+	"kalco/pkg/rbac"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	rbacName             string
+	rbacNamespace        string
+	rbacOutputFile       string
+	rbacSecretMode       string
+	rbacResources        []string
+	rbacExcludeResources []string
+	rbacSkipResources    []string
+)
+
+var rbacCmd = &cobra.Command{
+	Use:   "rbac",
+	Short: "Manage the RBAC permissions kalco needs",
+	Long: formatLongDescription(`
+Manage the Kubernetes RBAC permissions needed to run kalco with a dedicated,
+read-only identity.
+`),
+}
+
+var rbacGenerateCmd = &cobra.Command{
+	Use:   "generate",
+	Short: "Generate a least-privilege ServiceAccount, ClusterRole and ClusterRoleBinding",
+	Long: formatLongDescription(`
+Generate a ServiceAccount, ClusterRole and ClusterRoleBinding granting exactly the
+list permissions an export needs. The cluster of the active context is discovered
+the same way kalco export does, honouring the resource filters and secret mode, so
+the role only covers resources that would actually be exported.
+
+The manifest is written to stdout, or to the file given with --output. Verify the
+role before deploying kalco with it by impersonating the ServiceAccount; any
+forbidden resource makes the export fail without touching the output directory:
+
+  kubectl apply -f kalco-rbac.yaml
+  kalco export --as system:serviceaccount:kalco:kalco-reader --fail-on-error
+`),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runRBACGenerate()
+	},
+}
+
+func runRBACGenerate() error {
+	activeContext, err := getActiveContext()
+	if err != nil {
+		return fmt.Errorf("no active context found: %w", err)
+	}
+
+	opts, err := clientOptions(activeContext)
+	if err != nil {
+		return err
+	}
+	if opts.KubeConfig == "" {
+		return fmt.Errorf("context must have a kubeconfig configured (or pass --kubeconfig)")
+	}
+
+	clientset, discoveryClient, _, err := kube.NewClientsWithOptions(opts)
+	if err != nil {
+		return fmt.Errorf("failed to create Kubernetes clients: %w", err)
+	}
+
+	// Plan the export exactly as kalco export would
+	d := dumper.NewDumper(clientset, discoveryClient)
+	secretMode := rbacSecretMode
+	if secretMode == "" {
+		secretMode = activeContext.Export.SecretMode
+	}
+	mode, err := dumper.ParseSecretMode(secretMode)
+	if err != nil {
+		return err
+	}
+	if mode == dumper.SecretsOmit {
+		// Only whether Secrets are listed at all matters for the role
+		if err := d.SetSecretMode(mode, nil); err != nil {
+			return err
+		}
+	}
+	d.SetSkipResources(rbacSkipResources)
+	d.SetFilter(dumper.ResourceFilter{
+		Resources:        rbacResources,
+		ExcludeResources: rbacExcludeResources,
+	})
+	d.SetOutputCallback(func(level, message string) {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", level, message)
+	})
+
+	resources, err := d.PlannedResources()
+	if err != nil {
+		return fmt.Errorf("failed to discover resources: %w", err)
+	}
+
+	manifestOpts := rbac.Options{Name: rbacName, Namespace: rbacNamespace}
+	manifest, err := rbac.GenerateManifest(resources, manifestOpts)
+	if err != nil {
+		return err
+	}
+
+	if rbacOutputFile == "" {
+		fmt.Print(string(manifest))
+		return nil
+	}
+	if err := os.WriteFile(rbacOutputFile, manifest, 0644); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	printSuccess(fmt.Sprintf("RBAC manifest for %d resources written to %s", len(resources), rbacOutputFile))
+	printInfo(fmt.Sprintf("Verify it with: kalco export --as %s --fail-on-error", rbac.ServiceAccountUser(manifestOpts)))
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(rbacCmd)
+	rbacCmd.AddCommand(rbacGenerateCmd)
+
+	rbacGenerateCmd.Flags().StringVar(&rbacName, "name", rbac.DefaultName, "name of the ServiceAccount, ClusterRole and ClusterRoleBinding")
+	rbacGenerateCmd.Flags().StringVar(&rbacNamespace, "namespace", rbac.DefaultNamespace, "namespace of the ServiceAccount")
+	rbacGenerateCmd.Flags().StringVarP(&rbacOutputFile, "output", "o", "", "write the manifest to this file instead of stdout")
+	rbacGenerateCmd.Flags().StringVar(&rbacSecretMode, "secrets", "", "secret mode of the export; omit drops Secrets from the role (default from context)")
+	rbacGenerateCmd.Flags().StringSliceVar(&rbacResources, "resources", nil, "only grant these resource kinds, plural names or groups (comma-separated, globs allowed)")
+	rbacGenerateCmd.Flags().StringSliceVar(&rbacExcludeResources, "exclude-resources", nil, "resource kinds, plural names or groups to leave out (comma-separated, globs allowed)")
+	rbacGenerateCmd.Flags().StringSliceVar(&rbacSkipResources, "skip-resources", dumper.DefaultSkipResources, "ephemeral resources skipped unless selected with --resources (empty to include all)")
+}
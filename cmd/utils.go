@@ -5,8 +5,12 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"kalco/pkg/context"
+	"kalco/pkg/dumper"
+	"kalco/pkg/git"
+	"kalco/pkg/kube"
 )
 
 // getConfigDir returns the Kalco configuration directory
@@ -35,6 +39,101 @@ func getActiveContext() (*context.Context, error) {
 	return cm.GetCurrentContext()
 }
 
+// gitRemoteConfig returns where and how a context's output directory is pushed; the
+// token is read from the environment variable the context names
+func gitRemoteConfig(ctx *context.Context) git.RemoteConfig {
+	remote := git.RemoteConfig{
+		Name:   ctx.Git.Remote,
+		URL:    ctx.Git.RemoteURL,
+		Branch: ctx.Git.Branch,
+		Rebase: ctx.Git.Rebase,
+		Auth:   git.Auth{SSHKeyPath: ctx.Git.SSHKey, Username: ctx.Git.Username},
+	}
+	if ctx.Git.TokenEnv != "" {
+		remote.Auth.Token = os.Getenv(ctx.Git.TokenEnv)
+	}
+	return remote
+}
+
+// secretHashKey returns the key a context's Secret value hashes are computed with: the
+// KALCO_SECRET_HASH_KEY environment variable, or a key kept in the config directory and
+// generated on first use. It is never written to the output directory.
+func secretHashKey(ctx *context.Context) ([]byte, error) {
+	if key := os.Getenv(dumper.SecretHashKeyEnv); key != "" {
+		return []byte(key), nil
+	}
+	configDir, err := getConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	return dumper.LoadSecretHashKey(filepath.Join(configDir, "secret-keys", ctx.Name+".key"))
+}
+
+// contextGitRepo returns the Git repository of a context's output directory: a work tree
+// of the context's shared repository when one is set. A non-empty backend name overrides
+// the context's backend.
+func contextGitRepo(ctx *context.Context, backendName string) (*git.GitRepo, error) {
+	if backendName == "" {
+		backendName = ctx.Git.Backend
+	}
+	backend, err := git.NewBackend(backendName, ctx.OutputDir)
+	if err != nil {
+		return nil, err
+	}
+
+	repo := git.NewGitRepo(ctx.OutputDir)
+	repo.SetBackend(backend)
+	repo.SetRemote(gitRemoteConfig(ctx))
+	if ctx.Git.Repository != "" {
+		branch := ctx.Git.Branch
+		if branch == "" {
+			branch = git.ClusterBranchPrefix + ctx.Name
+		}
+		repo.SetSharedRepository(ctx.Git.Repository, branch)
+	}
+	return repo, nil
+}
+
+// clientOptions returns the cluster selection and client tuning of a context. The global
+// --kubeconfig flag overrides the context's kubeconfig.
+func clientOptions(ctx *context.Context) (kube.ClientOptions, error) {
+	kubeconfigPath := ctx.KubeConfig
+	if kubeconfig != "" {
+		kubeconfigPath = kubeconfig
+	}
+
+	opts := kube.ClientOptions{
+		KubeConfig:        kubeconfigPath,
+		Context:           ctx.Kube.Context,
+		Cluster:           ctx.Kube.Cluster,
+		User:              ctx.Kube.User,
+		Namespace:         ctx.Kube.Namespace,
+		Impersonate:       ctx.Kube.Impersonate,
+		ImpersonateGroups: ctx.Kube.ImpersonateGroups,
+		QPS:               kube.DefaultQPS,
+		Burst:             kube.DefaultBurst,
+		RequestTimeout:    kube.DefaultRequestTimeout,
+		MaxRetries:        kube.DefaultMaxRetries,
+	}
+	if ctx.Kube.QPS > 0 {
+		opts.QPS = ctx.Kube.QPS
+	}
+	if ctx.Kube.Burst > 0 {
+		opts.Burst = ctx.Kube.Burst
+	}
+	if ctx.Kube.Retries > 0 {
+		opts.MaxRetries = ctx.Kube.Retries
+	}
+	if ctx.Kube.RequestTimeout != "" {
+		timeout, err := time.ParseDuration(ctx.Kube.RequestTimeout)
+		if err != nil {
+			return opts, fmt.Errorf("invalid request timeout '%s' in context: %w", ctx.Kube.RequestTimeout, err)
+		}
+		opts.RequestTimeout = timeout
+	}
+	return opts, nil
+}
+
 // requireActiveContext ensures that an active context exists before executing a command
 // This function will exit the program if no context is active
 func requireActiveContext() {
@@ -61,9 +160,14 @@ func requireActiveContext() {
 	if activeContext.Description != "" {
 		printInfo(fmt.Sprintf("   Description: %s", activeContext.Description))
 	}
-	if activeContext.KubeConfig != "" {
+	if kubeconfig != "" {
+		printInfo(fmt.Sprintf("   Kubeconfig: %s (--kubeconfig)", kubeconfig))
+	} else if activeContext.KubeConfig != "" {
 		printInfo(fmt.Sprintf("   Kubeconfig: %s", activeContext.KubeConfig))
 	}
+	if activeContext.Kube.Context != "" {
+		printInfo(fmt.Sprintf("   Kube Context: %s", activeContext.Kube.Context))
+	}
 	if activeContext.OutputDir != "" {
 		printInfo(fmt.Sprintf("   Output Dir: %s", activeContext.OutputDir))
 	}
@@ -7,7 +7,7 @@ import (
 	"path/filepath"
 	"time"
 
-	"os/exec"
+	"kalco/pkg/git"
 
 	"gopkg.in/yaml.v3"
 )
@@ -19,10 +19,69 @@ type Context struct {
 	OutputDir   string            `json:"output_dir" yaml:"output_dir"`
 	Labels      map[string]string `json:"labels" yaml:"labels"`
 	Description string            `json:"description" yaml:"description"`
+	Kube        KubeSettings      `json:"kube,omitempty" yaml:"kube,omitempty"`
+	Export      ExportSettings    `json:"export,omitempty" yaml:"export,omitempty"`
+	Git         GitSettings       `json:"git,omitempty" yaml:"git,omitempty"`
 	CreatedAt   time.Time         `json:"created_at" yaml:"created_at"`
 	UpdatedAt   time.Time         `json:"updated_at" yaml:"updated_at"`
 }
 
+// KubeSettings selects the kubeconfig context, cluster and identity a context targets.
+// Empty fields fall back to the kubeconfig's current context.
+type KubeSettings struct {
+	Context           string   `json:"context,omitempty" yaml:"context,omitempty"`
+	Cluster           string   `json:"cluster,omitempty" yaml:"cluster,omitempty"`
+	User              string   `json:"user,omitempty" yaml:"user,omitempty"`
+	Namespace         string   `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+	Impersonate       string   `json:"impersonate,omitempty" yaml:"impersonate,omitempty"`
+	ImpersonateGroups []string `json:"impersonate_groups,omitempty" yaml:"impersonate_groups,omitempty"`
+
+	// Client tuning; zero values use kalco's defaults
+	QPS            float32 `json:"qps,omitempty" yaml:"qps,omitempty"`
+	Burst          int     `json:"burst,omitempty" yaml:"burst,omitempty"`
+	RequestTimeout string  `json:"request_timeout,omitempty" yaml:"request_timeout,omitempty"`
+	Retries        int     `json:"retries,omitempty" yaml:"retries,omitempty"`
+}
+
+// ExportSettings holds per-context defaults for kalco export
+type ExportSettings struct {
+	CleanupProfile   string   `json:"cleanup_profile,omitempty" yaml:"cleanup_profile,omitempty"`
+	SecretMode       string   `json:"secret_mode,omitempty" yaml:"secret_mode,omitempty"`
+	SecretRecipients []string `json:"secret_recipients,omitempty" yaml:"secret_recipients,omitempty"`
+	Timeout          string   `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	Layout           string   `json:"layout,omitempty" yaml:"layout,omitempty"`
+	Format           string   `json:"format,omitempty" yaml:"format,omitempty"`
+
+	// APIVersions pins the exported version per API group ("core" for the core group)
+	APIVersions        map[string]string `json:"api_versions,omitempty" yaml:"api_versions,omitempty"`
+	AdditionalVersions []string          `json:"additional_versions,omitempty" yaml:"additional_versions,omitempty"`
+}
+
+// GitSettings configures the Git repository of a context's output directory
+type GitSettings struct {
+	// Backend is the Git implementation: go-git (default) or exec
+	Backend string `json:"backend,omitempty" yaml:"backend,omitempty"`
+
+	// Repository is a repository shared by several contexts. The output directory is
+	// then a work tree of it on the context's own branch (Branch, else clusters/<name>).
+	Repository string `json:"repository,omitempty" yaml:"repository,omitempty"`
+
+	// Where to push: the remote (origin when empty), its URL (added or updated before
+	// pushing) and the target branch (the current branch when empty)
+	Remote    string `json:"remote,omitempty" yaml:"remote,omitempty"`
+	RemoteURL string `json:"remote_url,omitempty" yaml:"remote_url,omitempty"`
+	Branch    string `json:"branch,omitempty" yaml:"branch,omitempty"`
+
+	// Push authentication: an SSH private key, or a token read from an environment
+	// variable for HTTP(S) remotes
+	SSHKey   string `json:"ssh_key,omitempty" yaml:"ssh_key,omitempty"`
+	TokenEnv string `json:"token_env,omitempty" yaml:"token_env,omitempty"`
+	Username string `json:"username,omitempty" yaml:"username,omitempty"`
+
+	// Rebase onto the remote branch and retry when a push is rejected
+	Rebase bool `json:"rebase,omitempty" yaml:"rebase,omitempty"`
+}
+
 // ContextManager handles context operations
 type ContextManager struct {
 	configDir string
@@ -83,9 +142,12 @@ func (cm *ContextManager) SetContext(name, kubeconfig, outputDir, description st
 		UpdatedAt:   now,
 	}
 
-	// If context exists, preserve creation time
+	// If context exists, preserve creation time, kube, export and Git settings
 	if existing, exists := cm.contexts[name]; exists {
 		context.CreatedAt = existing.CreatedAt
+		context.Kube = existing.Kube
+		context.Export = existing.Export
+		context.Git = existing.Git
 	} else {
 		context.CreatedAt = now
 	}
@@ -100,6 +162,23 @@ func (cm *ContextManager) SetContext(name, kubeconfig, outputDir, description st
 	return nil
 }
 
+// UpdateContext applies changes to an existing context and saves it
+func (cm *ContextManager) UpdateContext(name string, update func(*Context)) error {
+	context, exists := cm.contexts[name]
+	if !exists {
+		return fmt.Errorf("context '%s' not found", name)
+	}
+
+	update(context)
+	context.UpdatedAt = time.Now()
+
+	if err := cm.saveContexts(); err != nil {
+		return fmt.Errorf("failed to save contexts: %w", err)
+	}
+
+	return nil
+}
+
 // GetContext retrieves a context by name
 func (cm *ContextManager) GetContext(name string) (*Context, error) {
 	context, exists := cm.contexts[name]
@@ -281,23 +360,15 @@ func (cm *ContextManager) initializeKalcoDirectory(outputDir, contextName, kubec
 	// Initialize Git repository if not already initialized
 	gitDir := filepath.Join(outputDir, ".git")
 	if _, err := os.Stat(gitDir); os.IsNotExist(err) {
-		// Initialize Git repository
-		cmd := exec.Command("git", "init")
-		cmd.Dir = outputDir
-		if err := cmd.Run(); err != nil {
+		// Initialize Git repository and commit the initial files
+		repo := git.NewGoGitBackend(outputDir)
+		if err := repo.Init(); err != nil {
 			return fmt.Errorf("failed to initialize Git repository: %w", err)
 		}
-
-		// Add and commit initial files
-		cmd = exec.Command("git", "add", ".")
-		cmd.Dir = outputDir
-		if err := cmd.Run(); err != nil {
+		if err := repo.AddAll(nil); err != nil {
 			return fmt.Errorf("failed to add files to Git: %w", err)
 		}
-
-		cmd = exec.Command("git", "commit", "-m", fmt.Sprintf("Initial kalco context: %s", contextName))
-		cmd.Dir = outputDir
-		if err := cmd.Run(); err != nil {
+		if _, err := repo.Commit(fmt.Sprintf("Initial kalco context: %s", contextName)); err != nil {
 			return fmt.Errorf("failed to commit initial files: %w", err)
 		}
 	}
@@ -504,3 +504,61 @@ func TestContextTimestamps(t *testing.T) {
 		t.Errorf("UpdatedAt %v is not within expected range [%v, %v]", updatedContext.UpdatedAt, beforeUpdate, afterUpdate)
 	}
 }
+
+func TestUpdateContext(t *testing.T) {
+	tempDir := t.TempDir()
+	cm, err := NewContextManager(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to create context manager: %v", err)
+	}
+
+	// Test updating non-existent context
+	err = cm.UpdateContext("nonexistent", func(c *Context) {})
+	if err == nil {
+		t.Fatal("Expected error for non-existent context, got none")
+	}
+
+	outputDir := filepath.Join(tempDir, "output")
+	err = cm.SetContext("test-context", tempDir, outputDir, "Description", nil)
+	if err != nil {
+		t.Fatalf("Failed to set context: %v", err)
+	}
+
+	err = cm.UpdateContext("test-context", func(c *Context) {
+		c.Export.SecretMode = "encrypt"
+		c.Export.SecretRecipients = []string{"age1example"}
+		c.Kube.Context = "production"
+		c.Git.Branch = "snapshots"
+		c.Git.Rebase = true
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Kube, export and Git settings should survive a reload and a later SetContext
+	err = cm.SetContext("test-context", tempDir, outputDir, "Updated", nil)
+	if err != nil {
+		t.Fatalf("Failed to update context: %v", err)
+	}
+
+	cm2, err := NewContextManager(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to reload context manager: %v", err)
+	}
+	context, err := cm2.GetContext("test-context")
+	if err != nil {
+		t.Fatalf("Failed to get context: %v", err)
+	}
+	if context.Export.SecretMode != "encrypt" {
+		t.Errorf("Expected secret mode 'encrypt', got %s", context.Export.SecretMode)
+	}
+	if len(context.Export.SecretRecipients) != 1 {
+		t.Errorf("Expected 1 secret recipient, got %d", len(context.Export.SecretRecipients))
+	}
+	if context.Kube.Context != "production" {
+		t.Errorf("Expected kube context 'production', got %s", context.Kube.Context)
+	}
+	if context.Git.Branch != "snapshots" || !context.Git.Rebase {
+		t.Errorf("Expected Git branch 'snapshots' with rebase, got %+v", context.Git)
+	}
+}
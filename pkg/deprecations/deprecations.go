@@ -0,0 +1,309 @@
+package deprecations
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"kalco/pkg/dumper"
+)
+
+// LastAppliedAnnotation holds the configuration last applied with kubectl apply, whose
+// apiVersion is what the next apply of the same manifest will use
+const LastAppliedAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+// Sources of a finding
+const (
+	SourceObject      = "object"
+	SourceLastApplied = "last-applied"
+)
+
+// Version is a Kubernetes minor release such as v1.29
+type Version struct {
+	Major int
+	Minor int
+}
+
+// ParseVersion parses a Kubernetes version such as "1.29", "v1.29" or "v1.28.4-gke.100";
+// only the major and minor release are kept
+func ParseVersion(value string) (Version, error) {
+	parts := strings.SplitN(strings.TrimPrefix(strings.TrimSpace(value), "v"), ".", 3)
+	if len(parts) < 2 {
+		return Version{}, fmt.Errorf("invalid Kubernetes version '%s' (expected e.g. v1.29)", value)
+	}
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return Version{}, fmt.Errorf("invalid Kubernetes version '%s' (expected e.g. v1.29)", value)
+	}
+	// Some providers report minors such as "28+"
+	minor, err := strconv.Atoi(strings.TrimRight(parts[1], "+"))
+	if err != nil {
+		return Version{}, fmt.Errorf("invalid Kubernetes version '%s' (expected e.g. v1.29)", value)
+	}
+	return Version{Major: major, Minor: minor}, nil
+}
+
+// IsZero reports whether no version is set
+func (v Version) IsZero() bool {
+	return v == Version{}
+}
+
+// Before reports whether v is an earlier release than other
+func (v Version) Before(other Version) bool {
+	if v.Major != other.Major {
+		return v.Major < other.Major
+	}
+	return v.Minor < other.Minor
+}
+
+// Next returns the minor release following v, the one an upgrade of v moves to
+func (v Version) Next() Version {
+	return Version{Major: v.Major, Minor: v.Minor + 1}
+}
+
+func (v Version) String() string {
+	if v.IsZero() {
+		return ""
+	}
+	return fmt.Sprintf("v%d.%d", v.Major, v.Minor)
+}
+
+// MarshalText writes the version as in "v1.29", or empty when not set
+func (v Version) MarshalText() ([]byte, error) {
+	return []byte(v.String()), nil
+}
+
+// Deprecation is a deprecated API version of a Kind. RemovedIn is zero when no removal
+// is scheduled.
+type Deprecation struct {
+	APIVersion   string  `json:"apiVersion"`
+	Kind         string  `json:"kind"`
+	DeprecatedIn Version `json:"deprecatedIn"`
+	RemovedIn    Version `json:"removedIn"`
+	Replacement  string  `json:"replacement,omitempty"`
+}
+
+// Deprecations is the bundled table of deprecated and removed Kubernetes APIs, from the
+// upstream deprecation guide
+var Deprecations = []Deprecation{
+	// Removed in v1.16
+	{APIVersion: "extensions/v1beta1", Kind: "DaemonSet", DeprecatedIn: Version{1, 9}, RemovedIn: Version{1, 16}, Replacement: "apps/v1"},
+	{APIVersion: "extensions/v1beta1", Kind: "Deployment", DeprecatedIn: Version{1, 9}, RemovedIn: Version{1, 16}, Replacement: "apps/v1"},
+	{APIVersion: "extensions/v1beta1", Kind: "ReplicaSet", DeprecatedIn: Version{1, 9}, RemovedIn: Version{1, 16}, Replacement: "apps/v1"},
+	{APIVersion: "extensions/v1beta1", Kind: "NetworkPolicy", DeprecatedIn: Version{1, 9}, RemovedIn: Version{1, 16}, Replacement: "networking.k8s.io/v1"},
+	{APIVersion: "extensions/v1beta1", Kind: "PodSecurityPolicy", DeprecatedIn: Version{1, 11}, RemovedIn: Version{1, 16}, Replacement: "policy/v1beta1"},
+	{APIVersion: "apps/v1beta1", Kind: "Deployment", DeprecatedIn: Version{1, 9}, RemovedIn: Version{1, 16}, Replacement: "apps/v1"},
+	{APIVersion: "apps/v1beta1", Kind: "StatefulSet", DeprecatedIn: Version{1, 9}, RemovedIn: Version{1, 16}, Replacement: "apps/v1"},
+	{APIVersion: "apps/v1beta2", Kind: "DaemonSet", DeprecatedIn: Version{1, 9}, RemovedIn: Version{1, 16}, Replacement: "apps/v1"},
+	{APIVersion: "apps/v1beta2", Kind: "Deployment", DeprecatedIn: Version{1, 9}, RemovedIn: Version{1, 16}, Replacement: "apps/v1"},
+	{APIVersion: "apps/v1beta2", Kind: "ReplicaSet", DeprecatedIn: Version{1, 9}, RemovedIn: Version{1, 16}, Replacement: "apps/v1"},
+	{APIVersion: "apps/v1beta2", Kind: "StatefulSet", DeprecatedIn: Version{1, 9}, RemovedIn: Version{1, 16}, Replacement: "apps/v1"},
+
+	// Removed in v1.22
+	{APIVersion: "admissionregistration.k8s.io/v1beta1", Kind: "MutatingWebhookConfiguration", DeprecatedIn: Version{1, 16}, RemovedIn: Version{1, 22}, Replacement: "admissionregistration.k8s.io/v1"},
+	{APIVersion: "admissionregistration.k8s.io/v1beta1", Kind: "ValidatingWebhookConfiguration", DeprecatedIn: Version{1, 16}, RemovedIn: Version{1, 22}, Replacement: "admissionregistration.k8s.io/v1"},
+	{APIVersion: "apiextensions.k8s.io/v1beta1", Kind: "CustomResourceDefinition", DeprecatedIn: Version{1, 16}, RemovedIn: Version{1, 22}, Replacement: "apiextensions.k8s.io/v1"},
+	{APIVersion: "apiregistration.k8s.io/v1beta1", Kind: "APIService", DeprecatedIn: Version{1, 19}, RemovedIn: Version{1, 22}, Replacement: "apiregistration.k8s.io/v1"},
+	{APIVersion: "certificates.k8s.io/v1beta1", Kind: "CertificateSigningRequest", DeprecatedIn: Version{1, 19}, RemovedIn: Version{1, 22}, Replacement: "certificates.k8s.io/v1"},
+	{APIVersion: "coordination.k8s.io/v1beta1", Kind: "Lease", DeprecatedIn: Version{1, 19}, RemovedIn: Version{1, 22}, Replacement: "coordination.k8s.io/v1"},
+	{APIVersion: "extensions/v1beta1", Kind: "Ingress", DeprecatedIn: Version{1, 14}, RemovedIn: Version{1, 22}, Replacement: "networking.k8s.io/v1"},
+	{APIVersion: "networking.k8s.io/v1beta1", Kind: "Ingress", DeprecatedIn: Version{1, 19}, RemovedIn: Version{1, 22}, Replacement: "networking.k8s.io/v1"},
+	{APIVersion: "networking.k8s.io/v1beta1", Kind: "IngressClass", DeprecatedIn: Version{1, 19}, RemovedIn: Version{1, 22}, Replacement: "networking.k8s.io/v1"},
+	{APIVersion: "rbac.authorization.k8s.io/v1beta1", Kind: "ClusterRole", DeprecatedIn: Version{1, 17}, RemovedIn: Version{1, 22}, Replacement: "rbac.authorization.k8s.io/v1"},
+	{APIVersion: "rbac.authorization.k8s.io/v1beta1", Kind: "ClusterRoleBinding", DeprecatedIn: Version{1, 17}, RemovedIn: Version{1, 22}, Replacement: "rbac.authorization.k8s.io/v1"},
+	{APIVersion: "rbac.authorization.k8s.io/v1beta1", Kind: "Role", DeprecatedIn: Version{1, 17}, RemovedIn: Version{1, 22}, Replacement: "rbac.authorization.k8s.io/v1"},
+	{APIVersion: "rbac.authorization.k8s.io/v1beta1", Kind: "RoleBinding", DeprecatedIn: Version{1, 17}, RemovedIn: Version{1, 22}, Replacement: "rbac.authorization.k8s.io/v1"},
+	{APIVersion: "scheduling.k8s.io/v1beta1", Kind: "PriorityClass", DeprecatedIn: Version{1, 14}, RemovedIn: Version{1, 22}, Replacement: "scheduling.k8s.io/v1"},
+	{APIVersion: "storage.k8s.io/v1beta1", Kind: "CSIDriver", DeprecatedIn: Version{1, 19}, RemovedIn: Version{1, 22}, Replacement: "storage.k8s.io/v1"},
+	{APIVersion: "storage.k8s.io/v1beta1", Kind: "CSINode", DeprecatedIn: Version{1, 17}, RemovedIn: Version{1, 22}, Replacement: "storage.k8s.io/v1"},
+	{APIVersion: "storage.k8s.io/v1beta1", Kind: "StorageClass", DeprecatedIn: Version{1, 19}, RemovedIn: Version{1, 22}, Replacement: "storage.k8s.io/v1"},
+	{APIVersion: "storage.k8s.io/v1beta1", Kind: "VolumeAttachment", DeprecatedIn: Version{1, 19}, RemovedIn: Version{1, 22}, Replacement: "storage.k8s.io/v1"},
+
+	// Removed in v1.25
+	{APIVersion: "batch/v1beta1", Kind: "CronJob", DeprecatedIn: Version{1, 21}, RemovedIn: Version{1, 25}, Replacement: "batch/v1"},
+	{APIVersion: "discovery.k8s.io/v1beta1", Kind: "EndpointSlice", DeprecatedIn: Version{1, 21}, RemovedIn: Version{1, 25}, Replacement: "discovery.k8s.io/v1"},
+	{APIVersion: "events.k8s.io/v1beta1", Kind: "Event", DeprecatedIn: Version{1, 19}, RemovedIn: Version{1, 25}, Replacement: "events.k8s.io/v1"},
+	{APIVersion: "autoscaling/v2beta1", Kind: "HorizontalPodAutoscaler", DeprecatedIn: Version{1, 22}, RemovedIn: Version{1, 25}, Replacement: "autoscaling/v2"},
+	{APIVersion: "policy/v1beta1", Kind: "PodDisruptionBudget", DeprecatedIn: Version{1, 21}, RemovedIn: Version{1, 25}, Replacement: "policy/v1"},
+	{APIVersion: "policy/v1beta1", Kind: "PodSecurityPolicy", DeprecatedIn: Version{1, 21}, RemovedIn: Version{1, 25}, Replacement: "Pod Security Admission"},
+	{APIVersion: "node.k8s.io/v1beta1", Kind: "RuntimeClass", DeprecatedIn: Version{1, 20}, RemovedIn: Version{1, 25}, Replacement: "node.k8s.io/v1"},
+
+	// Removed in v1.26
+	{APIVersion: "flowcontrol.apiserver.k8s.io/v1beta1", Kind: "FlowSchema", DeprecatedIn: Version{1, 23}, RemovedIn: Version{1, 26}, Replacement: "flowcontrol.apiserver.k8s.io/v1beta3"},
+	{APIVersion: "flowcontrol.apiserver.k8s.io/v1beta1", Kind: "PriorityLevelConfiguration", DeprecatedIn: Version{1, 23}, RemovedIn: Version{1, 26}, Replacement: "flowcontrol.apiserver.k8s.io/v1beta3"},
+	{APIVersion: "autoscaling/v2beta2", Kind: "HorizontalPodAutoscaler", DeprecatedIn: Version{1, 23}, RemovedIn: Version{1, 26}, Replacement: "autoscaling/v2"},
+
+	// Removed in v1.27
+	{APIVersion: "storage.k8s.io/v1beta1", Kind: "CSIStorageCapacity", DeprecatedIn: Version{1, 24}, RemovedIn: Version{1, 27}, Replacement: "storage.k8s.io/v1"},
+
+	// Removed in v1.29
+	{APIVersion: "flowcontrol.apiserver.k8s.io/v1beta2", Kind: "FlowSchema", DeprecatedIn: Version{1, 26}, RemovedIn: Version{1, 29}, Replacement: "flowcontrol.apiserver.k8s.io/v1"},
+	{APIVersion: "flowcontrol.apiserver.k8s.io/v1beta2", Kind: "PriorityLevelConfiguration", DeprecatedIn: Version{1, 26}, RemovedIn: Version{1, 29}, Replacement: "flowcontrol.apiserver.k8s.io/v1"},
+
+	// Removed in v1.32
+	{APIVersion: "flowcontrol.apiserver.k8s.io/v1beta3", Kind: "FlowSchema", DeprecatedIn: Version{1, 29}, RemovedIn: Version{1, 32}, Replacement: "flowcontrol.apiserver.k8s.io/v1"},
+	{APIVersion: "flowcontrol.apiserver.k8s.io/v1beta3", Kind: "PriorityLevelConfiguration", DeprecatedIn: Version{1, 29}, RemovedIn: Version{1, 32}, Replacement: "flowcontrol.apiserver.k8s.io/v1"},
+}
+
+// Finding is an exported object, or its last-applied configuration, using a deprecated API
+type Finding struct {
+	Deprecation
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	File      string `json:"file"`
+	Source    string `json:"source"`
+	Removed   bool   `json:"removed"`
+}
+
+// lookup returns the deprecation of an API version and Kind, if any
+func lookup(apiVersion, kind string) (Deprecation, bool) {
+	for _, deprecation := range Deprecations {
+		if deprecation.APIVersion == apiVersion && deprecation.Kind == kind {
+			return deprecation, true
+		}
+	}
+	return Deprecation{}, false
+}
+
+// applies reports whether a deprecation is in effect at the target release; every known
+// deprecation applies when no target is given
+func (d Deprecation) applies(target Version) bool {
+	return target.IsZero() || !target.Before(d.DeprecatedIn)
+}
+
+// removedAt reports whether the API version is no longer served at the target release
+func (d Deprecation) removedAt(target Version) bool {
+	return !d.RemovedIn.IsZero() && !target.IsZero() && !target.Before(d.RemovedIn)
+}
+
+// Check returns the findings of an exported object: its own apiVersion, and the apiVersion
+// of its last-applied configuration, which a later kubectl apply would reuse
+func Check(object map[string]interface{}, file string, target Version) []Finding {
+	kind, namespace, name, ok := dumper.ObjectIdentity(object)
+	if !ok {
+		return nil
+	}
+
+	var findings []Finding
+	add := func(apiVersion, kind, source string) {
+		deprecation, ok := lookup(apiVersion, kind)
+		if !ok || !deprecation.applies(target) {
+			return
+		}
+		findings = append(findings, Finding{
+			Deprecation: deprecation,
+			Namespace:   namespace,
+			Name:        name,
+			File:        filepath.ToSlash(file),
+			Source:      source,
+			Removed:     deprecation.removedAt(target),
+		})
+	}
+
+	apiVersion, _ := object["apiVersion"].(string)
+	add(apiVersion, kind, SourceObject)
+
+	metadata, _ := object["metadata"].(map[string]interface{})
+	annotations, _ := metadata["annotations"].(map[string]interface{})
+	if lastApplied, ok := annotations[LastAppliedAnnotation].(string); ok {
+		var applied struct {
+			APIVersion string `json:"apiVersion"`
+			Kind       string `json:"kind"`
+		}
+		if json.Unmarshal([]byte(lastApplied), &applied) == nil && applied.Kind != "" {
+			add(applied.APIVersion, applied.Kind, SourceLastApplied)
+		}
+	}
+	return findings
+}
+
+// Scan checks every object of the export in dir against the deprecation table. Status
+// files and copies exported in additional API versions are skipped. Findings are sorted
+// with removed APIs first, then by file.
+func Scan(dir string, target Version) ([]Finding, error) {
+	var findings []Finding
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			// Skip .git, reports and CRD definitions without walking them
+			if rel != "." && !dumper.IsResourceFile(filepath.Join(rel, "_.yaml")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !dumper.IsResourceFile(rel) || dumper.IsStatusFile(rel) || dumper.IsAdditionalVersionFile(rel) {
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", rel, err)
+		}
+		objects, err := dumper.ReadDocuments(data)
+		if err != nil {
+			// Not every file in the directory has to be an exported object
+			return nil
+		}
+		for _, object := range objects {
+			findings = append(findings, Check(object, rel, target)...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan %s: %w", dir, err)
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if a.Removed != b.Removed {
+			return a.Removed
+		}
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Source < b.Source
+	})
+	return findings, nil
+}
+
+// Object names the object of a finding as namespace/name, or name when cluster-scoped
+func (f Finding) Object() string {
+	if f.Namespace == "" {
+		return f.Name
+	}
+	return f.Namespace + "/" + f.Name
+}
+
+// Status describes how the API version stands at the target release
+func (f Finding) Status() string {
+	switch {
+	case f.Removed:
+		return "removed in " + f.RemovedIn.String()
+	case f.RemovedIn.IsZero():
+		return "deprecated in " + f.DeprecatedIn.String()
+	default:
+		return "deprecated in " + f.DeprecatedIn.String() + ", removed in " + f.RemovedIn.String()
+	}
+}
+
+// CountRemoved returns how many findings use an API version removed at the target release
+func CountRemoved(findings []Finding) int {
+	removed := 0
+	for _, finding := range findings {
+		if finding.Removed {
+			removed++
+		}
+	}
+	return removed
+}
@@ -0,0 +1,116 @@
+package deprecations
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseVersion(t *testing.T) {
+	for value, expected := range map[string]Version{
+		"1.29":            {1, 29},
+		"v1.29":           {1, 29},
+		"v1.28.4":         {1, 28},
+		"v1.27.3-gke.100": {1, 27},
+		"v1.26+":          {1, 26},
+	} {
+		version, err := ParseVersion(value)
+		if err != nil {
+			t.Errorf("unexpected error for %q: %v", value, err)
+			continue
+		}
+		if version != expected {
+			t.Errorf("expected %v for %q, got %v", expected, value, version)
+		}
+	}
+	for _, value := range []string{"", "v1", "latest", "v1.x"} {
+		if _, err := ParseVersion(value); err == nil {
+			t.Errorf("expected error for %q", value)
+		}
+	}
+}
+
+func TestVersionNext(t *testing.T) {
+	if next := (Version{1, 29}).Next(); next != (Version{1, 30}) {
+		t.Errorf("expected v1.30 after v1.29, got %s", next)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	object := map[string]interface{}{
+		"apiVersion": "autoscaling/v2",
+		"kind":       "HorizontalPodAutoscaler",
+		"metadata": map[string]interface{}{
+			"name":      "web",
+			"namespace": "default",
+			"annotations": map[string]interface{}{
+				LastAppliedAnnotation: `{"apiVersion":"autoscaling/v2beta2","kind":"HorizontalPodAutoscaler","metadata":{"name":"web"}}`,
+			},
+		},
+	}
+
+	findings := Check(object, "default/HorizontalPodAutoscaler/web.yaml", Version{1, 26})
+	if len(findings) != 1 {
+		t.Fatalf("expected only the last-applied configuration to be reported, got %+v", findings)
+	}
+	finding := findings[0]
+	if finding.Source != SourceLastApplied || finding.APIVersion != "autoscaling/v2beta2" || !finding.Removed {
+		t.Errorf("unexpected finding: %+v", finding)
+	}
+	if finding.Object() != "default/web" || finding.Status() != "removed in v1.26" {
+		t.Errorf("unexpected description: %s, %s", finding.Object(), finding.Status())
+	}
+
+	// Deprecated but still served before the removal
+	findings = Check(object, "web.yaml", Version{1, 24})
+	if len(findings) != 1 || findings[0].Removed || findings[0].Status() != "deprecated in v1.23, removed in v1.26" {
+		t.Errorf("expected a deprecation at v1.24, got %+v", findings)
+	}
+
+	// Not yet deprecated at the target
+	if findings := Check(object, "web.yaml", Version{1, 22}); len(findings) != 0 {
+		t.Errorf("expected no findings at v1.22, got %+v", findings)
+	}
+
+	// Every known deprecation without a target
+	if findings := Check(object, "web.yaml", Version{}); len(findings) != 1 || findings[0].Removed {
+		t.Errorf("expected an unscoped deprecation, got %+v", findings)
+	}
+}
+
+func TestScan(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"default/CronJob/backup.yaml":         "apiVersion: batch/v1beta1\nkind: CronJob\nmetadata:\n  name: backup\n  namespace: default\n",
+		"default/CronJob/backup.status.yaml":  "apiVersion: batch/v1beta1\nkind: CronJob\nmetadata:\n  name: backup\n  namespace: default\nstatus: {}\n",
+		"default/CronJob/backup@v1beta1.yaml": "apiVersion: batch/v1beta1\nkind: CronJob\nmetadata:\n  name: backup\n  namespace: default\n",
+		"default/Ingress/web.yaml":            "apiVersion: networking.k8s.io/v1beta1\nkind: Ingress\nmetadata:\n  name: web\n  namespace: default\n",
+		"default/ConfigMap/app.yaml":          "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: app\n  namespace: default\n",
+		"kalco-reports/old.yaml":              "apiVersion: batch/v1beta1\nkind: CronJob\nmetadata:\n  name: old\n",
+		"_crds/x/definition.yaml":             "apiVersion: apiextensions.k8s.io/v1beta1\nkind: CustomResourceDefinition\nmetadata:\n  name: x\n",
+	}
+	for rel, content := range files {
+		path := filepath.Join(dir, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	findings, err := Scan(dir, Version{1, 23})
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if len(findings) != 2 {
+		t.Fatalf("expected the CronJob and the Ingress, got %+v", findings)
+	}
+	// Removed APIs come first
+	if findings[0].Kind != "Ingress" || !findings[0].Removed || findings[0].File != "default/Ingress/web.yaml" {
+		t.Errorf("expected the removed Ingress first, got %+v", findings[0])
+	}
+	if findings[1].Kind != "CronJob" || findings[1].Removed || CountRemoved(findings) != 1 {
+		t.Errorf("expected the deprecated CronJob, got %+v", findings[1])
+	}
+}
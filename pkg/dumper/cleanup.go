@@ -0,0 +1,259 @@
+package dumper
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// DefaultCleanupProfile is the profile used when none is configured
+const DefaultCleanupProfile = "reapply"
+
+// CleanupRule removes fields from objects of matching API group and Kind.
+// Group and Kind accept globs; empty matches everything and "core" matches the core group.
+// Fields use a JSONPath-style syntax: "metadata.uid", "spec.containers[*].image",
+// or `metadata.annotations["kubectl.kubernetes.io/last-applied-configuration"]`.
+type CleanupRule struct {
+	Group  string   `yaml:"group,omitempty"`
+	Kind   string   `yaml:"kind,omitempty"`
+	Fields []string `yaml:"fields"`
+}
+
+// CleanupProfile is a named set of cleanup rules applied to every exported object
+type CleanupProfile struct {
+	Name    string        `yaml:"name"`
+	Extends string        `yaml:"extends,omitempty"`
+	Rules   []CleanupRule `yaml:"rules"`
+}
+
+// builtinCleanupProfiles holds the profiles shipped with kalco
+var builtinCleanupProfiles = map[string]*CleanupProfile{
+	// minimal strips only fields populated by the API server, keeping ownerReferences
+	"minimal": {
+		Name: "minimal",
+		Rules: []CleanupRule{
+			{Fields: []string{
+				"metadata.uid",
+				"metadata.resourceVersion",
+				"metadata.generation",
+				"metadata.creationTimestamp",
+				"metadata.managedFields",
+				"status",
+			}},
+		},
+	},
+	// reapply additionally drops ownerReferences so objects can be applied to a new cluster
+	"reapply": {
+		Name:    "reapply",
+		Extends: "minimal",
+		Rules: []CleanupRule{
+			{Fields: []string{"metadata.ownerReferences"}},
+		},
+	},
+	// full also removes controller bookkeeping and allocated values that churn between clusters
+	"full": {
+		Name:    "full",
+		Extends: "reapply",
+		Rules: []CleanupRule{
+			{Fields: []string{
+				`metadata.annotations["kubectl.kubernetes.io/last-applied-configuration"]`,
+				`metadata.annotations["deployment.kubernetes.io/revision"]`,
+			}},
+			{Group: "core", Kind: "Service", Fields: []string{
+				"spec.clusterIP",
+				"spec.clusterIPs",
+			}},
+			{Group: "core", Kind: "PersistentVolumeClaim", Fields: []string{
+				`metadata.annotations["pv.kubernetes.io/bind-completed"]`,
+				`metadata.annotations["pv.kubernetes.io/bound-by-controller"]`,
+			}},
+		},
+	},
+}
+
+// BuiltinCleanupProfiles returns the names of the built-in cleanup profiles
+func BuiltinCleanupProfiles() []string {
+	return []string{"minimal", "reapply", "full"}
+}
+
+// LoadCleanupProfile resolves a built-in profile name or loads a profile from a YAML file.
+// Profiles may extend another profile by name; its rules are applied first.
+func LoadCleanupProfile(nameOrPath string) (*CleanupProfile, error) {
+	return loadCleanupProfile(nameOrPath, map[string]bool{})
+}
+
+// loadCleanupProfile resolves a profile and its parents, guarding against cycles
+func loadCleanupProfile(nameOrPath string, seen map[string]bool) (*CleanupProfile, error) {
+	if nameOrPath == "" {
+		nameOrPath = DefaultCleanupProfile
+	}
+	if seen[nameOrPath] {
+		return nil, fmt.Errorf("cleanup profile '%s' extends itself", nameOrPath)
+	}
+	seen[nameOrPath] = true
+
+	profile, builtin := builtinCleanupProfiles[nameOrPath]
+	if !builtin {
+		data, err := os.ReadFile(nameOrPath)
+		if err != nil {
+			return nil, fmt.Errorf("unknown cleanup profile '%s' (built-in profiles: %s)", nameOrPath, strings.Join(BuiltinCleanupProfiles(), ", "))
+		}
+		profile = &CleanupProfile{}
+		if err := yaml.Unmarshal(data, profile); err != nil {
+			return nil, fmt.Errorf("failed to parse cleanup profile '%s': %w", nameOrPath, err)
+		}
+		if profile.Name == "" {
+			profile.Name = nameOrPath
+		}
+	}
+
+	// Validate field paths up front so typos fail before the export starts
+	for _, rule := range profile.Rules {
+		for _, field := range rule.Fields {
+			if _, err := parseFieldPath(field); err != nil {
+				return nil, fmt.Errorf("cleanup profile '%s': %w", profile.Name, err)
+			}
+		}
+	}
+
+	if profile.Extends == "" {
+		return profile, nil
+	}
+
+	parent, err := loadCleanupProfile(profile.Extends, seen)
+	if err != nil {
+		return nil, err
+	}
+	return &CleanupProfile{
+		Name:  profile.Name,
+		Rules: append(append([]CleanupRule{}, parent.Rules...), profile.Rules...),
+	}, nil
+}
+
+// SetCleanupProfile sets the cleanup profile applied to exported objects
+func (d *Dumper) SetCleanupProfile(profile *CleanupProfile) {
+	d.cleanup = profile
+}
+
+// Apply removes the fields selected by the profile's matching rules from the object
+func (p *CleanupProfile) Apply(item *unstructured.Unstructured) {
+	if p == nil {
+		return
+	}
+
+	gvk := item.GroupVersionKind()
+	for _, rule := range p.Rules {
+		if !rule.matches(gvk.Group, gvk.Kind) {
+			continue
+		}
+		for _, field := range rule.Fields {
+			segments, err := parseFieldPath(field)
+			if err != nil {
+				continue
+			}
+			removeField(item.Object, segments)
+		}
+	}
+}
+
+// matches reports whether the rule applies to the given API group and Kind
+func (r CleanupRule) matches(group, kind string) bool {
+	if r.Group != "" {
+		pattern := r.Group
+		if pattern == "core" {
+			pattern = ""
+		}
+		if matched, _ := path.Match(pattern, group); !matched {
+			return false
+		}
+	}
+	if r.Kind != "" {
+		if matched, _ := path.Match(r.Kind, kind); !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// wildcardSegment selects every element of a list or every value of a map
+const wildcardSegment = "[*]"
+
+// parseFieldPath splits a JSONPath-style field expression into its segments
+func parseFieldPath(field string) ([]string, error) {
+	expr := strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(field), "$"), ".")
+	if expr == "" {
+		return nil, fmt.Errorf("empty field path")
+	}
+
+	var segments []string
+	for len(expr) > 0 {
+		switch {
+		case strings.HasPrefix(expr, "[*]"):
+			segments = append(segments, wildcardSegment)
+			expr = expr[3:]
+		case strings.HasPrefix(expr, `["`) || strings.HasPrefix(expr, `['`):
+			quote := expr[1:2]
+			end := strings.Index(expr[2:], quote+"]")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated key in field path '%s'", field)
+			}
+			segments = append(segments, expr[2:2+end])
+			expr = expr[2+end+2:]
+		case strings.HasPrefix(expr, "."):
+			expr = expr[1:]
+		default:
+			end := strings.IndexAny(expr, ".[")
+			if end < 0 {
+				end = len(expr)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("invalid field path '%s'", field)
+			}
+			segments = append(segments, expr[:end])
+			expr = expr[end:]
+		}
+	}
+	return segments, nil
+}
+
+// removeField deletes the field addressed by segments. Maps left empty by the removal
+// (e.g. annotations) are dropped as well so they do not show up as "{}".
+func removeField(node interface{}, segments []string) {
+	if len(segments) == 0 {
+		return
+	}
+	key, rest := segments[0], segments[1:]
+
+	switch value := node.(type) {
+	case map[string]interface{}:
+		if key == wildcardSegment {
+			for _, child := range value {
+				removeField(child, rest)
+			}
+			return
+		}
+		child, exists := value[key]
+		if !exists {
+			return
+		}
+		if len(rest) == 0 {
+			delete(value, key)
+			return
+		}
+		removeField(child, rest)
+		if childMap, ok := child.(map[string]interface{}); ok && len(childMap) == 0 {
+			delete(value, key)
+		}
+	case []interface{}:
+		if key != wildcardSegment {
+			return
+		}
+		for _, child := range value {
+			removeField(child, rest)
+		}
+	}
+}
@@ -0,0 +1,387 @@
+package dumper
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/openapi"
+)
+
+// CRDDir is the top-level directory holding the CustomResourceDefinitions of exported
+// custom resources, with the OpenAPI v3 schema of every served version
+const CRDDir = "_crds"
+
+// crdDefinitionFile is the name of the CRD object within its directory, before the format extension
+const crdDefinitionFile = "definition.yaml"
+
+// crdResource is the resource CustomResourceDefinitions are listed from
+var crdResource = schema.GroupVersionResource{Group: "apiextensions.k8s.io", Version: "v1", Resource: "customresourcedefinitions"}
+
+// ManifestCRD records a CustomResourceDefinition captured in the _crds directory
+type ManifestCRD struct {
+	Name           string       `json:"name"`
+	Group          string       `json:"group"`
+	Kind           string       `json:"kind"`
+	Generation     int64        `json:"generation"`
+	StorageVersion string       `json:"storageVersion"`
+	Versions       []CRDVersion `json:"versions"`
+	File           string       `json:"file"`
+	SHA256         string       `json:"sha256"`
+}
+
+// CRDVersion is a version of a CRD and the file holding its OpenAPI v3 schema, when served
+type CRDVersion struct {
+	Name    string `json:"name"`
+	Served  bool   `json:"served"`
+	Storage bool   `json:"storage"`
+	Schema  string `json:"schema,omitempty"`
+}
+
+// servesCRDs reports whether discovery found the CustomResourceDefinition API
+func servesCRDs(resourceLists []*metav1.APIResourceList) bool {
+	for _, resourceList := range resourceLists {
+		if resourceList.GroupVersion != crdResource.GroupVersion().String() {
+			continue
+		}
+		for _, resource := range resourceList.APIResources {
+			if resource.Name == crdResource.Resource {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// exportCRDs writes the definition of every CRD whose Kind the export covered into
+// <outputDir>/_crds/<name>/, with the OpenAPI v3 schema of each served version fetched
+// via discovery. When CRDs cannot be listed a warning is printed and the previous _crds
+// directory is kept; a missing schema only leaves out its file.
+func (d *Dumper) exportCRDs(ctx context.Context, outputDir string) error {
+	log := &taskLog{}
+	defer log.flush(d.outputCallback)
+
+	crds := make(map[string]unstructured.Unstructured)
+	err := d.listPages(ctx, d.dynamicClient.Resource(crdResource), log, func(items []unstructured.Unstructured) error {
+		for _, item := range items {
+			crds[item.GetName()] = item
+		}
+		return nil
+	})
+	if err != nil {
+		if ctx.Err() == nil && !apierrors.IsNotFound(err) {
+			log.add("WARNING", fmt.Sprintf("%s - failed to list CustomResourceDefinitions, keeping the previous definitions: %v", CRDDir, err))
+		}
+		return nil
+	}
+	d.run.crdsListed = true
+
+	names := make([]string, 0, len(crds))
+	for name, crd := range crds {
+		group, _, _ := unstructured.NestedString(crd.Object, "spec", "group")
+		kind, _, _ := unstructured.NestedString(crd.Object, "spec", "names", "kind")
+		if d.run.planned[d.kindDir(group, kind)] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	if len(names) == 0 {
+		return nil
+	}
+
+	schemas := newSchemaCache(d)
+	for _, name := range names {
+		crd := crds[name]
+		captured, err := d.writeCRD(outputDir, crd, schemas)
+		if err != nil {
+			return err
+		}
+		d.run.crds = append(d.run.crds, captured)
+	}
+	for _, message := range schemas.warnings {
+		log.add("WARNING", message)
+	}
+	return nil
+}
+
+// writeCRD writes a CRD and the schemas of its served versions and returns its manifest record
+func (d *Dumper) writeCRD(outputDir string, crd unstructured.Unstructured, schemas *schemaCache) (ManifestCRD, error) {
+	group, _, _ := unstructured.NestedString(crd.Object, "spec", "group")
+	kind, _, _ := unstructured.NestedString(crd.Object, "spec", "names", "kind")
+	captured := ManifestCRD{
+		Name:       crd.GetName(),
+		Group:      group,
+		Kind:       kind,
+		Generation: crd.GetGeneration(),
+	}
+	dir := filepath.Join(CRDDir, EscapeName(crd.GetName()))
+
+	versions, _, _ := unstructured.NestedSlice(crd.Object, "spec", "versions")
+	for _, value := range versions {
+		version, _ := value.(map[string]interface{})
+		name, _ := version["name"].(string)
+		served, _ := version["served"].(bool)
+		storage, _ := version["storage"].(bool)
+		entry := CRDVersion{Name: name, Served: served, Storage: storage}
+		if storage {
+			captured.StorageVersion = name
+		}
+
+		// Discovery only publishes schemas of served versions
+		if data, ok := schemas.kindSchema(group, name, kind); served && ok {
+			entry.Schema = filepath.ToSlash(filepath.Join(dir, EscapeName(name)+".openapi.json"))
+			if err := writeFile(filepath.Join(outputDir, filepath.FromSlash(entry.Schema)), data); err != nil {
+				return captured, fmt.Errorf("failed to write schema of %s: %w", crd.GetName(), err)
+			}
+		}
+		captured.Versions = append(captured.Versions, entry)
+	}
+
+	d.cleanup.Apply(&crd)
+	data, err := d.format.Encode(crd.Object)
+	if err != nil {
+		return captured, err
+	}
+	rel := d.format.withExtension(filepath.Join(dir, crdDefinitionFile))
+	if err := writeFile(filepath.Join(outputDir, rel), data); err != nil {
+		return captured, fmt.Errorf("failed to write %s: %w", crd.GetName(), err)
+	}
+	sum := sha256.Sum256(data)
+	captured.File = filepath.ToSlash(rel)
+	captured.SHA256 = hex.EncodeToString(sum[:])
+	return captured, nil
+}
+
+// schemaCache fetches the OpenAPI v3 document of each API group version once
+type schemaCache struct {
+	d         *Dumper
+	paths     map[string]openapi.GroupVersion
+	documents map[string]*openAPIDocument
+	warnings  []string
+	failed    bool
+}
+
+// openAPIDocument is the part of an OpenAPI v3 document kalco reads
+type openAPIDocument struct {
+	OpenAPI    string `json:"openapi"`
+	Components struct {
+		Schemas map[string]interface{} `json:"schemas"`
+	} `json:"components"`
+}
+
+// newSchemaCache creates an empty cache; the discovery paths are fetched on first use
+func newSchemaCache(d *Dumper) *schemaCache {
+	return &schemaCache{d: d, documents: make(map[string]*openAPIDocument)}
+}
+
+// document returns the OpenAPI v3 document of a group version, or nil when unavailable
+func (c *schemaCache) document(group, version string) *openAPIDocument {
+	if c.paths == nil && !c.failed {
+		paths, err := c.d.discoveryClient.OpenAPIV3().Paths()
+		if err != nil {
+			c.failed = true
+			c.warnings = append(c.warnings, fmt.Sprintf("%s - OpenAPI v3 discovery failed, schemas are not captured: %v", CRDDir, err))
+			return nil
+		}
+		c.paths = paths
+	}
+
+	path := "apis/" + group + "/" + version
+	if doc, ok := c.documents[path]; ok {
+		return doc
+	}
+	var doc *openAPIDocument
+	if gv, ok := c.paths[path]; ok {
+		data, err := gv.Schema("application/json")
+		if err == nil {
+			doc = &openAPIDocument{}
+			err = json.Unmarshal(data, doc)
+		}
+		if err != nil {
+			doc = nil
+			c.warnings = append(c.warnings, fmt.Sprintf("%s - failed to fetch OpenAPI v3 schema of %s: %v", CRDDir, path, err))
+		}
+	}
+	c.documents[path] = doc
+	return doc
+}
+
+// kindSchema returns a standalone OpenAPI v3 document holding the schema of a Kind at a
+// version and every component it references, as canonical JSON
+func (c *schemaCache) kindSchema(group, version, kind string) ([]byte, bool) {
+	doc := c.document(group, version)
+	if doc == nil {
+		return nil, false
+	}
+
+	root := ""
+	for name, value := range doc.Components.Schemas {
+		if servesKind(value, group, version, kind) {
+			root = name
+			break
+		}
+	}
+	if root == "" {
+		return nil, false
+	}
+
+	components := make(map[string]interface{})
+	collectSchemas(root, doc.Components.Schemas, components)
+	standalone := map[string]interface{}{
+		"openapi":    doc.OpenAPI,
+		"info":       map[string]interface{}{"title": root, "version": version},
+		"paths":      map[string]interface{}{},
+		"components": map[string]interface{}{"schemas": components},
+	}
+
+	var out bytes.Buffer
+	encoder := json.NewEncoder(&out)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(standalone); err != nil {
+		return nil, false
+	}
+	return out.Bytes(), true
+}
+
+// servesKind reports whether a component schema is the schema of a group, version and Kind
+func servesKind(value interface{}, group, version, kind string) bool {
+	component, _ := value.(map[string]interface{})
+	gvks, _ := component["x-kubernetes-group-version-kind"].([]interface{})
+	for _, item := range gvks {
+		gvk, _ := item.(map[string]interface{})
+		if gvk["group"] == group && gvk["version"] == version && gvk["kind"] == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// collectSchemas adds a component and, transitively, the components it references to out
+func collectSchemas(name string, schemas, out map[string]interface{}) {
+	if _, ok := out[name]; ok {
+		return
+	}
+	value, ok := schemas[name]
+	if !ok {
+		return
+	}
+	out[name] = value
+
+	var walk func(interface{})
+	walk = func(value interface{}) {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			for key, item := range v {
+				if ref, ok := item.(string); ok && key == "$ref" {
+					collectSchemas(strings.TrimPrefix(ref, "#/components/schemas/"), schemas, out)
+					continue
+				}
+				walk(item)
+			}
+		case []interface{}:
+			for _, item := range v {
+				walk(item)
+			}
+		}
+	}
+	walk(value)
+}
+
+// carryCRDs copies the CRD directories of the previous export that the current export did
+// not write and must keep: all of them when CRDs could not be listed, otherwise those of
+// Kinds whose files are not pruned (filtered out or failed). It returns their records.
+func (d *Dumper) carryCRDs(stagingDir, outputDir string) ([]ManifestCRD, error) {
+	entries, err := os.ReadDir(filepath.Join(outputDir, CRDDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", CRDDir, err)
+	}
+
+	previous := make(map[string]ManifestCRD)
+	if manifest, err := ReadManifest(outputDir); err == nil {
+		for _, crd := range manifest.CRDs {
+			previous[crd.Name] = crd
+		}
+	}
+
+	var carried []ManifestCRD
+	for _, entry := range entries {
+		rel := filepath.Join(CRDDir, entry.Name())
+		if !entry.IsDir() {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(stagingDir, rel)); err == nil {
+			continue
+		}
+
+		crd, ok := previous[UnescapeName(entry.Name())]
+		if !ok {
+			crd, ok = readCRDRecord(outputDir, rel)
+		}
+		if d.run.crdsListed && ok && d.canPruneKind(d.kindDir(crd.Group, crd.Kind)) {
+			continue
+		}
+		if err := copyDir(filepath.Join(outputDir, rel), filepath.Join(stagingDir, rel)); err != nil {
+			return nil, fmt.Errorf("failed to preserve %s: %w", rel, err)
+		}
+		if ok {
+			carried = append(carried, crd)
+		}
+	}
+	return carried, nil
+}
+
+// readCRDRecord rebuilds the record of a CRD directory from its definition file
+func readCRDRecord(outputDir, rel string) (ManifestCRD, bool) {
+	for _, format := range []Format{FormatYAML, FormatJSON} {
+		file := format.withExtension(filepath.Join(rel, crdDefinitionFile))
+		data, err := os.ReadFile(filepath.Join(outputDir, file))
+		if err != nil {
+			continue
+		}
+		objects, err := ReadDocuments(data)
+		if err != nil || len(objects) != 1 {
+			return ManifestCRD{}, false
+		}
+		crd := unstructured.Unstructured{Object: objects[0]}
+		group, _, _ := unstructured.NestedString(crd.Object, "spec", "group")
+		kind, _, _ := unstructured.NestedString(crd.Object, "spec", "names", "kind")
+		sum := sha256.Sum256(data)
+		return ManifestCRD{
+			Name:   crd.GetName(),
+			Group:  group,
+			Kind:   kind,
+			File:   filepath.ToSlash(file),
+			SHA256: hex.EncodeToString(sum[:]),
+		}, group != "" && kind != ""
+	}
+	return ManifestCRD{}, false
+}
+
+// copyDir copies a directory tree
+func copyDir(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, entry os.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		return copyFile(path, filepath.Join(dst, rel))
+	})
+}
@@ -2,13 +2,15 @@ package dumper
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
-	"gopkg.in/yaml.v3"
-	corev1 "k8s.io/api/core/v1"
+	"filippo.io/age"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -26,16 +28,45 @@ type Dumper struct {
 	discoveryClient discovery.DiscoveryInterface
 	dynamicClient   dynamic.Interface
 	outputCallback  OutputCallback
+	filter          ResourceFilter
+	skipResources   []string
+	concurrency     int
+	pageSize        int64
+
+	secretMode       SecretMode
+	secretRecipients []age.Recipient
+	secretHashKey    []byte
+	cleanup          *CleanupProfile
+	statusMode       StatusMode
+	layout           Layout
+	format           Format
+
+	versionPins        map[string]string
+	additionalVersions []schema.GroupVersion
+
+	referenceDir string
+	run          *exportRun
 }
 
 // NewDumper creates a new Dumper instance
 func NewDumper(clientset kubernetes.Interface, discoveryClient discovery.DiscoveryInterface) *Dumper {
+	// The built-in default profile always resolves
+	cleanup, _ := LoadCleanupProfile(DefaultCleanupProfile)
+
 	// We need to pass the config from the kube package, so we'll create a new function signature
 	// For now, we'll create a dummy dynamic client that will be set later
 	return &Dumper{
 		clientset:       clientset,
 		discoveryClient: discoveryClient,
 		dynamicClient:   nil, // Will be set by SetDynamicClient
+		skipResources:   DefaultSkipResources,
+		concurrency:     DefaultConcurrency,
+		pageSize:        DefaultPageSize,
+		secretMode:      SecretsInclude,
+		cleanup:         cleanup,
+		statusMode:      StatusNone,
+		layout:          treeLayout{},
+		format:          FormatYAML,
 	}
 }
 
@@ -49,45 +80,162 @@ func (d *Dumper) SetOutputCallback(callback OutputCallback) {
 	d.outputCallback = callback
 }
 
-// DumpAllResources performs the main task of dumping all resources
-func (d *Dumper) DumpAllResources(outputDir string) error {
+// SetFilter sets the namespace and resource filter applied during export
+func (d *Dumper) SetFilter(filter ResourceFilter) {
+	d.filter = filter
+}
+
+// SetConcurrency sets the number of list operations executed in parallel
+func (d *Dumper) SetConcurrency(workers int) {
+	if workers < 1 {
+		workers = 1
+	}
+	d.concurrency = workers
+}
+
+// SetPageSize sets the number of objects requested per List call (0 disables paging)
+func (d *Dumper) SetPageSize(size int64) {
+	if size < 0 {
+		size = 0
+	}
+	d.pageSize = size
+}
+
+// DumpAllResources performs the main task of dumping all resources and returns
+// the per-resource outcome of the export
+func (d *Dumper) DumpAllResources(ctx context.Context, outputDir string) (*ExportResult, error) {
 	// Create output directory if it doesn't exist
 	if err := os.MkdirAll(outputDir, 0755); err != nil {
-		return fmt.Errorf("failed to create output directory: %w", err)
+		return nil, fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	d.run = newExportRun(outputDir)
+	if len(d.additionalVersions) > 0 && d.layout.Aggregated() {
+		return nil, fmt.Errorf("additional API versions require a per-object layout (%s or %s)", LayoutTree, LayoutFlat)
 	}
 
 	// Get all server resources
-	resourceLists, err := d.discoveryClient.ServerPreferredResources()
+	resourceLists, additionalLists, err := d.discoverResources()
 	if err != nil {
-		return fmt.Errorf("failed to get server resources: %w", err)
+		return nil, err
+	}
+	d.run.kindDirs = kindDirectories(resourceLists)
+	if info, err := d.discoveryClient.ServerVersion(); err == nil {
+		d.run.clusterVersion = info.GitVersion
 	}
 
 	// Get all namespaces for namespaced resources
-	namespaces, err := d.clientset.CoreV1().Namespaces().List(context.Background(), metav1.ListOptions{})
+	namespaces, err := d.clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
 	if err != nil {
-		return fmt.Errorf("failed to list namespaces: %v", err)
+		return nil, fmt.Errorf("failed to list namespaces: %v", err)
+	}
+
+	// Keep only the namespaces selected by the filter
+	var selectedNamespaces []string
+	for _, namespace := range namespaces.Items {
+		if d.filter.IncludesNamespace(namespace.Name) {
+			selectedNamespaces = append(selectedNamespaces, namespace.Name)
+		}
 	}
 
-	// Process each resource group
-	processedGroups := 0
+	// Plan the work for each resource group, then execute it on the worker pool
+	var tasks []dumpTask
 	for _, resourceList := range resourceLists {
-		if err := d.processResourceGroup(resourceList, namespaces.Items, outputDir); err != nil {
-			continue
+		tasks = append(tasks, d.processResourceGroup(resourceList, selectedNamespaces)...)
+	}
+	for _, resourceList := range additionalLists {
+		d.run.additional[resourceList.GroupVersion] = true
+		tasks = append(tasks, d.processResourceGroup(resourceList, selectedNamespaces)...)
+	}
+	for _, task := range tasks {
+		d.run.recordPlanned(d.kindDir(task.gvr.Group, task.resource.Kind))
+	}
+	d.runTasks(ctx, groupTasks(tasks), outputDir)
+
+	// Capture the definitions and schemas of the exported custom resources
+	if servesCRDs(resourceLists) {
+		if err := d.exportCRDs(ctx, outputDir); err != nil {
+			return nil, err
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("export interrupted: %w", err)
+	}
+
+	// Aggregated layouts are written once every object is known
+	if err := d.run.writeDocuments(d.format); err != nil {
+		return nil, err
+	}
+	if err := d.writeManifest(outputDir, nil, nil); err != nil {
+		return nil, err
+	}
+
+	result := d.run.results.finish()
+	result.Namespaces = len(selectedNamespaces)
+	return result, nil
+}
+
+// PlannedResources returns the resources an export with the current settings would list,
+// after skipping non-listable, ephemeral, filtered and omitted resources
+func (d *Dumper) PlannedResources() ([]schema.GroupVersionResource, error) {
+	d.run = newExportRun("")
+	resourceLists, additionalLists, err := d.discoverResources()
+	if err != nil {
+		return nil, err
+	}
+
+	var gvrs []schema.GroupVersionResource
+	for _, resourceList := range append(resourceLists, additionalLists...) {
+		for _, task := range d.processResourceGroup(resourceList, nil) {
+			gvrs = append(gvrs, task.gvr)
+		}
+	}
+	return gvrs, nil
+}
+
+// discoverResources returns the preferred version of every API resource, or its pinned
+// version, and the resources of the additional versions. A failing aggregated API only
+// drops its own group: it is recorded and the remaining groups are returned.
+func (d *Dumper) discoverResources() ([]*metav1.APIResourceList, []*metav1.APIResourceList, error) {
+	resourceLists, err := d.discoveryClient.ServerPreferredResources()
+	if err != nil {
+		var groupErr *discovery.ErrGroupDiscoveryFailed
+		if !errors.As(err, &groupErr) {
+			return nil, nil, fmt.Errorf("failed to get server resources: %w", err)
+		}
+		d.recordDiscoveryFailures(groupErr)
+	}
+	return d.resolveVersions(resourceLists)
+}
+
+// recordDiscoveryFailures records and reports API groups that could not be discovered
+func (d *Dumper) recordDiscoveryFailures(groupErr *discovery.ErrGroupDiscoveryFailed) {
+	d.run.recordDiscoveryFailure()
+
+	groupVersions := make([]string, 0, len(groupErr.Groups))
+	causes := make(map[string]error, len(groupErr.Groups))
+	for gv, err := range groupErr.Groups {
+		groupVersions = append(groupVersions, gv.String())
+		causes[gv.String()] = err
+	}
+	sort.Strings(groupVersions)
+
+	for _, gv := range groupVersions {
+		d.run.results.failGroup(gv, causes[gv])
+		if d.outputCallback != nil {
+			d.outputCallback("WARNING", fmt.Sprintf("%s - API group discovery failed, skipping its resources: %v", gv, causes[gv]))
 		}
-		processedGroups++
 	}
-	return nil
 }
 
-// processResourceGroup processes a single API resource group
-func (d *Dumper) processResourceGroup(resourceList *metav1.APIResourceList, namespaces []corev1.Namespace, outputDir string) error {
-	validResources := 0
+// processResourceGroup plans the list operations for a single API resource group
+func (d *Dumper) processResourceGroup(resourceList *metav1.APIResourceList, namespaces []string) []dumpTask {
+	var tasks []dumpTask
 	for _, resource := range resourceList.APIResources {
 		// Skip subresources
 		if strings.Contains(resource.Name, "/") {
 			continue
 		}
-		validResources++
 
 		// Create GVR for the resource
 		// Parse GroupVersion to get Group and Version separately
@@ -113,161 +261,221 @@ func (d *Dumper) processResourceGroup(resourceList *metav1.APIResourceList, name
 			Resource: resource.Name,
 		}
 
-		if resource.Namespaced {
-			// Handle namespaced resources
-			if err := d.dumpNamespacedResources(gvr, resource, namespaces, outputDir); err != nil {
-				// Silent fail for individual resources
-			}
-		} else {
-			// Handle cluster-scoped resources
-			if err := d.dumpClusterScopedResources(gvr, resource, outputDir); err != nil {
-				// Silent fail for individual resources
-			}
+		// Skip resources that cannot be listed, such as TokenReview or Binding
+		if !isListable(resource) {
+			d.run.results.skip(gvr.String(), resource.Kind, "not listable")
+			continue
 		}
-	}
 
-	if validResources > 0 {
-
-	}
-	return nil
-}
-
-// dumpNamespacedResources dumps all instances of a namespaced resource across all namespaces
-func (d *Dumper) dumpNamespacedResources(gvr schema.GroupVersionResource, resource metav1.APIResource, namespaces []corev1.Namespace, outputDir string) error {
-	totalResources := 0
-	for _, namespace := range namespaces {
-		// Create directory structure: <outputDir>/<namespace>/<resource_kind>
-		resourceDir := filepath.Join(outputDir, namespace.Name, resource.Kind)
-		if err := os.MkdirAll(resourceDir, 0755); err != nil {
-			return fmt.Errorf("failed to create resource directory: %w", err)
+		// Skip resources excluded by the filter
+		if !d.filter.IncludesResource(gvr, resource) {
+			d.run.results.skip(gvr.String(), resource.Kind, "excluded by filter")
+			continue
 		}
 
-		// List all resources of this type in the namespace
-		resourceList, err := d.dynamicClient.Resource(gvr).Namespace(namespace.Name).List(context.Background(), metav1.ListOptions{})
-		if err != nil {
-			if d.outputCallback != nil {
-				d.outputCallback("ERROR", fmt.Sprintf("%s/%s - failed to list resources: %v", namespace.Name, resource.Kind, err))
-			}
+		// Skip ephemeral resources such as Events and Leases
+		if d.isSkipped(gvr, resource) {
+			d.run.results.skip(gvr.String(), resource.Kind, "ephemeral")
 			continue
 		}
 
-		if len(resourceList.Items) > 0 {
-
+		// Secrets are not listed at all when they are omitted from the export
+		if d.secretMode == SecretsOmit && isSecretResource(gvr) {
+			d.run.results.skip(gvr.String(), resource.Kind, "secrets omitted")
+			continue
 		}
 
-		// Dump each resource instance
-		for _, item := range resourceList.Items {
-			if err := d.dumpResource(item, resourceDir); err != nil {
-				// Silent fail for individual resources
-			}
-		}
-		totalResources += len(resourceList.Items)
+		tasks = append(tasks, dumpTask{gvr: gvr, resource: resource, namespaces: namespaces})
 	}
+	return tasks
+}
 
-	if totalResources > 0 {
+// runTask executes a single planned list operation and records its outcome
+func (d *Dumper) runTask(ctx context.Context, task dumpTask, outputDir string, log *taskLog) {
+	rec := newResourceRecorder(task)
+	if task.resource.Namespaced {
+		d.dumpNamespacedResources(ctx, task.gvr, task.resource, task.namespaces, outputDir, log, rec)
+	} else {
+		d.dumpClusterScopedResources(ctx, task.gvr, task.resource, outputDir, log, rec)
+	}
 
+	rec.result.Status = rec.status()
+	if rec.result.Status != ResourceExported {
+		d.run.recordFailure(d.kindDir(task.gvr.Group, task.resource.Kind))
 	}
-	return nil
+	d.run.results.add(rec)
 }
 
-// dumpClusterScopedResources dumps all instances of a cluster-scoped resource
-func (d *Dumper) dumpClusterScopedResources(gvr schema.GroupVersionResource, resource metav1.APIResource, outputDir string) error {
-	// Create directory structure: <outputDir>/_cluster/<resource_kind>
-	resourceDir := filepath.Join(outputDir, "_cluster", resource.Kind)
-	if err := os.MkdirAll(resourceDir, 0755); err != nil {
-		return fmt.Errorf("failed to create resource directory: %w", err)
+// dumpItem writes a listed object of the given group and Kind into outputDir and records the outcome
+func (d *Dumper) dumpItem(item unstructured.Unstructured, gk schema.GroupKind, outputDir string, log *taskLog, rec *resourceRecorder) {
+	if err := d.dumpResource(item, gk, outputDir, log); err != nil {
+		rec.objectFailed(item.GetNamespace(), item.GetName(), err)
+		log.add("ERROR", fmt.Sprintf("%s/%s - failed to export %s: %v", item.GetNamespace(), item.GetName(), rec.result.Kind, err))
+		return
 	}
+	rec.exported()
+}
 
-	// List all resources of this type at cluster level
-	resourceList, err := d.dynamicClient.Resource(gvr).List(context.Background(), metav1.ListOptions{})
+// dumpNamespacedResources dumps all instances of a namespaced resource across the selected
+// namespaces. Without a namespace include list a single cluster-wide list is issued, and
+// per-namespace listing is only used when RBAC forbids listing across all namespaces.
+// With one, only the included namespaces are listed.
+func (d *Dumper) dumpNamespacedResources(ctx context.Context, gvr schema.GroupVersionResource, resource metav1.APIResource, namespaces []string, outputDir string, log *taskLog, rec *resourceRecorder) {
+	if len(d.filter.Namespaces) > 0 {
+		d.dumpNamespacedResourcesPerNamespace(ctx, gvr, resource, namespaces, outputDir, log, rec)
+		return
+	}
+
+	err := d.listPages(ctx, d.dynamicClient.Resource(gvr), log, func(items []unstructured.Unstructured) error {
+		// Dump each resource instance, dropping namespaces excluded by the filter
+		for _, item := range items {
+			if !d.filter.IncludesNamespace(item.GetNamespace()) {
+				continue
+			}
+			d.dumpItem(item, schema.GroupKind{Group: gvr.Group, Kind: resource.Kind}, outputDir, log, rec)
+		}
+		return nil
+	})
 	if err != nil {
-		if d.outputCallback != nil {
-			d.outputCallback("ERROR", fmt.Sprintf("_CLUSTER/%s - failed to list resources: %v", resource.Kind, err))
+		if apierrors.IsForbidden(err) {
+			d.dumpNamespacedResourcesPerNamespace(ctx, gvr, resource, namespaces, outputDir, log, rec)
+			return
 		}
-		return fmt.Errorf("failed to list cluster-scoped %s: %w", resource.Kind, err)
+		rec.listFailed("", err)
+		log.add("ERROR", fmt.Sprintf("%s - failed to list resources: %v", resource.Kind, err))
+		return
 	}
+	rec.listSucceeded()
+}
 
-	if len(resourceList.Items) > 0 {
-
+// dumpNamespacedResourcesPerNamespace lists a namespaced resource one namespace at a time
+func (d *Dumper) dumpNamespacedResourcesPerNamespace(ctx context.Context, gvr schema.GroupVersionResource, resource metav1.APIResource, namespaces []string, outputDir string, log *taskLog, rec *resourceRecorder) {
+	for _, namespace := range namespaces {
+		err := d.listPages(ctx, d.dynamicClient.Resource(gvr).Namespace(namespace), log, func(items []unstructured.Unstructured) error {
+			for _, item := range items {
+				d.dumpItem(item, schema.GroupKind{Group: gvr.Group, Kind: resource.Kind}, outputDir, log, rec)
+			}
+			return nil
+		})
+		if err != nil {
+			rec.listFailed(namespace, err)
+			log.add("ERROR", fmt.Sprintf("%s/%s - failed to list resources: %v", namespace, resource.Kind, err))
+			continue
+		}
+		rec.listSucceeded()
 	}
+}
 
-	// Dump each resource instance
-	for _, item := range resourceList.Items {
-		if err := d.dumpResource(item, resourceDir); err != nil {
-			// Silent fail for individual resources
+// dumpClusterScopedResources dumps all instances of a cluster-scoped resource
+func (d *Dumper) dumpClusterScopedResources(ctx context.Context, gvr schema.GroupVersionResource, resource metav1.APIResource, outputDir string, log *taskLog, rec *resourceRecorder) {
+	err := d.listPages(ctx, d.dynamicClient.Resource(gvr), log, func(items []unstructured.Unstructured) error {
+		for _, item := range items {
+			d.dumpItem(item, schema.GroupKind{Group: gvr.Group, Kind: resource.Kind}, outputDir, log, rec)
 		}
+		return nil
+	})
+	if err != nil {
+		rec.listFailed("", err)
+		log.add("ERROR", fmt.Sprintf("_CLUSTER/%s - failed to list resources: %v", resource.Kind, err))
+		return
+	}
+	rec.listSucceeded()
+}
+
+// dumpResource dumps a single resource instance to the file chosen by the layout.
+// Objects of aggregated layouts are buffered until the export finishes.
+func (d *Dumper) dumpResource(item unstructured.Unstructured, gk schema.GroupKind, outputDir string, log *taskLog) error {
+	key := objectKey{Group: gk.Group, Kind: gk.Kind, Namespace: item.GetNamespace(), Name: item.GetName()}
+	if d.run != nil && d.run.additional[item.GetAPIVersion()] {
+		key.Version = item.GroupVersionKind().Version
 	}
+	rel := d.objectPath(key)
+	filename := filepath.Join(outputDir, rel)
 
-	if len(resourceList.Items) > 0 {
+	// Capture status and the manifest identity before the cleanup profile strips them
+	status, hasStatus := item.Object["status"]
+	entry := newManifestEntry(item.Object)
 
+	// Clean up fields that are not useful for re-application
+	d.cleanup.Apply(&item)
+
+	if d.statusMode == StatusInline && hasStatus {
+		item.Object["status"] = status
+	} else if d.statusMode == StatusSeparate {
+		delete(item.Object, "status")
 	}
-	return nil
-}
 
-// dumpResource dumps a single resource instance to a YAML file
-func (d *Dumper) dumpResource(item unstructured.Unstructured, resourceDir string) error {
-	// Clean up metadata fields that are not useful for re-application
-	cleanupMetadata(&item)
+	// Redact or encrypt Secret values before they touch the disk
+	if isSecretObject(&item) && d.secretMode != SecretsInclude {
+		if err := d.protectSecret(&item, d.previousFile(filename)); err != nil {
+			return err
+		}
+	}
 
-	// Convert to YAML
-	yamlData, err := yaml.Marshal(item.Object)
+	// Serialize canonically so unchanged objects produce identical files
+	data, err := d.format.Encode(item.Object)
 	if err != nil {
-		return fmt.Errorf("failed to marshal resource to YAML: %w", err)
+		return err
+	}
+	if err := d.writeDocument(rel, filename, document{key: key, data: data}, entry); err != nil {
+		return fmt.Errorf("failed to write %s file: %w", strings.ToUpper(string(d.format)), err)
 	}
 
-	// Create filename: <resource_name>.yaml
-	filename := filepath.Join(resourceDir, item.GetName()+".yaml")
-
-	// Write to file
-	if err := os.WriteFile(filename, yamlData, 0644); err != nil {
-		return fmt.Errorf("failed to write YAML file: %w", err)
+	// Write status to its sibling file when captured separately
+	if d.statusMode == StatusSeparate && hasStatus {
+		statusData, err := marshalStatus(&item, status, d.format)
+		if err != nil {
+			return err
+		}
+		statusKey := key
+		statusKey.Status = true
+		statusEntry := entry
+		statusEntry.Status = true
+		if err := d.writeDocument(statusFilename(rel), statusFilename(filename), document{key: statusKey, data: statusData}, statusEntry); err != nil {
+			return fmt.Errorf("failed to write status file: %w", err)
+		}
 	}
 
 	// Output success message with resource path
-	if d.outputCallback != nil {
-		// Extract resource path from resourceDir
-		pathParts := strings.Split(resourceDir, string(os.PathSeparator))
-		var resourcePath string
-
-		if len(pathParts) >= 3 {
-			// Namespaced resource: <output>/<namespace>/<kind>/<name>
-			namespace := pathParts[len(pathParts)-2]
-			kind := pathParts[len(pathParts)-1]
-			name := item.GetName()
-			resourcePath = fmt.Sprintf("%s/%s/%s", namespace, kind, name)
-		} else if len(pathParts) >= 2 {
-			// Cluster-scoped resource: <output>/_cluster/<kind>/<name>
-			kind := pathParts[len(pathParts)-1]
-			name := item.GetName()
-			resourcePath = fmt.Sprintf("_CLUSTER/%s/%s", kind, name)
+	if log != nil {
+		scope := key.Namespace
+		if scope == "" {
+			scope = "_CLUSTER"
 		}
-
-		if resourcePath != "" {
-			d.outputCallback("SUCCESS", resourcePath)
+		name := key.Name
+		if key.Version != "" {
+			name += "@" + key.Version
 		}
+		log.add("SUCCESS", fmt.Sprintf("%s/%s/%s", scope, d.kindDir(key.Group, key.Kind), name))
 	}
 
 	return nil
 }
 
-// cleanupMetadata removes metadata fields that are not useful for re-application
-func cleanupMetadata(item *unstructured.Unstructured) {
-	metadata, exists, err := unstructured.NestedMap(item.Object, "metadata")
-	if !exists || err != nil {
-		return
+// objectPath returns the file holding an object, or its status, relative to the output
+// directory. Kinds served by several API groups are group-qualified and names are escaped.
+// Copies in an additional API version get a "@<version>" suffix, which escaped names
+// never contain.
+func (d *Dumper) objectPath(key objectKey) string {
+	name := EscapeName(key.Name)
+	if key.Version != "" {
+		name += "@" + key.Version
 	}
+	rel := d.format.withExtension(d.layout.Path(key.Namespace, d.kindDir(key.Group, key.Kind), name))
+	if key.Status {
+		rel = statusFilename(rel)
+	}
+	return rel
+}
 
-	// Remove fields that are not useful for re-application
-	delete(metadata, "uid")
-	delete(metadata, "resourceVersion")
-	delete(metadata, "generation")
-	delete(metadata, "creationTimestamp")
-	delete(metadata, "managedFields")
-	delete(metadata, "ownerReferences")
-
-	// Update the cleaned metadata
-	item.Object["metadata"] = metadata
-
-	// Remove status field entirely
-	delete(item.Object, "status")
+// writeDocument writes an object to its own file, or buffers it for an aggregated file,
+// and records its manifest entry
+func (d *Dumper) writeDocument(rel, filename string, doc document, entry ManifestEntry) error {
+	if d.layout.Aggregated() {
+		d.run.addDocument(rel, doc)
+	} else if err := writeFile(filename, doc.data); err != nil {
+		return err
+	}
+	d.run.recordExported(doc.key, entry.locate(rel, doc.data))
+	return nil
 }
@@ -1,13 +1,123 @@
 package dumper
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"gopkg.in/yaml.v3"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/client-go/discovery"
 	discoveryfake "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/dynamic"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
 	kubernetesfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/openapi"
+	k8stesting "k8s.io/client-go/testing"
 )
 
+// preferredDiscovery is a fake discovery client that serves a fixed resource list
+type preferredDiscovery struct {
+	*discoveryfake.FakeDiscovery
+	resources []*metav1.APIResourceList
+	err       error
+	openapi   fakeOpenAPI
+}
+
+func (p *preferredDiscovery) ServerPreferredResources() ([]*metav1.APIResourceList, error) {
+	return p.resources, p.err
+}
+
+func (p *preferredDiscovery) OpenAPIV3() openapi.Client {
+	return p.openapi
+}
+
+// fakeOpenAPI serves OpenAPI v3 documents by discovery path
+type fakeOpenAPI map[string]fakeGroupVersion
+
+func (f fakeOpenAPI) Paths() (map[string]openapi.GroupVersion, error) {
+	paths := make(map[string]openapi.GroupVersion, len(f))
+	for path, gv := range f {
+		paths[path] = gv
+	}
+	return paths, nil
+}
+
+// fakeGroupVersion is the OpenAPI v3 document of a group version
+type fakeGroupVersion string
+
+func (f fakeGroupVersion) Schema(string) ([]byte, error) {
+	return []byte(f), nil
+}
+
+// newTestDumper builds a Dumper backed by fake clients holding three namespaces,
+// a ConfigMap in two of them and a cluster-scoped Namespace list
+func newTestDumper(t *testing.T) *Dumper {
+	t.Helper()
+
+	clientset := kubernetesfake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "empty"}},
+	)
+	discovery := &preferredDiscovery{
+		FakeDiscovery: &discoveryfake.FakeDiscovery{
+			Fake:               &k8stesting.Fake{},
+			FakedServerVersion: &version.Info{GitVersion: "v1.28.4"},
+		},
+		resources: []*metav1.APIResourceList{
+			{
+				GroupVersion: "v1",
+				APIResources: []metav1.APIResource{
+					{Name: "configmaps", Kind: "ConfigMap", Namespaced: true, Verbs: metav1.Verbs{"list", "get"}},
+					{Name: "namespaces", Kind: "Namespace", Verbs: metav1.Verbs{"list", "get"}},
+					{Name: "pods/log", Kind: "Pod", Namespaced: true},
+				},
+			},
+		},
+	}
+	dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(),
+		newTestObject("v1", "ConfigMap", "default", "settings"),
+		newTestObject("v1", "ConfigMap", "team-a", "app-config"),
+		newTestObject("v1", "Namespace", "", "default"),
+		newTestObject("v1", "Namespace", "", "team-a"),
+		newTestObject("v1", "Namespace", "", "empty"),
+	)
+
+	d := NewDumper(clientset, discovery)
+	d.SetDynamicClient(dynamicClient)
+	return d
+}
+
+// newTestObject builds an unstructured object for the fake dynamic client
+func newTestObject(apiVersion, kind, namespace, name string) *unstructured.Unstructured {
+	item := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	item.SetAPIVersion(apiVersion)
+	item.SetKind(kind)
+	item.SetNamespace(namespace)
+	item.SetName(name)
+	return item
+}
+
 func TestNewDumper(t *testing.T) {
 	fakeClientset := kubernetesfake.NewSimpleClientset()
 	fakeDiscovery := &discoveryfake.FakeDiscovery{}
@@ -44,7 +154,11 @@ func TestCleanupMetadata(t *testing.T) {
 		},
 	}
 
-	cleanupMetadata(item)
+	profile, err := LoadCleanupProfile(DefaultCleanupProfile)
+	if err != nil {
+		t.Fatalf("failed to load default cleanup profile: %v", err)
+	}
+	profile.Apply(item)
 
 	metadata, exists, _ := unstructured.NestedMap(item.Object, "metadata")
 	if !exists {
@@ -81,3 +195,1313 @@ func TestCleanupMetadata(t *testing.T) {
 		t.Error("status should have been removed")
 	}
 }
+
+func TestResourceFilter(t *testing.T) {
+	filter := ResourceFilter{
+		ExcludeNamespaces: []string{"kube-*"},
+		ExcludeResources:  []string{"events", "*.events.k8s.io"},
+	}
+
+	if filter.IncludesNamespace("kube-system") {
+		t.Error("kube-system should have been excluded")
+	}
+	if !filter.IncludesNamespace("default") {
+		t.Error("default should have been included")
+	}
+
+	core := schema.GroupVersionResource{Version: "v1", Resource: "events"}
+	if filter.IncludesResource(core, metav1.APIResource{Name: "events", Kind: "Event"}) {
+		t.Error("core events should have been excluded")
+	}
+
+	grouped := schema.GroupVersionResource{Group: "events.k8s.io", Version: "v1", Resource: "events"}
+	if filter.IncludesResource(grouped, metav1.APIResource{Name: "events", Kind: "Event"}) {
+		t.Error("events.k8s.io events should have been excluded")
+	}
+
+	deployments := schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+	if !filter.IncludesResource(deployments, metav1.APIResource{Name: "deployments", Kind: "Deployment"}) {
+		t.Error("deployments should have been included")
+	}
+
+	// Include lists restrict the export to matching entries only
+	filter = ResourceFilter{Namespaces: []string{"team-*"}, Resources: []string{"Deployment"}}
+	if filter.IncludesNamespace("default") {
+		t.Error("default should not match the include list")
+	}
+	if !filter.IncludesResource(deployments, metav1.APIResource{Name: "deployments", Kind: "Deployment"}) {
+		t.Error("Deployment should match the include list by Kind")
+	}
+	if filter.IncludesResource(core, metav1.APIResource{Name: "configmaps", Kind: "ConfigMap"}) {
+		t.Error("ConfigMap should not match the include list")
+	}
+}
+
+func TestSkipResources(t *testing.T) {
+	resourceList := &metav1.APIResourceList{
+		GroupVersion: "v1",
+		APIResources: []metav1.APIResource{
+			{Name: "configmaps", Kind: "ConfigMap", Namespaced: true, Verbs: metav1.Verbs{"list", "get"}},
+			{Name: "events", Kind: "Event", Namespaced: true, Verbs: metav1.Verbs{"list", "get"}},
+			{Name: "bindings", Kind: "Binding", Namespaced: true, Verbs: metav1.Verbs{"create"}},
+		},
+	}
+	planned := func(d *Dumper) []string {
+		d.run = newExportRun(t.TempDir())
+		var kinds []string
+		for _, task := range d.processResourceGroup(resourceList, nil) {
+			kinds = append(kinds, task.resource.Kind)
+		}
+		return kinds
+	}
+
+	d := newTestDumper(t)
+	if kinds := planned(d); !reflect.DeepEqual(kinds, []string{"ConfigMap"}) {
+		t.Errorf("expected only ConfigMap to be planned, got %v", kinds)
+	}
+	reasons := make(map[string]string)
+	for _, skipped := range d.run.results.result.Skipped {
+		reasons[skipped.Kind] = skipped.Reason
+	}
+	if !reflect.DeepEqual(reasons, map[string]string{"Event": "ephemeral", "Binding": "not listable"}) {
+		t.Errorf("unexpected skip reasons: %v", reasons)
+	}
+
+	// Explicitly selected resources override the skip list
+	d.SetFilter(ResourceFilter{Resources: []string{"events"}})
+	if kinds := planned(d); !reflect.DeepEqual(kinds, []string{"Event"}) {
+		t.Errorf("expected explicitly selected Events to be planned, got %v", kinds)
+	}
+
+	// An empty skip list exports every listable resource
+	d.SetFilter(ResourceFilter{})
+	d.SetSkipResources(nil)
+	if kinds := planned(d); !reflect.DeepEqual(kinds, []string{"ConfigMap", "Event"}) {
+		t.Errorf("expected every listable resource to be planned, got %v", kinds)
+	}
+}
+
+func TestPlannedResources(t *testing.T) {
+	d := newTestDumper(t)
+	d.SetFilter(ResourceFilter{ExcludeResources: []string{"namespaces"}})
+
+	gvrs, err := d.PlannedResources()
+	if err != nil {
+		t.Fatalf("PlannedResources failed: %v", err)
+	}
+	expected := []schema.GroupVersionResource{{Version: "v1", Resource: "configmaps"}}
+	if !reflect.DeepEqual(gvrs, expected) {
+		t.Errorf("expected %v, got %v", expected, gvrs)
+	}
+}
+
+func TestDumpAllResourcesConcurrent(t *testing.T) {
+	var runs [][]string
+	for i := 0; i < 2; i++ {
+		outputDir := t.TempDir()
+		d := newTestDumper(t)
+		d.SetConcurrency(4)
+
+		var messages []string
+		d.SetOutputCallback(func(level, message string) {
+			messages = append(messages, level+" "+message)
+		})
+
+		if _, err := d.DumpAllResources(context.Background(), outputDir); err != nil {
+			t.Fatalf("DumpAllResources failed: %v", err)
+		}
+
+		for _, file := range []string{
+			filepath.Join("default", "ConfigMap", "settings.yaml"),
+			filepath.Join("team-a", "ConfigMap", "app-config.yaml"),
+			filepath.Join("_cluster", "Namespace", "team-a.yaml"),
+		} {
+			if _, err := os.Stat(filepath.Join(outputDir, file)); err != nil {
+				t.Errorf("expected %s to be written: %v", file, err)
+			}
+		}
+		runs = append(runs, messages)
+	}
+
+	if !reflect.DeepEqual(runs[0], runs[1]) {
+		t.Errorf("callback ordering is not stable:\n%v\n%v", runs[0], runs[1])
+	}
+}
+
+func TestDumpAllResourcesCancelled(t *testing.T) {
+	d := newTestDumper(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := d.DumpAllResources(ctx, t.TempDir()); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected a cancelled export to fail with context.Canceled, got %v", err)
+	}
+}
+
+func TestDumpNamespacedResourcesForbiddenFallback(t *testing.T) {
+	outputDir := t.TempDir()
+	d := newTestDumper(t)
+
+	// Forbid cluster-wide ConfigMap lists so the dumper falls back to per-namespace listing
+	fakeDynamic := d.dynamicClient.(*dynamicfake.FakeDynamicClient)
+	var namespacedLists []string
+	fakeDynamic.PrependReactor("list", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetNamespace() == "" {
+			return true, nil, apierrors.NewForbidden(schema.GroupResource{Resource: "configmaps"}, "", nil)
+		}
+		namespacedLists = append(namespacedLists, action.GetNamespace())
+		return false, nil, nil
+	})
+
+	if _, err := d.DumpAllResources(context.Background(), outputDir); err != nil {
+		t.Fatalf("DumpAllResources failed: %v", err)
+	}
+
+	if len(namespacedLists) != 3 {
+		t.Errorf("expected one list per namespace, got %v", namespacedLists)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "team-a", "ConfigMap", "app-config.yaml")); err != nil {
+		t.Errorf("expected ConfigMap to be written after fallback: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "empty")); !os.IsNotExist(err) {
+		t.Error("no directory should be created for a namespace without resources")
+	}
+}
+
+func TestDumpNamespacedResourcesIncludedNamespaces(t *testing.T) {
+	outputDir := t.TempDir()
+	d := newTestDumper(t)
+	d.SetFilter(ResourceFilter{Namespaces: []string{"team-*"}})
+
+	// Only the included namespaces are listed, never the whole cluster
+	fakeDynamic := d.dynamicClient.(*dynamicfake.FakeDynamicClient)
+	var lists []string
+	fakeDynamic.PrependReactor("list", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		lists = append(lists, action.GetNamespace())
+		return false, nil, nil
+	})
+
+	if _, err := d.DumpAllResources(context.Background(), outputDir); err != nil {
+		t.Fatalf("DumpAllResources failed: %v", err)
+	}
+
+	if !reflect.DeepEqual(lists, []string{"team-a"}) {
+		t.Errorf("expected ConfigMaps to be listed in team-a only, got %q", lists)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "team-a", "ConfigMap", "app-config.yaml")); err != nil {
+		t.Errorf("expected ConfigMap of an included namespace to be written: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "default", "ConfigMap", "settings.yaml")); !os.IsNotExist(err) {
+		t.Error("ConfigMap of a namespace outside the include list should not be written")
+	}
+}
+
+func TestExportResult(t *testing.T) {
+	outputDir := t.TempDir()
+	d := newTestDumper(t)
+
+	// Forbid Namespace lists entirely and ConfigMap lists everywhere but in team-a
+	fakeDynamic := d.dynamicClient.(*dynamicfake.FakeDynamicClient)
+	fakeDynamic.PrependReactor("list", "namespaces", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(schema.GroupResource{Resource: "namespaces"}, "", nil)
+	})
+	fakeDynamic.PrependReactor("list", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetNamespace() != "team-a" {
+			return true, nil, apierrors.NewForbidden(schema.GroupResource{Resource: "configmaps"}, "", nil)
+		}
+		return false, nil, nil
+	})
+
+	result, err := d.DumpAllResources(context.Background(), outputDir)
+	if err != nil {
+		t.Fatalf("DumpAllResources failed: %v", err)
+	}
+
+	statuses := make(map[string]string)
+	for _, res := range result.Resources {
+		statuses[res.Kind] = res.Status
+	}
+	expected := map[string]string{"ConfigMap": ResourcePartial, "Namespace": ResourceForbidden}
+	if !reflect.DeepEqual(statuses, expected) {
+		t.Errorf("expected statuses %v, got %v", expected, statuses)
+	}
+
+	if result.Namespaces != 3 {
+		t.Errorf("expected 3 namespaces, got %d", result.Namespaces)
+	}
+	expectedTotals := ExportTotals{Resources: 2, Objects: 1, Forbidden: 1}
+	if result.Totals != expectedTotals {
+		t.Errorf("expected totals %+v, got %+v", expectedTotals, result.Totals)
+	}
+	if len(result.Forbidden) != 3 || len(result.Failed) != 0 {
+		t.Errorf("expected 3 forbidden lists and no failures, got %v and %v", result.Forbidden, result.Failed)
+	}
+	if !result.HasErrors() {
+		t.Error("result with forbidden resources should report errors")
+	}
+	if !reflect.DeepEqual(d.FailedKinds(), []string{"ConfigMap", "Namespace"}) {
+		t.Errorf("expected ConfigMap and Namespace to be failed kinds, got %v", d.FailedKinds())
+	}
+}
+
+func TestPartialDiscovery(t *testing.T) {
+	outputDir := t.TempDir()
+	stale := filepath.Join(outputDir, "_cluster", "NodeMetrics", "node-1.yaml")
+	if err := os.MkdirAll(filepath.Dir(stale), 0755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(stale, []byte("{}\n"), 0644); err != nil {
+		t.Fatalf("failed to write stale file: %v", err)
+	}
+
+	d := newTestDumper(t)
+	d.discoveryClient.(*preferredDiscovery).err = &discovery.ErrGroupDiscoveryFailed{
+		Groups: map[schema.GroupVersion]error{
+			{Group: "metrics.k8s.io", Version: "v1beta1"}: errors.New("the server is currently unable to handle the request"),
+		},
+	}
+	var warnings []string
+	d.SetOutputCallback(func(level, message string) {
+		if level == "WARNING" {
+			warnings = append(warnings, message)
+		}
+	})
+
+	stagingDir, err := CreateStagingDir(outputDir)
+	if err != nil {
+		t.Fatalf("CreateStagingDir failed: %v", err)
+	}
+	defer os.RemoveAll(stagingDir)
+
+	result, err := d.DumpAllResources(context.Background(), stagingDir)
+	if err != nil {
+		t.Fatalf("DumpAllResources should continue past failed groups: %v", err)
+	}
+	if len(result.FailedGroups) != 1 || result.FailedGroups[0].GroupVersion != "metrics.k8s.io/v1beta1" {
+		t.Errorf("expected metrics.k8s.io/v1beta1 to be recorded as failed, got %v", result.FailedGroups)
+	}
+	if len(warnings) != 1 {
+		t.Errorf("expected one discovery warning, got %v", warnings)
+	}
+	if result.Totals.Objects != 5 {
+		t.Errorf("expected discovered groups to be exported, got %d objects", result.Totals.Objects)
+	}
+
+	// Files of kinds that may belong to the undiscovered group must survive pruning
+	if _, err := d.PromoteStaging(stagingDir, outputDir, true); err != nil {
+		t.Fatalf("PromoteStaging failed: %v", err)
+	}
+	if _, err := os.Stat(stale); err != nil {
+		t.Errorf("file of an undiscovered kind should not be pruned: %v", err)
+	}
+
+	// Any other discovery error still aborts the export
+	d.discoveryClient.(*preferredDiscovery).err = errors.New("connection refused")
+	if _, err := d.DumpAllResources(context.Background(), t.TempDir()); err == nil {
+		t.Error("expected discovery errors other than failed groups to abort the export")
+	}
+}
+
+// pagedResource is a dynamic.ResourceInterface stub serving fixed pages. The first
+// request using a continue token fails with 410 Gone to exercise list restarts.
+type pagedResource struct {
+	dynamic.ResourceInterface
+	pages    [][]string
+	requests []metav1.ListOptions
+	expired  bool
+}
+
+func (p *pagedResource) List(_ context.Context, opts metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	p.requests = append(p.requests, opts)
+	if opts.Continue != "" && !p.expired {
+		p.expired = true
+		return nil, apierrors.NewResourceExpired("continue token expired")
+	}
+
+	page := 0
+	if opts.Continue != "" {
+		page, _ = strconv.Atoi(opts.Continue)
+	}
+
+	list := &unstructured.UnstructuredList{}
+	for _, name := range p.pages[page] {
+		list.Items = append(list.Items, *newTestObject("v1", "ConfigMap", "default", name))
+	}
+	if page+1 < len(p.pages) {
+		list.SetContinue(strconv.Itoa(page + 1))
+	}
+	return list, nil
+}
+
+func TestListPages(t *testing.T) {
+	d := newTestDumper(t)
+	d.SetPageSize(2)
+
+	client := &pagedResource{pages: [][]string{{"a", "b"}, {"c"}}}
+	var names []string
+	err := d.listPages(context.Background(), client, &taskLog{}, func(items []unstructured.Unstructured) error {
+		for _, item := range items {
+			names = append(names, item.GetName())
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("listPages failed: %v", err)
+	}
+
+	// The expired continue token restarts the list, without delivering the first page twice
+	expected := []string{"a", "b", "c"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected items %v, got %v", expected, names)
+	}
+	for _, opts := range client.requests {
+		if opts.Limit != 2 {
+			t.Errorf("expected page size 2, got %d", opts.Limit)
+		}
+	}
+}
+
+func TestProtectSecret(t *testing.T) {
+	newSecret := func() *unstructured.Unstructured {
+		item := newTestObject("v1", "Secret", "default", "db")
+		item.Object["data"] = map[string]interface{}{
+			"password": base64.StdEncoding.EncodeToString([]byte("hunter2")),
+		}
+		return item
+	}
+
+	// Redaction needs a hash key, then replaces values with a stable hash
+	d := newTestDumper(t)
+	if err := d.SetSecretMode(SecretsRedact, nil); err != nil {
+		t.Fatalf("SetSecretMode failed: %v", err)
+	}
+	if err := d.protectSecret(newSecret(), ""); err == nil {
+		t.Error("expected error when redacting without a hash key")
+	}
+	keyFile := filepath.Join(t.TempDir(), "keys", "test.key")
+	key, err := LoadSecretHashKey(keyFile)
+	if err != nil {
+		t.Fatalf("LoadSecretHashKey failed: %v", err)
+	}
+	if again, err := LoadSecretHashKey(keyFile); err != nil || !bytes.Equal(again, key) {
+		t.Errorf("expected the stored key to be reused, got %x (%v)", again, err)
+	}
+	d.SetSecretHashKey(key)
+	first, second := newSecret(), newSecret()
+	if err := d.protectSecret(first, ""); err != nil {
+		t.Fatalf("protectSecret failed: %v", err)
+	}
+	if err := d.protectSecret(second, ""); err != nil {
+		t.Fatalf("protectSecret failed: %v", err)
+	}
+	redacted, _, _ := unstructured.NestedString(first.Object, "data", "password")
+	if !strings.HasPrefix(redacted, RedactedPrefix) {
+		t.Errorf("expected redacted value, got %s", redacted)
+	}
+	if again, _, _ := unstructured.NestedString(second.Object, "data", "password"); again != redacted {
+		t.Error("redacted value should be stable across exports")
+	}
+	d.SetSecretHashKey([]byte("other"))
+	rekeyed := newSecret()
+	if err := d.protectSecret(rekeyed, ""); err != nil {
+		t.Fatalf("protectSecret failed: %v", err)
+	}
+	if other, _, _ := unstructured.NestedString(rekeyed.Object, "data", "password"); other == redacted {
+		t.Error("redacted value should depend on the hash key")
+	}
+
+	// Encryption requires recipients and produces decryptable age ciphertext
+	if err := d.SetSecretMode(SecretsEncrypt, nil); err == nil {
+		t.Error("expected error when encrypting without recipients")
+	}
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatalf("failed to generate age identity: %v", err)
+	}
+	if err := d.SetSecretMode(SecretsEncrypt, []string{identity.Recipient().String()}); err != nil {
+		t.Fatalf("SetSecretMode failed: %v", err)
+	}
+
+	encrypted := newSecret()
+	if err := d.protectSecret(encrypted, ""); err != nil {
+		t.Fatalf("protectSecret failed: %v", err)
+	}
+	ciphertext, _, _ := unstructured.NestedString(encrypted.Object, "data", "password")
+	reader, err := age.Decrypt(armor.NewReader(strings.NewReader(ciphertext)), identity)
+	if err != nil {
+		t.Fatalf("failed to decrypt secret value: %v", err)
+	}
+	plaintext, _ := io.ReadAll(reader)
+	if string(plaintext) != "hunter2" {
+		t.Errorf("expected decrypted value hunter2, got %s", plaintext)
+	}
+
+	// Unchanged values keep their previous ciphertext
+	previousFile := filepath.Join(t.TempDir(), "db.yaml")
+	data, _ := yaml.Marshal(encrypted.Object)
+	if err := os.WriteFile(previousFile, data, 0644); err != nil {
+		t.Fatalf("failed to write previous secret: %v", err)
+	}
+	reexported := newSecret()
+	if err := d.protectSecret(reexported, previousFile); err != nil {
+		t.Fatalf("protectSecret failed: %v", err)
+	}
+	if again, _, _ := unstructured.NestedString(reexported.Object, "data", "password"); again != ciphertext {
+		t.Error("unchanged secret value should keep its ciphertext")
+	}
+
+	// Without a hash key no checksums are exported
+	d.SetSecretHashKey(nil)
+	unkeyed := newSecret()
+	if err := d.protectSecret(unkeyed, previousFile); err != nil {
+		t.Fatalf("protectSecret failed: %v", err)
+	}
+	if _, ok := unkeyed.GetAnnotations()[SecretChecksumsAnnotation]; ok {
+		t.Error("expected no checksums without a hash key")
+	}
+}
+
+func TestCleanupProfiles(t *testing.T) {
+	newService := func() *unstructured.Unstructured {
+		item := newTestObject("v1", "Service", "default", "web")
+		item.SetUID("0f6c5e4e-6f0e-4b1e-9a43-3d7f2a1c9b10")
+		item.SetAnnotations(map[string]string{
+			"kubectl.kubernetes.io/last-applied-configuration": "{}",
+		})
+		item.SetOwnerReferences([]metav1.OwnerReference{{Name: "owner"}})
+		item.Object["spec"] = map[string]interface{}{
+			"clusterIP": "10.0.0.1",
+			"ports":     []interface{}{map[string]interface{}{"port": int64(80), "nodePort": int64(30080)}},
+		}
+		return item
+	}
+
+	// minimal keeps ownerReferences
+	minimal, err := LoadCleanupProfile("minimal")
+	if err != nil {
+		t.Fatalf("failed to load minimal profile: %v", err)
+	}
+	item := newService()
+	minimal.Apply(item)
+	if len(item.GetOwnerReferences()) != 1 {
+		t.Error("minimal profile should keep ownerReferences")
+	}
+
+	// full drops controller noise and allocated cluster IPs, and empty annotation maps
+	full, err := LoadCleanupProfile("full")
+	if err != nil {
+		t.Fatalf("failed to load full profile: %v", err)
+	}
+	item = newService()
+	full.Apply(item)
+	if len(item.GetOwnerReferences()) != 0 {
+		t.Error("full profile should remove ownerReferences")
+	}
+	if _, exists, _ := unstructured.NestedFieldNoCopy(item.Object, "metadata", "annotations"); exists {
+		t.Error("empty annotations should have been removed")
+	}
+	if _, exists, _ := unstructured.NestedFieldNoCopy(item.Object, "spec", "clusterIP"); exists {
+		t.Error("spec.clusterIP should have been removed")
+	}
+
+	// Custom profiles extend built-ins and support list wildcards
+	profilePath := filepath.Join(t.TempDir(), "profile.yaml")
+	custom := `name: custom
+extends: minimal
+rules:
+  - group: core
+    kind: Service
+    fields: ["spec.ports[*].nodePort"]
+`
+	if err := os.WriteFile(profilePath, []byte(custom), 0644); err != nil {
+		t.Fatalf("failed to write profile: %v", err)
+	}
+	profile, err := LoadCleanupProfile(profilePath)
+	if err != nil {
+		t.Fatalf("failed to load custom profile: %v", err)
+	}
+	item = newService()
+	profile.Apply(item)
+	ports, _, _ := unstructured.NestedSlice(item.Object, "spec", "ports")
+	if _, exists := ports[0].(map[string]interface{})["nodePort"]; exists {
+		t.Error("nodePort should have been removed from every port")
+	}
+	if _, exists, _ := unstructured.NestedFieldNoCopy(item.Object, "metadata", "uid"); exists {
+		t.Error("custom profile should inherit minimal rules")
+	}
+
+	if _, err := LoadCleanupProfile("does-not-exist"); err == nil {
+		t.Error("expected error for unknown profile")
+	}
+}
+
+func TestStatusModes(t *testing.T) {
+	newPod := func() unstructured.Unstructured {
+		item := newTestObject("v1", "Pod", "default", "web")
+		item.Object["status"] = map[string]interface{}{"phase": "Running"}
+		return *item
+	}
+
+	d := newTestDumper(t)
+	outputDir := t.TempDir()
+	d.run = newExportRun(outputDir)
+	resourceDir := filepath.Join(outputDir, "default", "Pod")
+
+	d.SetStatusMode(StatusSeparate)
+	if err := d.dumpResource(newPod(), schema.GroupKind{Kind: "Pod"}, outputDir, &taskLog{}); err != nil {
+		t.Fatalf("dumpResource failed: %v", err)
+	}
+	spec, _ := os.ReadFile(filepath.Join(resourceDir, "web.yaml"))
+	if strings.Contains(string(spec), "phase") {
+		t.Error("status should not be written inline in separate mode")
+	}
+	status, err := os.ReadFile(filepath.Join(resourceDir, "web"+StatusFileSuffix))
+	if err != nil {
+		t.Fatalf("expected status file: %v", err)
+	}
+	if !strings.Contains(string(status), "phase: Running") {
+		t.Errorf("status file should hold the status, got:\n%s", status)
+	}
+
+	d.SetStatusMode(StatusInline)
+	if err := d.dumpResource(newPod(), schema.GroupKind{Kind: "Pod"}, outputDir, &taskLog{}); err != nil {
+		t.Fatalf("dumpResource failed: %v", err)
+	}
+	spec, _ = os.ReadFile(filepath.Join(resourceDir, "web.yaml"))
+	if !strings.Contains(string(spec), "phase: Running") {
+		t.Error("status should be kept inline in inline mode")
+	}
+}
+
+func TestPromoteStaging(t *testing.T) {
+	for _, prune := range []bool{true, false} {
+		outputDir := t.TempDir()
+
+		// Files left over from a previous export, plus entries that must never be pruned
+		stale := []string{
+			filepath.Join("default", "ConfigMap", "deleted.yaml"),
+			filepath.Join("removed-namespace", "ConfigMap", "old.yaml"),
+		}
+		kept := []string{
+			filepath.Join("kalco-reports", "report.md"),
+			"kalco-config.json",
+			filepath.Join("kube-system", "ConfigMap", "filtered.yaml"),
+		}
+		for _, file := range append(append([]string{}, stale...), kept...) {
+			path := filepath.Join(outputDir, file)
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatalf("failed to create directory: %v", err)
+			}
+			if err := os.WriteFile(path, []byte("{}\n"), 0644); err != nil {
+				t.Fatalf("failed to write %s: %v", file, err)
+			}
+		}
+
+		stagingDir, err := CreateStagingDir(outputDir)
+		if err != nil {
+			t.Fatalf("CreateStagingDir failed: %v", err)
+		}
+		defer os.RemoveAll(stagingDir)
+
+		d := newTestDumper(t)
+		d.SetFilter(ResourceFilter{ExcludeNamespaces: []string{"kube-system"}})
+		if _, err := d.PromoteStaging(stagingDir, outputDir, prune); err == nil {
+			t.Error("expected error when promoting before an export")
+		}
+		if _, err := d.DumpAllResources(context.Background(), stagingDir); err != nil {
+			t.Fatalf("DumpAllResources failed: %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(outputDir, "default", "ConfigMap", "settings.yaml")); !os.IsNotExist(err) {
+			t.Error("output directory should be untouched until the staging directory is promoted")
+		}
+
+		pruned, err := d.PromoteStaging(stagingDir, outputDir, prune)
+		if err != nil {
+			t.Fatalf("PromoteStaging failed: %v", err)
+		}
+
+		if prune {
+			if !reflect.DeepEqual(pruned, stale) {
+				t.Errorf("expected pruned files %v, got %v", stale, pruned)
+			}
+			if _, err := os.Stat(filepath.Join(outputDir, "removed-namespace")); !os.IsNotExist(err) {
+				t.Error("namespace directory without files should have been removed")
+			}
+		} else {
+			if len(pruned) != 0 {
+				t.Errorf("expected no pruned files, got %v", pruned)
+			}
+			kept = append(kept, stale...)
+		}
+		for _, file := range kept {
+			if _, err := os.Stat(filepath.Join(outputDir, file)); err != nil {
+				t.Errorf("%s should have been kept: %v", file, err)
+			}
+		}
+		if _, err := os.Stat(filepath.Join(outputDir, "default", "ConfigMap", "settings.yaml")); err != nil {
+			t.Errorf("exported file should have been promoted: %v", err)
+		}
+	}
+}
+
+func TestLayouts(t *testing.T) {
+	tests := []struct {
+		layout string
+		files  []string
+	}{
+		{LayoutTree, []string{
+			filepath.Join("default", "ConfigMap", "settings.yaml"),
+			filepath.Join("_cluster", "Namespace", "team-a.yaml"),
+		}},
+		{LayoutNamespace, []string{"default.yaml", "team-a.yaml", "_cluster.yaml"}},
+		{LayoutKind, []string{"ConfigMap.yaml", "Namespace.yaml"}},
+		{LayoutSingle, []string{"all.yaml"}},
+		{LayoutFlat, []string{"default_ConfigMap_settings.yaml", "_cluster_Namespace_team-a.yaml"}},
+	}
+
+	for _, tt := range tests {
+		layout, err := ParseLayout(tt.layout)
+		if err != nil {
+			t.Fatalf("ParseLayout(%s) failed: %v", tt.layout, err)
+		}
+		outputDir := t.TempDir()
+		d := newTestDumper(t)
+		d.SetLayout(layout)
+		if _, err := d.DumpAllResources(context.Background(), outputDir); err != nil {
+			t.Fatalf("%s: DumpAllResources failed: %v", tt.layout, err)
+		}
+
+		var objects int
+		for _, file := range tt.files {
+			data, err := os.ReadFile(filepath.Join(outputDir, file))
+			if err != nil {
+				t.Errorf("%s: expected file %s: %v", tt.layout, file, err)
+				continue
+			}
+			documents, err := ReadDocuments(data)
+			if err != nil {
+				t.Errorf("%s: failed to parse %s: %v", tt.layout, file, err)
+			}
+			objects += len(documents)
+		}
+		if layout.Aggregated() && objects != 5 {
+			t.Errorf("%s: expected 5 objects across files, got %d", tt.layout, objects)
+		}
+	}
+
+	// Aggregated files are sorted by Kind, namespace and name
+	outputDir := t.TempDir()
+	d := newTestDumper(t)
+	d.SetLayout(singleLayout{})
+	if _, err := d.DumpAllResources(context.Background(), outputDir); err != nil {
+		t.Fatalf("DumpAllResources failed: %v", err)
+	}
+	data, _ := os.ReadFile(filepath.Join(outputDir, "all.yaml"))
+	documents, _ := ReadDocuments(data)
+	var order []string
+	for _, object := range documents {
+		_, namespace, name, _ := ObjectIdentity(object)
+		order = append(order, namespace+"/"+name)
+	}
+	expected := []string{"default/settings", "team-a/app-config", "/default", "/empty", "/team-a"}
+	if !reflect.DeepEqual(order, expected) {
+		t.Errorf("expected document order %v, got %v", expected, order)
+	}
+
+	if _, err := ParseLayout("nested"); err == nil {
+		t.Error("expected error for unknown layout")
+	}
+}
+
+func TestAddDocumentReplaces(t *testing.T) {
+	outputDir := t.TempDir()
+	run := newExportRun(outputDir)
+	key := objectKey{Kind: "ConfigMap", Namespace: "default", Name: "settings"}
+	run.addDocument("all.yaml", document{key: key, data: []byte("version: 1\n")})
+	run.addDocument("all.yaml", document{key: key, data: []byte("version: 2\n")})
+	if err := run.writeDocuments(FormatYAML); err != nil {
+		t.Fatalf("writeDocuments failed: %v", err)
+	}
+
+	data, _ := os.ReadFile(filepath.Join(outputDir, "all.yaml"))
+	if string(data) != "version: 2\n" {
+		t.Errorf("expected only the latest document, got %q", data)
+	}
+}
+
+func TestPromoteStagingAggregated(t *testing.T) {
+	outputDir := t.TempDir()
+
+	// A previous single-file export, plus a file of the tree layout used before that
+	previous := []*unstructured.Unstructured{
+		newTestObject("v1", "ConfigMap", "default", "settings"),
+		newTestObject("v1", "ConfigMap", "default", "deleted"),
+		newTestObject("v1", "ConfigMap", "kube-system", "filtered"),
+	}
+	var documents []document
+	for _, object := range previous {
+		data, _ := yaml.Marshal(object.Object)
+		documents = append(documents, document{key: objectKey{Kind: object.GetKind(), Namespace: object.GetNamespace(), Name: object.GetName()}, data: data})
+	}
+	if err := writeFile(filepath.Join(outputDir, "all.yaml"), joinDocuments(documents)); err != nil {
+		t.Fatalf("failed to write previous export: %v", err)
+	}
+	treeFile := filepath.Join(outputDir, "team-a", "ConfigMap", "app-config.yaml")
+	data, _ := yaml.Marshal(newTestObject("v1", "ConfigMap", "team-a", "app-config").Object)
+	if err := writeFile(treeFile, data); err != nil {
+		t.Fatalf("failed to write previous export: %v", err)
+	}
+
+	stagingDir, err := CreateStagingDir(outputDir)
+	if err != nil {
+		t.Fatalf("CreateStagingDir failed: %v", err)
+	}
+	defer os.RemoveAll(stagingDir)
+
+	d := newTestDumper(t)
+	d.SetLayout(singleLayout{})
+	d.SetFilter(ResourceFilter{ExcludeNamespaces: []string{"kube-system"}})
+	if _, err := d.DumpAllResources(context.Background(), stagingDir); err != nil {
+		t.Fatalf("DumpAllResources failed: %v", err)
+	}
+	pruned, err := d.PromoteStaging(stagingDir, outputDir, true)
+	if err != nil {
+		t.Fatalf("PromoteStaging failed: %v", err)
+	}
+
+	expectedPruned := []string{"all.yaml: ConfigMap default/deleted"}
+	if !reflect.DeepEqual(pruned, expectedPruned) {
+		t.Errorf("expected pruned %v, got %v", expectedPruned, pruned)
+	}
+	if _, err := os.Stat(treeFile); !os.IsNotExist(err) {
+		t.Error("objects moved into all.yaml should not be kept in their old file")
+	}
+
+	data, err = os.ReadFile(filepath.Join(outputDir, "all.yaml"))
+	if err != nil {
+		t.Fatalf("expected all.yaml: %v", err)
+	}
+	objects, _ := ReadDocuments(data)
+	var names []string
+	for _, object := range objects {
+		_, namespace, name, _ := ObjectIdentity(object)
+		names = append(names, namespace+"/"+name)
+	}
+	expected := []string{"default/settings", "kube-system/filtered", "team-a/app-config", "/default", "/empty", "/team-a"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected objects %v, got %v", expected, names)
+	}
+}
+
+func TestCanonicalEncode(t *testing.T) {
+	newObject := func() map[string]interface{} {
+		return map[string]interface{}{
+			"kind":       "Pod",
+			"apiVersion": "v1",
+			"metadata": map[string]interface{}{
+				"name":        "web",
+				"annotations": map[string]interface{}{"enabled": "true", "ratio": "1.0", "html": "<b>"},
+			},
+			"spec": map[string]interface{}{
+				"containers": []interface{}{map[string]interface{}{
+					"name":      "app",
+					"args":      []interface{}{"--port", int64(8080)},
+					"resources": map[string]interface{}{"limits": map[string]interface{}{"cpu": "1000m", "memory": "1024Mi"}},
+					"script":    "echo one\necho two\n",
+				}},
+			},
+		}
+	}
+
+	first, err := FormatYAML.Encode(newObject())
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	second, _ := FormatYAML.Encode(newObject())
+	if string(first) != string(second) {
+		t.Error("encoding an unchanged object should be byte-identical")
+	}
+
+	yamlOut := string(first)
+	for _, expected := range []string{
+		"apiVersion: v1\nkind: Pod\nmetadata:",
+		`enabled: "true"`,
+		`ratio: "1.0"`,
+		"cpu: \"1\"",
+		"memory: 1Gi",
+		"script: |\n",
+		"name: web",
+	} {
+		if !strings.Contains(yamlOut, expected) {
+			t.Errorf("expected %q in YAML output:\n%s", expected, yamlOut)
+		}
+	}
+
+	// Canonical YAML reads back as the same object
+	objects, err := ReadDocuments(first)
+	if err != nil || len(objects) != 1 {
+		t.Fatalf("failed to read back YAML: %v", err)
+	}
+	annotations, _, _ := unstructured.NestedStringMap(objects[0], "metadata", "annotations")
+	if annotations["enabled"] != "true" || annotations["ratio"] != "1.0" {
+		t.Errorf("quoted strings should read back as strings, got %v", annotations)
+	}
+
+	jsonOut, err := FormatJSON.Encode(newObject())
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	if !strings.HasPrefix(string(jsonOut), "{\n  \"apiVersion\": \"v1\",\n  \"kind\": \"Pod\",") {
+		t.Errorf("expected sorted, indented JSON, got:\n%s", jsonOut)
+	}
+	if !strings.Contains(string(jsonOut), `"html": "<b>"`) {
+		t.Errorf("HTML characters should not be escaped, got:\n%s", jsonOut)
+	}
+
+	// Quantities are only normalized at the known paths of core Kinds
+	quota, _ := FormatYAML.Encode(map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ResourceQuota",
+		"metadata":   map[string]interface{}{"name": "quota"},
+		"spec":       map[string]interface{}{"hard": map[string]interface{}{"requests.memory": "2048Mi"}},
+	})
+	if !strings.Contains(string(quota), "requests.memory: 2Gi") {
+		t.Errorf("expected a normalized quota, got:\n%s", quota)
+	}
+	custom, _ := FormatYAML.Encode(map[string]interface{}{
+		"apiVersion": "example.com/v1",
+		"kind":       "Widget",
+		"metadata":   map[string]interface{}{"name": "widget"},
+		"spec": map[string]interface{}{
+			"resources": map[string]interface{}{"limits": map[string]interface{}{"cpu": "1000m"}},
+			"hard":      map[string]interface{}{"version": "1e3"},
+		},
+	})
+	if !strings.Contains(string(custom), "cpu: 1000m") || !strings.Contains(string(custom), `version: "1e3"`) {
+		t.Errorf("expected custom resource fields to be left as they are, got:\n%s", custom)
+	}
+
+	if _, err := ParseFormat("xml"); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
+
+func TestJSONFormat(t *testing.T) {
+	outputDir := t.TempDir()
+	d := newTestDumper(t)
+	d.SetFormat(FormatJSON)
+	if _, err := d.DumpAllResources(context.Background(), outputDir); err != nil {
+		t.Fatalf("DumpAllResources failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "default", "ConfigMap", "settings.json")); err != nil {
+		t.Errorf("expected JSON file: %v", err)
+	}
+
+	// Aggregated layouts write a List
+	d = newTestDumper(t)
+	d.SetFormat(FormatJSON)
+	d.SetLayout(singleLayout{})
+	if _, err := d.DumpAllResources(context.Background(), outputDir); err != nil {
+		t.Fatalf("DumpAllResources failed: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(outputDir, "all.json"))
+	if err != nil {
+		t.Fatalf("expected all.json: %v", err)
+	}
+	objects, err := ReadDocuments(data)
+	if err != nil {
+		t.Fatalf("failed to read List: %v", err)
+	}
+	if len(objects) != 5 {
+		t.Errorf("expected 5 objects in List, got %d", len(objects))
+	}
+}
+
+func TestEscapeName(t *testing.T) {
+	tests := map[string]string{
+		"web-1.example":          "web-1.example",
+		"system:controller:job":  "system%3Acontroller%3Ajob",
+		"user@example.com":       "user%40example.com",
+		"snake_case":             "snake%5Fcase",
+		".hidden":                "%2Ehidden",
+		"already%3Aescaped-name": "already%253Aescaped-name",
+	}
+	for name, expected := range tests {
+		if escaped := EscapeName(name); escaped != expected {
+			t.Errorf("EscapeName(%q) = %q, expected %q", name, escaped, expected)
+		}
+		if unescaped := UnescapeName(EscapeName(name)); unescaped != name {
+			t.Errorf("UnescapeName(EscapeName(%q)) = %q", name, unescaped)
+		}
+	}
+
+	if kind, group := ParseKindDir("Certificate.cert-manager.io"); kind != "Certificate" || group != "cert-manager.io" {
+		t.Errorf("unexpected kind %q and group %q", kind, group)
+	}
+	if kind, group := ParseKindDir("ConfigMap"); kind != "ConfigMap" || group != "" {
+		t.Errorf("unexpected kind %q and group %q", kind, group)
+	}
+}
+
+func TestKindCollisions(t *testing.T) {
+	clientset := kubernetesfake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+	)
+	discovery := &preferredDiscovery{
+		FakeDiscovery: &discoveryfake.FakeDiscovery{Fake: &k8stesting.Fake{}},
+		resources: []*metav1.APIResourceList{
+			{
+				GroupVersion: "v1",
+				APIResources: []metav1.APIResource{
+					{Name: "events", Kind: "Event", Namespaced: true, Verbs: metav1.Verbs{"list"}},
+				},
+			},
+			{
+				GroupVersion: "events.k8s.io/v1",
+				APIResources: []metav1.APIResource{
+					{Name: "events", Kind: "Event", Namespaced: true, Verbs: metav1.Verbs{"list"}},
+				},
+			},
+			{
+				GroupVersion: "rbac.authorization.k8s.io/v1",
+				APIResources: []metav1.APIResource{
+					{Name: "clusterroles", Kind: "ClusterRole", Verbs: metav1.Verbs{"list"}},
+				},
+			},
+		},
+	}
+	dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(),
+		newTestObject("v1", "Event", "default", "core-event"),
+		newTestObject("events.k8s.io/v1", "Event", "default", "new-event"),
+		newTestObject("rbac.authorization.k8s.io/v1", "ClusterRole", "", "system:controller:job"),
+	)
+
+	d := NewDumper(clientset, discovery)
+	d.SetDynamicClient(dynamicClient)
+	d.SetSkipResources(nil)
+	outputDir := t.TempDir()
+	if _, err := d.DumpAllResources(context.Background(), outputDir); err != nil {
+		t.Fatalf("DumpAllResources failed: %v", err)
+	}
+
+	for _, file := range []string{
+		filepath.Join("default", "Event", "core-event.yaml"),
+		filepath.Join("default", "Event.events.k8s.io", "new-event.yaml"),
+		filepath.Join("_cluster", "ClusterRole", "system%3Acontroller%3Ajob.yaml"),
+	} {
+		if _, err := os.Stat(filepath.Join(outputDir, file)); err != nil {
+			t.Errorf("expected %s: %v", file, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "default", "Event", "new-event.yaml")); !os.IsNotExist(err) {
+		t.Error("objects of different groups should not share a Kind directory")
+	}
+}
+
+func TestManifest(t *testing.T) {
+	outputDir := t.TempDir()
+
+	// A previous export holding an object of a namespace the next export excludes
+	filtered := filepath.Join("kube-system", "ConfigMap", "filtered.yaml")
+	data, _ := FormatYAML.Encode(newTestObject("v1", "ConfigMap", "kube-system", "filtered").Object)
+	if err := writeFile(filepath.Join(outputDir, filtered), data); err != nil {
+		t.Fatalf("failed to write previous export: %v", err)
+	}
+	previous, _ := json.Marshal(Manifest{Objects: []ManifestEntry{{
+		APIVersion: "v1", Kind: "ConfigMap", Namespace: "kube-system", Name: "filtered",
+		UID: "uid-filtered", File: filepath.ToSlash(filtered),
+	}}})
+	if err := writeFile(filepath.Join(outputDir, ManifestFileName), previous); err != nil {
+		t.Fatalf("failed to write previous manifest: %v", err)
+	}
+
+	stagingDir, err := CreateStagingDir(outputDir)
+	if err != nil {
+		t.Fatalf("CreateStagingDir failed: %v", err)
+	}
+	defer os.RemoveAll(stagingDir)
+
+	d := newTestDumper(t)
+	d.SetFilter(ResourceFilter{ExcludeNamespaces: []string{"kube-system"}})
+	if _, err := d.DumpAllResources(context.Background(), stagingDir); err != nil {
+		t.Fatalf("DumpAllResources failed: %v", err)
+	}
+	if _, err := d.PromoteStaging(stagingDir, outputDir, true); err != nil {
+		t.Fatalf("PromoteStaging failed: %v", err)
+	}
+
+	manifest, err := ReadManifest(outputDir)
+	if err != nil {
+		t.Fatalf("ReadManifest failed: %v", err)
+	}
+	if manifest.ClusterVersion != "v1.28.4" || manifest.Layout != LayoutTree || manifest.Format != FormatYAML || manifest.ExportTime.IsZero() {
+		t.Errorf("unexpected manifest header: %+v", manifest)
+	}
+
+	var files []string
+	for _, entry := range manifest.Objects {
+		files = append(files, entry.File)
+		data, err := os.ReadFile(filepath.Join(outputDir, filepath.FromSlash(entry.File)))
+		if err != nil {
+			t.Errorf("manifest lists missing file %s", entry.File)
+			continue
+		}
+		if sum := sha256.Sum256(data); hex.EncodeToString(sum[:]) != entry.SHA256 {
+			t.Errorf("checksum of %s does not match the manifest", entry.File)
+		}
+		if entry.Name == "filtered" && entry.UID != "uid-filtered" {
+			t.Errorf("carried object should keep its previous entry, got %+v", entry)
+		}
+	}
+	expected := []string{
+		"_cluster/Namespace/default.yaml",
+		"_cluster/Namespace/empty.yaml",
+		"_cluster/Namespace/team-a.yaml",
+		"default/ConfigMap/settings.yaml",
+		"kube-system/ConfigMap/filtered.yaml",
+		"team-a/ConfigMap/app-config.yaml",
+	}
+	if !reflect.DeepEqual(files, expected) {
+		t.Errorf("expected manifest files %v, got %v", expected, files)
+	}
+
+	// The uid and resourceVersion are recorded although the cleanup profile strips them
+	item := newTestObject("v1", "ConfigMap", "default", "versioned")
+	item.SetUID("uid-1")
+	item.SetResourceVersion("42")
+	dir := t.TempDir()
+	d.run = newExportRun(dir)
+	if err := d.dumpResource(*item, schema.GroupKind{Kind: "ConfigMap"}, dir, nil); err != nil {
+		t.Fatalf("dumpResource failed: %v", err)
+	}
+	entry := d.run.exported[objectKey{Kind: "ConfigMap", Namespace: "default", Name: "versioned"}]
+	if entry.UID != "uid-1" || entry.ResourceVersion != "42" || entry.APIVersion != "v1" {
+		t.Errorf("unexpected manifest entry: %+v", entry)
+	}
+	data, _ = os.ReadFile(filepath.Join(dir, filepath.FromSlash(entry.File)))
+	if strings.Contains(string(data), "uid-1") {
+		t.Error("the uid should only be recorded in the manifest")
+	}
+}
+
+func TestExportCRDs(t *testing.T) {
+	clientset := kubernetesfake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+	)
+	widgets := schema.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "widgets"}
+	discovery := &preferredDiscovery{
+		FakeDiscovery: &discoveryfake.FakeDiscovery{Fake: &k8stesting.Fake{}},
+		resources: []*metav1.APIResourceList{
+			{
+				GroupVersion: "apiextensions.k8s.io/v1",
+				APIResources: []metav1.APIResource{
+					{Name: "customresourcedefinitions", Kind: "CustomResourceDefinition", Verbs: metav1.Verbs{"list"}},
+				},
+			},
+			{
+				GroupVersion: "example.com/v1",
+				APIResources: []metav1.APIResource{
+					{Name: "widgets", Kind: "Widget", Namespaced: true, Verbs: metav1.Verbs{"list"}},
+				},
+			},
+		},
+		openapi: fakeOpenAPI{"apis/example.com/v1": `{
+			"openapi": "3.0.0",
+			"components": {"schemas": {
+				"com.example.v1.Widget": {
+					"type": "object",
+					"x-kubernetes-group-version-kind": [{"group": "example.com", "version": "v1", "kind": "Widget"}],
+					"properties": {"metadata": {"$ref": "#/components/schemas/io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta"}}
+				},
+				"com.example.v1.Gadget": {"type": "object"},
+				"io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta": {"type": "object"}
+			}}
+		}`},
+	}
+
+	newCRD := func(name, kind string, generation int64) *unstructured.Unstructured {
+		crd := newTestObject("apiextensions.k8s.io/v1", "CustomResourceDefinition", "", name)
+		crd.SetGeneration(generation)
+		crd.Object["spec"] = map[string]interface{}{
+			"group": "example.com",
+			"names": map[string]interface{}{"kind": kind},
+			"versions": []interface{}{
+				map[string]interface{}{"name": "v1", "served": true, "storage": true},
+				map[string]interface{}{"name": "v1beta1", "served": false, "storage": false},
+			},
+		}
+		return crd
+	}
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			crdResource: "CustomResourceDefinitionList",
+			widgets:     "WidgetList",
+		},
+		newCRD("widgets.example.com", "Widget", 3),
+		newCRD("gadgets.example.com", "Gadget", 1),
+		newTestObject("example.com/v1", "Widget", "default", "w1"),
+	)
+
+	// The definition of a CRD that was removed from the cluster
+	outputDir := t.TempDir()
+	removed := filepath.Join(outputDir, CRDDir, "olds.example.com", "definition.yaml")
+	data, _ := FormatYAML.Encode(newCRD("olds.example.com", "Old", 1).Object)
+	if err := writeFile(removed, data); err != nil {
+		t.Fatalf("failed to write previous export: %v", err)
+	}
+
+	stagingDir, err := CreateStagingDir(outputDir)
+	if err != nil {
+		t.Fatalf("CreateStagingDir failed: %v", err)
+	}
+	defer os.RemoveAll(stagingDir)
+
+	d := NewDumper(clientset, discovery)
+	d.SetDynamicClient(dynamicClient)
+	if _, err := d.DumpAllResources(context.Background(), stagingDir); err != nil {
+		t.Fatalf("DumpAllResources failed: %v", err)
+	}
+	if _, err := d.PromoteStaging(stagingDir, outputDir, true); err != nil {
+		t.Fatalf("PromoteStaging failed: %v", err)
+	}
+
+	crdDir := filepath.Join(outputDir, CRDDir, "widgets.example.com")
+	if _, err := os.Stat(filepath.Join(crdDir, "definition.yaml")); err != nil {
+		t.Errorf("expected the Widget CRD to be exported: %v", err)
+	}
+	schemaData, err := os.ReadFile(filepath.Join(crdDir, "v1.openapi.json"))
+	if err != nil {
+		t.Fatalf("expected the v1 schema to be exported: %v", err)
+	}
+	if !strings.Contains(string(schemaData), "ObjectMeta") || strings.Contains(string(schemaData), "Gadget") {
+		t.Errorf("schema should hold the Widget and the components it references only:\n%s", schemaData)
+	}
+	for _, path := range []string{
+		filepath.Join(crdDir, "v1beta1.openapi.json"),
+		filepath.Join(outputDir, CRDDir, "gadgets.example.com"),
+		filepath.Dir(removed),
+	} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s should not exist", path)
+		}
+	}
+
+	manifest, err := ReadManifest(outputDir)
+	if err != nil {
+		t.Fatalf("ReadManifest failed: %v", err)
+	}
+	if len(manifest.CRDs) != 1 || manifest.CRDs[0].Name != "widgets.example.com" || manifest.CRDs[0].StorageVersion != "v1" || len(manifest.CRDs[0].Versions) != 2 {
+		t.Fatalf("unexpected CRDs in manifest: %+v", manifest.CRDs)
+	}
+	var linked int
+	for _, entry := range manifest.Objects {
+		if entry.CRD != "" {
+			linked++
+		}
+		if entry.Kind == "Widget" && (entry.CRD != "widgets.example.com" || entry.CRDGeneration != 3) {
+			t.Errorf("Widget should be linked to its CRD revision, got %+v", entry)
+		}
+	}
+	if linked != 1 {
+		t.Errorf("expected only the Widget to be linked to a CRD, got %d entries", linked)
+	}
+}
+
+func TestAPIVersions(t *testing.T) {
+	hpa := func(version string) metav1.APIResourceList {
+		return metav1.APIResourceList{
+			GroupVersion: "autoscaling/" + version,
+			APIResources: []metav1.APIResource{
+				{Name: "horizontalpodautoscalers", Kind: "HorizontalPodAutoscaler", Namespaced: true, Verbs: metav1.Verbs{"list"}},
+			},
+		}
+	}
+	v1, v2 := hpa("v1"), hpa("v2")
+	newDumper := func() (*Dumper, *[]string) {
+		clientset := kubernetesfake.NewSimpleClientset(
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+		)
+		discovery := &preferredDiscovery{
+			FakeDiscovery: &discoveryfake.FakeDiscovery{
+				Fake: &k8stesting.Fake{Resources: []*metav1.APIResourceList{&v1, &v2}},
+			},
+			resources: []*metav1.APIResourceList{&v2},
+		}
+		dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+			map[schema.GroupVersionResource]string{
+				{Group: "autoscaling", Version: "v1", Resource: "horizontalpodautoscalers"}: "HorizontalPodAutoscalerList",
+				{Group: "autoscaling", Version: "v2", Resource: "horizontalpodautoscalers"}: "HorizontalPodAutoscalerList",
+			},
+			newTestObject("autoscaling/v1", "HorizontalPodAutoscaler", "default", "web"),
+			newTestObject("autoscaling/v2", "HorizontalPodAutoscaler", "default", "web"),
+		)
+		d := NewDumper(clientset, discovery)
+		d.SetDynamicClient(dynamicClient)
+		var warnings []string
+		d.SetOutputCallback(func(level, message string) {
+			if level == "WARNING" {
+				warnings = append(warnings, message)
+			}
+		})
+		return d, &warnings
+	}
+	apiVersionOf := func(path string) string {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("expected %s: %v", path, err)
+		}
+		objects, _ := ReadDocuments(data)
+		apiVersion, _ := objects[0]["apiVersion"].(string)
+		return apiVersion
+	}
+	file := filepath.Join("default", "HorizontalPodAutoscaler", "web.yaml")
+
+	// A pin replaces the preferred version; an additional version is written next to it
+	pins, err := ParseVersionPins([]string{"autoscaling=v1"})
+	if err != nil {
+		t.Fatalf("ParseVersionPins failed: %v", err)
+	}
+	additional, err := ParseGroupVersions([]string{"autoscaling/v2"})
+	if err != nil {
+		t.Fatalf("ParseGroupVersions failed: %v", err)
+	}
+	d, _ := newDumper()
+	d.SetVersionPins(pins)
+	d.SetAdditionalVersions(additional)
+	outputDir := t.TempDir()
+	if _, err := d.DumpAllResources(context.Background(), outputDir); err != nil {
+		t.Fatalf("DumpAllResources failed: %v", err)
+	}
+	if version := apiVersionOf(filepath.Join(outputDir, file)); version != "autoscaling/v1" {
+		t.Errorf("expected the pinned version, got %s", version)
+	}
+	if version := apiVersionOf(filepath.Join(outputDir, "default", "HorizontalPodAutoscaler", "web@v2.yaml")); version != "autoscaling/v2" {
+		t.Errorf("expected the additional version, got %s", version)
+	}
+
+	// Unserved pins fall back to the preferred version with a warning
+	d, warnings := newDumper()
+	d.SetVersionPins(map[string]string{"autoscaling": "v2beta2"})
+	outputDir = t.TempDir()
+	if _, err := d.DumpAllResources(context.Background(), outputDir); err != nil {
+		t.Fatalf("DumpAllResources failed: %v", err)
+	}
+	if version := apiVersionOf(filepath.Join(outputDir, file)); version != "autoscaling/v2" {
+		t.Errorf("expected the preferred version, got %s", version)
+	}
+	if len(*warnings) != 1 || !strings.Contains((*warnings)[0], "autoscaling/v2beta2") {
+		t.Errorf("expected a warning about the unserved pin, got %v", *warnings)
+	}
+
+	d, _ = newDumper()
+	d.SetAdditionalVersions(additional)
+	d.SetLayout(singleLayout{})
+	if _, err := d.DumpAllResources(context.Background(), t.TempDir()); err == nil {
+		t.Error("expected additional versions to be rejected for aggregated layouts")
+	}
+	for _, value := range []string{"autoscaling", "=v1"} {
+		if _, err := ParseVersionPins([]string{value}); err == nil {
+			t.Errorf("expected error for pin %q", value)
+		}
+	}
+}
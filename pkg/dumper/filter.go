@@ -0,0 +1,112 @@
+package dumper
+
+import (
+	"path"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// DefaultSkipResources are ephemeral resources that churn constantly and carry no
+// desired state. They are skipped unless explicitly selected with ResourceFilter.Resources.
+var DefaultSkipResources = []string{
+	"events",
+	"leases.coordination.k8s.io",
+	"componentstatuses",
+}
+
+// ResourceFilter narrows the scope of an export by namespace and resource kind.
+// All patterns are shell globs (e.g. "kube-*", "*.cert-manager.io").
+type ResourceFilter struct {
+	Namespaces        []string
+	ExcludeNamespaces []string
+	Resources         []string
+	ExcludeResources  []string
+}
+
+// IsEmpty reports whether the filter has no patterns configured
+func (f ResourceFilter) IsEmpty() bool {
+	return len(f.Namespaces) == 0 && len(f.ExcludeNamespaces) == 0 &&
+		len(f.Resources) == 0 && len(f.ExcludeResources) == 0
+}
+
+// IncludesNamespace reports whether resources in the given namespace should be exported
+func (f ResourceFilter) IncludesNamespace(namespace string) bool {
+	if len(f.Namespaces) > 0 && !matchAny(f.Namespaces, namespace) {
+		return false
+	}
+	return !matchAny(f.ExcludeNamespaces, namespace)
+}
+
+// IncludesResource reports whether the given API resource should be exported.
+// Patterns are matched against the Kind, the plural resource name, the API group
+// and the qualified "<resource>.<group>" form.
+func (f ResourceFilter) IncludesResource(gvr schema.GroupVersionResource, resource metav1.APIResource) bool {
+	candidates := resourceCandidates(gvr, resource)
+	if len(f.Resources) > 0 && !matchAnyCandidate(f.Resources, candidates) {
+		return false
+	}
+	return !matchAnyCandidate(f.ExcludeResources, candidates)
+}
+
+// isListable reports whether discovery advertises the list verb for a resource. Virtual
+// resources such as TokenReview or Binding only support create and cannot be exported.
+func isListable(resource metav1.APIResource) bool {
+	for _, verb := range resource.Verbs {
+		if verb == "list" {
+			return true
+		}
+	}
+	return false
+}
+
+// SetSkipResources replaces the patterns of ephemeral resources skipped during export
+// (DefaultSkipResources by default). An empty list exports every listable resource.
+func (d *Dumper) SetSkipResources(patterns []string) {
+	d.skipResources = patterns
+}
+
+// isSkipped reports whether a resource is on the skip list and was not explicitly
+// selected by the filter's include patterns
+func (d *Dumper) isSkipped(gvr schema.GroupVersionResource, resource metav1.APIResource) bool {
+	candidates := resourceCandidates(gvr, resource)
+	if !matchAnyCandidate(d.skipResources, candidates) {
+		return false
+	}
+	return !matchAnyCandidate(d.filter.Resources, candidates)
+}
+
+// resourceCandidates returns the names a resource pattern may match against
+func resourceCandidates(gvr schema.GroupVersionResource, resource metav1.APIResource) []string {
+	candidates := []string{resource.Kind, resource.Name}
+	if gvr.Group != "" {
+		candidates = append(candidates, gvr.Group, resource.Name+"."+gvr.Group, resource.Kind+"."+gvr.Group)
+	}
+	return candidates
+}
+
+// matchAnyCandidate reports whether any candidate matches any of the patterns
+func matchAnyCandidate(patterns, candidates []string) bool {
+	for _, candidate := range candidates {
+		if matchAny(patterns, candidate) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchAny reports whether value matches any of the glob patterns (case-insensitive)
+func matchAny(patterns []string, value string) bool {
+	value = strings.ToLower(value)
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if pattern == "" {
+			continue
+		}
+		if matched, err := path.Match(pattern, value); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
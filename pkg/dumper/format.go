@@ -0,0 +1,299 @@
+package dumper
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Format is the serialization of exported files
+type Format string
+
+const (
+	// FormatYAML writes canonical YAML (the default)
+	FormatYAML Format = "yaml"
+	// FormatJSON writes canonical, indented JSON
+	FormatJSON Format = "json"
+)
+
+// yamlIndent is fixed rather than left to the library default so output never shifts
+const yamlIndent = 4
+
+// podSpecQuantityPaths are the maps of a pod spec whose values are resource quantities
+var podSpecQuantityPaths = []string{
+	"containers.*.resources.limits", "containers.*.resources.requests",
+	"initContainers.*.resources.limits", "initContainers.*.resources.requests",
+	"ephemeralContainers.*.resources.limits", "ephemeralContainers.*.resources.requests",
+	"resources.limits", "resources.requests",
+	"overhead",
+}
+
+// quantityPaths are, per Kind, the maps whose string values are Kubernetes resource
+// quantities, as dotted paths where * stands for any list item. Only these core paths are
+// normalized; fields of other objects, including custom resources, are left as they are.
+var quantityPaths = func() map[schema.GroupKind]map[string]bool {
+	paths := make(map[schema.GroupKind]map[string]bool)
+	add := func(group, kind, prefix string, fields ...string) {
+		gk := schema.GroupKind{Group: group, Kind: kind}
+		if paths[gk] == nil {
+			paths[gk] = make(map[string]bool)
+		}
+		for _, field := range fields {
+			paths[gk][prefix+field] = true
+		}
+	}
+
+	// Container resources of pods and of the pod templates of workloads
+	add("", "Pod", "spec.", podSpecQuantityPaths...)
+	add("", "PodTemplate", "template.spec.", podSpecQuantityPaths...)
+	add("", "ReplicationController", "spec.template.spec.", podSpecQuantityPaths...)
+	for _, kind := range []string{"Deployment", "ReplicaSet", "StatefulSet", "DaemonSet"} {
+		add("apps", kind, "spec.template.spec.", podSpecQuantityPaths...)
+	}
+	add("apps", "StatefulSet", "spec.volumeClaimTemplates.*.spec.resources.", "limits", "requests")
+	add("batch", "Job", "spec.template.spec.", podSpecQuantityPaths...)
+	add("batch", "CronJob", "spec.jobTemplate.spec.template.spec.", podSpecQuantityPaths...)
+
+	add("", "ResourceQuota", "", "spec.hard", "status.hard", "status.used")
+	add("", "LimitRange", "spec.limits.*.", "max", "min", "default", "defaultRequest", "maxLimitRequestRatio")
+	add("", "Node", "status.", "capacity", "allocatable")
+	add("", "PersistentVolumeClaim", "", "spec.resources.limits", "spec.resources.requests", "status.capacity", "status.allocatedResources")
+	add("", "PersistentVolume", "spec.", "capacity")
+	return paths
+}()
+
+// plainReserved are words YAML parsers may read as booleans or null when unquoted
+var plainReserved = map[string]bool{
+	"true": true, "false": true, "yes": true, "no": true, "on": true, "off": true,
+	"y": true, "n": true, "null": true,
+}
+
+// ParseFormat validates an output format name
+func ParseFormat(value string) (Format, error) {
+	switch format := Format(strings.ToLower(strings.TrimSpace(value))); format {
+	case FormatYAML, FormatJSON:
+		return format, nil
+	case "":
+		return FormatYAML, nil
+	default:
+		return "", fmt.Errorf("invalid format '%s' (expected yaml or json)", value)
+	}
+}
+
+// SetFormat sets the serialization of exported files
+func (d *Dumper) SetFormat(format Format) {
+	d.format = format
+}
+
+// Extension returns the file extension of the format, including the dot
+func (f Format) Extension() string {
+	if f == FormatJSON {
+		return ".json"
+	}
+	return ".yaml"
+}
+
+// withExtension replaces the .yaml extension of a layout path by the format's extension
+func (f Format) withExtension(path string) string {
+	return strings.TrimSuffix(path, ".yaml") + f.Extension()
+}
+
+// Encode serializes an object canonically: keys are sorted, quantities are normalized
+// and scalar styles are chosen by kalco rather than the library, so an unchanged object
+// always produces byte-identical output
+func (f Format) Encode(object map[string]interface{}) ([]byte, error) {
+	canonical := canonicalize(object, objectQuantityPaths(object), "")
+
+	var out bytes.Buffer
+	if f == FormatJSON {
+		encoder := json.NewEncoder(&out)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(canonical); err != nil {
+			return nil, fmt.Errorf("failed to marshal resource to JSON: %w", err)
+		}
+		return out.Bytes(), nil
+	}
+
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(yamlIndent)
+	if err := encoder.Encode(yamlNode(canonical)); err != nil {
+		return nil, fmt.Errorf("failed to marshal resource to YAML: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("failed to marshal resource to YAML: %w", err)
+	}
+	return out.Bytes(), nil
+}
+
+// join renders documents as one file: multi-document YAML, or a JSON List
+func (f Format) join(documents []document) ([]byte, error) {
+	if f != FormatJSON {
+		return joinDocuments(documents), nil
+	}
+
+	items := make([]json.RawMessage, 0, len(documents))
+	for _, doc := range documents {
+		items = append(items, json.RawMessage(doc.data))
+	}
+	var out bytes.Buffer
+	encoder := json.NewEncoder(&out)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	err := encoder.Encode(map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "List",
+		"items":      items,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal list to JSON: %w", err)
+	}
+	return out.Bytes(), nil
+}
+
+// isExportedFileName reports whether a file name has the extension of an output format
+func isExportedFileName(name string) bool {
+	ext := filepath.Ext(name)
+	return ext == FormatYAML.Extension() || ext == FormatJSON.Extension()
+}
+
+// objectQuantityPaths returns the quantity paths of an object's Kind
+func objectQuantityPaths(object map[string]interface{}) map[string]bool {
+	apiVersion, _ := object["apiVersion"].(string)
+	kind, _ := object["kind"].(string)
+	gv, err := schema.ParseGroupVersion(apiVersion)
+	if err != nil {
+		return nil
+	}
+	return quantityPaths[gv.WithKind(kind).GroupKind()]
+}
+
+// canonicalize returns a copy of value, found at path, with the resource quantities of
+// the given quantity paths in canonical form
+func canonicalize(value interface{}, quantities map[string]bool, path string) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			if quantities[path] {
+				out[k] = canonicalQuantity(item)
+				continue
+			}
+			out[k] = canonicalize(item, quantities, fieldPath(path, k))
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = canonicalize(item, quantities, fieldPath(path, "*"))
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// fieldPath appends a field, or * for a list item, to a dotted path
+func fieldPath(path, field string) string {
+	if path == "" {
+		return field
+	}
+	return path + "." + field
+}
+
+// canonicalQuantity rewrites a quantity string such as "1000m" or "1024Mi" to its
+// canonical form ("1", "1Gi"); anything else is returned unchanged
+func canonicalQuantity(value interface{}) interface{} {
+	s, ok := value.(string)
+	if !ok {
+		return canonicalize(value, nil, "")
+	}
+	quantity, err := resource.ParseQuantity(s)
+	if err != nil {
+		return s
+	}
+	return quantity.String()
+}
+
+// yamlNode builds the YAML node tree of a canonical value with sorted mapping keys
+func yamlNode(value interface{}) *yaml.Node {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		for _, key := range keys {
+			node.Content = append(node.Content, stringNode(key), yamlNode(v[key]))
+		}
+		return node
+	case []interface{}:
+		node := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for _, item := range v {
+			node.Content = append(node.Content, yamlNode(item))
+		}
+		return node
+	case string:
+		return stringNode(v)
+	case bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: strconv.FormatBool(v)}
+	case int64:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.FormatInt(v, 10)}
+	case int:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.Itoa(v)}
+	case float64:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!float", Value: strconv.FormatFloat(v, 'g', -1, 64)}
+	case nil:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}
+	default:
+		return stringNode(fmt.Sprint(v))
+	}
+}
+
+// stringNode renders a string: multi-line strings as literal blocks, strings that would
+// be read back as something else double-quoted, and everything else plain
+func stringNode(s string) *yaml.Node {
+	node := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: s}
+	switch {
+	case strings.Contains(s, "\n"):
+		node.Style = yaml.LiteralStyle
+	case !isPlainSafe(s):
+		node.Style = yaml.DoubleQuotedStyle
+	}
+	return node
+}
+
+// isPlainSafe reports whether a string reads back as the same string when written unquoted
+func isPlainSafe(s string) bool {
+	if s == "" || plainReserved[strings.ToLower(s)] {
+		return false
+	}
+
+	// Fast path for identifiers, names and paths
+	simple := s[0] >= 'a' && s[0] <= 'z' || s[0] >= 'A' && s[0] <= 'Z'
+	for i := 0; simple && i < len(s); i++ {
+		c := s[i]
+		simple = c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '/'
+	}
+	if simple {
+		return true
+	}
+
+	var decoded interface{}
+	if err := yaml.Unmarshal([]byte(s), &decoded); err != nil {
+		return false
+	}
+	return decoded == s
+}
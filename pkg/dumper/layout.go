@@ -0,0 +1,351 @@
+package dumper
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// ClusterScopeDir is the namespace placeholder used in paths of cluster-scoped objects
+const ClusterScopeDir = "_cluster"
+
+// Layout maps exported objects to files in the output directory
+type Layout interface {
+	// Name returns the name the layout is selected by
+	Name() string
+	// Path returns the file holding an object, relative to the output directory, with a
+	// .yaml extension that is replaced for other formats. Cluster-scoped objects have an
+	// empty namespace.
+	Path(namespace, kind, name string) string
+	// Aggregated reports whether a file holds several objects (multi-document YAML or a JSON List)
+	Aggregated() bool
+}
+
+// Built-in layout names
+const (
+	LayoutTree      = "tree"
+	LayoutNamespace = "namespace"
+	LayoutKind      = "kind"
+	LayoutSingle    = "single"
+	LayoutFlat      = "flat"
+)
+
+// LayoutNames lists the built-in layouts in the order they are documented
+var LayoutNames = []string{LayoutTree, LayoutNamespace, LayoutKind, LayoutSingle, LayoutFlat}
+
+// ParseLayout returns the built-in layout with the given name (tree when empty)
+func ParseLayout(value string) (Layout, error) {
+	switch name := strings.ToLower(strings.TrimSpace(value)); name {
+	case LayoutTree, "":
+		return treeLayout{}, nil
+	case LayoutNamespace:
+		return namespaceLayout{}, nil
+	case LayoutKind:
+		return kindLayout{}, nil
+	case LayoutSingle:
+		return singleLayout{}, nil
+	case LayoutFlat:
+		return flatLayout{}, nil
+	default:
+		return nil, fmt.Errorf("invalid layout '%s' (expected %s)", value, strings.Join(LayoutNames, ", "))
+	}
+}
+
+// SetLayout sets how exported objects are organized into files
+func (d *Dumper) SetLayout(layout Layout) {
+	d.layout = layout
+}
+
+// scopeDir returns the namespace, or the cluster placeholder for cluster-scoped objects
+func scopeDir(namespace string) string {
+	if namespace == "" {
+		return ClusterScopeDir
+	}
+	return namespace
+}
+
+// treeLayout writes <namespace>/<Kind>/<name>.yaml, the default layout
+type treeLayout struct{}
+
+func (treeLayout) Name() string     { return LayoutTree }
+func (treeLayout) Aggregated() bool { return false }
+func (treeLayout) Path(namespace, kind, name string) string {
+	return filepath.Join(scopeDir(namespace), kind, name+".yaml")
+}
+
+// namespaceLayout writes one multi-document <namespace>.yaml per namespace
+type namespaceLayout struct{}
+
+func (namespaceLayout) Name() string     { return LayoutNamespace }
+func (namespaceLayout) Aggregated() bool { return true }
+func (namespaceLayout) Path(namespace, kind, name string) string {
+	return scopeDir(namespace) + ".yaml"
+}
+
+// kindLayout writes one multi-document <Kind>.yaml per Kind
+type kindLayout struct{}
+
+func (kindLayout) Name() string     { return LayoutKind }
+func (kindLayout) Aggregated() bool { return true }
+func (kindLayout) Path(namespace, kind, name string) string {
+	return kind + ".yaml"
+}
+
+// singleLayout writes every object to a single multi-document all.yaml
+type singleLayout struct{}
+
+func (singleLayout) Name() string     { return LayoutSingle }
+func (singleLayout) Aggregated() bool { return true }
+func (singleLayout) Path(namespace, kind, name string) string {
+	return "all.yaml"
+}
+
+// flatLayout writes <namespace>_<Kind>_<name>.yaml files into the output directory itself
+type flatLayout struct{}
+
+func (flatLayout) Name() string     { return LayoutFlat }
+func (flatLayout) Aggregated() bool { return false }
+func (flatLayout) Path(namespace, kind, name string) string {
+	return scopeDir(namespace) + "_" + kind + "_" + name + ".yaml"
+}
+
+// parseLayoutPath returns the namespace and Kind of a per-object layout file, as far as
+// its path tells. Cluster-scoped files have an empty namespace.
+func parseLayoutPath(layout Layout, rel string) (namespace, kind string, ok bool) {
+	if !isExportedFileName(rel) {
+		return "", "", false
+	}
+	name := strings.TrimSuffix(filepath.Base(rel), filepath.Ext(rel))
+	name = strings.TrimSuffix(name, ".status")
+
+	var parts []string
+	switch layout.(type) {
+	case treeLayout:
+		parts = strings.Split(rel, string(os.PathSeparator))
+	case flatLayout:
+		if filepath.Dir(rel) != "." {
+			return "", "", false
+		}
+		parts = strings.SplitN(name, "_", 3)
+		if strings.HasPrefix(name, ClusterScopeDir+"_") {
+			parts = append([]string{ClusterScopeDir}, strings.SplitN(strings.TrimPrefix(name, ClusterScopeDir+"_"), "_", 2)...)
+		}
+	default:
+		return "", "", false
+	}
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	if parts[0] == ClusterScopeDir {
+		return "", parts[1], true
+	}
+	return parts[0], parts[1], true
+}
+
+// objectKey identifies an exported object, or its separately captured status. Version
+// is only set for copies of an object exported in an additional API version.
+type objectKey struct {
+	Group     string
+	Kind      string
+	Namespace string
+	Name      string
+	Status    bool
+	Version   string
+}
+
+// String describes the object for messages
+func (k objectKey) String() string {
+	kind := k.Kind
+	if k.Group != "" {
+		kind += "." + k.Group
+	}
+	id := kind + " " + k.Name
+	if k.Namespace != "" {
+		id = kind + " " + k.Namespace + "/" + k.Name
+	}
+	if k.Version != "" {
+		id += "@" + k.Version
+	}
+	if k.Status {
+		id += " (status)"
+	}
+	return id
+}
+
+// document is a single object of a multi-document file
+type document struct {
+	key  objectKey
+	data []byte
+}
+
+// sortDocuments orders documents by Kind, group, namespace and name so aggregated files are deterministic
+func sortDocuments(documents []document) {
+	sort.Slice(documents, func(i, j int) bool {
+		a, b := documents[i].key, documents[j].key
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Group != b.Group {
+			return a.Group < b.Group
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		if a.Version != b.Version {
+			return a.Version < b.Version
+		}
+		return !a.Status && b.Status
+	})
+}
+
+// joinDocuments renders documents as multi-document YAML
+func joinDocuments(documents []document) []byte {
+	var out bytes.Buffer
+	for i, doc := range documents {
+		if i > 0 {
+			out.WriteString("---\n")
+		}
+		out.Write(doc.data)
+	}
+	return out.Bytes()
+}
+
+// ReadDocuments parses an exported file into its objects. It reads single and
+// multi-document YAML as well as JSON objects and Lists.
+func ReadDocuments(data []byte) ([]map[string]interface{}, error) {
+	var objects []map[string]interface{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var object map[string]interface{}
+		if err := decoder.Decode(&object); err != nil {
+			if errors.Is(err, io.EOF) {
+				return objects, nil
+			}
+			return nil, err
+		}
+		if items, ok := object["items"].([]interface{}); ok && object["kind"] == "List" {
+			for _, item := range items {
+				if itemObject, ok := item.(map[string]interface{}); ok {
+					objects = append(objects, itemObject)
+				}
+			}
+			continue
+		}
+		if object != nil {
+			objects = append(objects, object)
+		}
+	}
+}
+
+// ObjectIdentity returns the Kind, namespace and name of an exported object
+func ObjectIdentity(object map[string]interface{}) (kind, namespace, name string, ok bool) {
+	kind, _ = object["kind"].(string)
+	metadata, _ := object["metadata"].(map[string]interface{})
+	name, _ = metadata["name"].(string)
+	namespace, _ = metadata["namespace"].(string)
+	return kind, namespace, name, kind != "" && name != ""
+}
+
+// readDocuments parses an exported file into keyed documents serialized in the given
+// format. Documents without an identity make the whole file unparseable, so it is
+// preserved as-is.
+func readDocuments(path string, format Format) ([]document, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	objects, err := ReadDocuments(data)
+	if err != nil || len(objects) == 0 {
+		return nil, false
+	}
+
+	isStatus := IsStatusFile(path)
+	documents := make([]document, 0, len(objects))
+	for _, object := range objects {
+		kind, namespace, name, ok := ObjectIdentity(object)
+		if !ok {
+			return nil, false
+		}
+		encoded, err := format.Encode(object)
+		if err != nil {
+			return nil, false
+		}
+		apiVersion, _ := object["apiVersion"].(string)
+		gv, _ := schema.ParseGroupVersion(apiVersion)
+		documents = append(documents, document{
+			key:  objectKey{Group: gv.Group, Kind: kind, Namespace: namespace, Name: name, Status: isStatus},
+			data: encoded,
+		})
+	}
+	return documents, true
+}
+
+// addDocument buffers a document of an aggregated file until the export finishes. A
+// document for an object already buffered replaces the earlier one.
+func (r *exportRun) addDocument(rel string, doc document) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.documents[rel] == nil {
+		r.documents[rel] = make(map[objectKey]document)
+	}
+	r.documents[rel][doc.key] = doc
+}
+
+// recordExported marks an object as written by the current run
+func (r *exportRun) recordExported(key objectKey, entry ManifestEntry) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.exported[key] = entry
+}
+
+// isExported reports whether the current run wrote an object
+func (r *exportRun) isExported(key objectKey) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.exported[key]
+	return ok
+}
+
+// writeDocuments writes the buffered aggregated files, sorted deterministically
+func (r *exportRun) writeDocuments(format Format) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for rel, byKey := range r.documents {
+		documents := make([]document, 0, len(byKey))
+		for _, doc := range byKey {
+			documents = append(documents, doc)
+		}
+		sortDocuments(documents)
+		data, err := format.join(documents)
+		if err != nil {
+			return err
+		}
+		if err := writeFile(filepath.Join(r.outputDir, rel), data); err != nil {
+			return fmt.Errorf("failed to write %s: %w", rel, err)
+		}
+	}
+	return nil
+}
+
+// writeFile writes a file, creating its directory only once it actually receives a file
+func writeFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
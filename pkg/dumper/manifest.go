@@ -0,0 +1,218 @@
+package dumper
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// ManifestFileName is the index of an export, written at the top of the output directory
+const ManifestFileName = "kalco-manifest.json"
+
+// Manifest is the machine-readable record of what an export contained
+type Manifest struct {
+	ExportTime     time.Time       `json:"exportTime"`
+	ClusterVersion string          `json:"clusterVersion,omitempty"`
+	Layout         string          `json:"layout"`
+	Format         Format          `json:"format"`
+	CRDs           []ManifestCRD   `json:"crds,omitempty"`
+	Objects        []ManifestEntry `json:"objects"`
+}
+
+// ManifestEntry describes an exported object, or its separately captured status, and the
+// file holding it. SHA256 is the checksum of the object's document, which is the whole
+// file for per-object layouts. Custom resources name the CRD, and the generation of the
+// CRD, they were exported under.
+type ManifestEntry struct {
+	APIVersion      string `json:"apiVersion"`
+	Kind            string `json:"kind"`
+	Namespace       string `json:"namespace,omitempty"`
+	Name            string `json:"name"`
+	UID             string `json:"uid,omitempty"`
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+	Status          bool   `json:"status,omitempty"`
+	File            string `json:"file"`
+	SHA256          string `json:"sha256"`
+	CRD             string `json:"crd,omitempty"`
+	CRDGeneration   int64  `json:"crdGeneration,omitempty"`
+}
+
+// key returns the identity of the object the entry describes
+func (e ManifestEntry) key() objectKey {
+	gv, _ := schema.ParseGroupVersion(e.APIVersion)
+	return objectKey{Group: gv.Group, Kind: e.Kind, Namespace: e.Namespace, Name: e.Name, Status: e.Status}
+}
+
+// ReadManifest reads the manifest of the export in dir
+func ReadManifest(dir string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, ManifestFileName))
+	if err != nil {
+		return nil, err
+	}
+	return ParseManifest(data)
+}
+
+// ParseManifest parses the content of a manifest, e.g. as read from a commit
+func ParseManifest(data []byte) (*Manifest, error) {
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", ManifestFileName, err)
+	}
+	return &manifest, nil
+}
+
+// newManifestEntry describes an object from its own fields; uid and resourceVersion are
+// only known when the cleanup profile has not stripped them yet
+func newManifestEntry(object map[string]interface{}) ManifestEntry {
+	apiVersion, _ := object["apiVersion"].(string)
+	kind, namespace, name, _ := ObjectIdentity(object)
+	metadata, _ := object["metadata"].(map[string]interface{})
+	uid, _ := metadata["uid"].(string)
+	resourceVersion, _ := metadata["resourceVersion"].(string)
+	return ManifestEntry{
+		APIVersion:      apiVersion,
+		Kind:            kind,
+		Namespace:       namespace,
+		Name:            name,
+		UID:             uid,
+		ResourceVersion: resourceVersion,
+	}
+}
+
+// locate sets the file and checksum of an entry from the document written for it
+func (e ManifestEntry) locate(rel string, data []byte) ManifestEntry {
+	sum := sha256.Sum256(data)
+	e.File = filepath.ToSlash(rel)
+	e.SHA256 = hex.EncodeToString(sum[:])
+	return e
+}
+
+// manifestIndex looks up the entries of a previous manifest, so objects carried over
+// keep the uid and resourceVersion they were exported with
+type manifestIndex struct {
+	byFile map[string][]ManifestEntry
+	byKey  map[objectKey]ManifestEntry
+}
+
+// newManifestIndex indexes the manifest of dir; a missing or unreadable manifest yields an empty index
+func newManifestIndex(dir string) manifestIndex {
+	index := manifestIndex{
+		byFile: make(map[string][]ManifestEntry),
+		byKey:  make(map[objectKey]ManifestEntry),
+	}
+	manifest, err := ReadManifest(dir)
+	if err != nil {
+		return index
+	}
+	for _, entry := range manifest.Objects {
+		index.byFile[entry.File] = append(index.byFile[entry.File], entry)
+		index.byKey[entry.key()] = entry
+	}
+	return index
+}
+
+// fileEntries returns the entry of a per-object file copied unchanged from a previous
+// export, taken from the previous manifest when it lists the file. The checksum is always
+// computed from the file itself.
+func (i manifestIndex) fileEntries(dir, rel string) []ManifestEntry {
+	data, err := os.ReadFile(filepath.Join(dir, rel))
+	if err != nil {
+		return nil
+	}
+	if entries := i.byFile[filepath.ToSlash(rel)]; len(entries) == 1 {
+		return []ManifestEntry{entries[0].locate(rel, data)}
+	}
+	objects, err := ReadDocuments(data)
+	if err != nil || len(objects) != 1 {
+		return nil
+	}
+	if _, _, _, ok := ObjectIdentity(objects[0]); !ok {
+		return nil
+	}
+	entry := newManifestEntry(objects[0])
+	entry.Status = IsStatusFile(rel)
+	return []ManifestEntry{entry.locate(rel, data)}
+}
+
+// documentEntry returns the entry of an object carried over into target
+func (i manifestIndex) documentEntry(doc document, target string) ManifestEntry {
+	entry, ok := i.byKey[doc.key]
+	if !ok {
+		if objects, err := ReadDocuments(doc.data); err == nil && len(objects) == 1 {
+			entry = newManifestEntry(objects[0])
+		}
+		entry.Kind, entry.Namespace, entry.Name, entry.Status = doc.key.Kind, doc.key.Namespace, doc.key.Name, doc.key.Status
+	}
+	return entry.locate(target, doc.data)
+}
+
+// writeManifest writes the manifest of the current export, plus objects and CRDs carried
+// over from a previous one, into dir. Entries are sorted by file and object so unchanged
+// exports only differ in their timestamp and resource versions.
+func (d *Dumper) writeManifest(dir string, carried []ManifestEntry, carriedCRDs []ManifestCRD) error {
+	d.run.mu.Lock()
+	entries := make([]ManifestEntry, 0, len(d.run.exported)+len(carried))
+	for _, entry := range d.run.exported {
+		entries = append(entries, entry)
+	}
+	crds := append(append([]ManifestCRD{}, d.run.crds...), carriedCRDs...)
+	d.run.mu.Unlock()
+	entries = append(entries, carried...)
+
+	// Link custom resources to the CRD revision they were exported under
+	sort.Slice(crds, func(i, j int) bool { return crds[i].Name < crds[j].Name })
+	byKind := make(map[schema.GroupKind]ManifestCRD, len(crds))
+	for _, crd := range crds {
+		byKind[schema.GroupKind{Group: crd.Group, Kind: crd.Kind}] = crd
+	}
+	for i, entry := range entries {
+		key := entry.key()
+		if crd, ok := byKind[schema.GroupKind{Group: key.Group, Kind: key.Kind}]; ok {
+			entries[i].CRD, entries[i].CRDGeneration = crd.Name, crd.Generation
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.APIVersion != b.APIVersion {
+			return a.APIVersion < b.APIVersion
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return !a.Status && b.Status
+	})
+
+	manifest := Manifest{
+		ExportTime:     d.run.results.result.StartTime.UTC(),
+		ClusterVersion: d.run.clusterVersion,
+		Layout:         d.layout.Name(),
+		Format:         d.format,
+		CRDs:           crds,
+		Objects:        entries,
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+	if err := writeFile(filepath.Join(dir, ManifestFileName), append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write %s: %w", ManifestFileName, err)
+	}
+	return nil
+}
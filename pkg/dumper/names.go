@@ -0,0 +1,97 @@
+package dumper
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// EscapeName makes an object name safe to use as a file name on every filesystem and in
+// every layout. ASCII letters, digits, '-' and '.' are kept; every other byte, and a
+// leading '.', is written as %XX, so "system:controller:job" becomes
+// "system%3Acontroller%3Ajob". The scheme is deterministic and reversed by UnescapeName.
+func EscapeName(name string) string {
+	var out strings.Builder
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		safe := c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '.'
+		if safe && !(i == 0 && c == '.') {
+			out.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&out, "%%%02X", c)
+	}
+	return out.String()
+}
+
+// UnescapeName recovers the object name from a file name written with EscapeName.
+// Malformed escapes are kept as they are.
+func UnescapeName(escaped string) string {
+	var out strings.Builder
+	for i := 0; i < len(escaped); i++ {
+		if escaped[i] == '%' && i+2 < len(escaped) {
+			if value, err := strconv.ParseUint(escaped[i+1:i+3], 16, 8); err == nil {
+				out.WriteByte(byte(value))
+				i += 2
+				continue
+			}
+		}
+		out.WriteByte(escaped[i])
+	}
+	return out.String()
+}
+
+// ParseKindDir splits a Kind directory name into the Kind and, for group-qualified
+// directories such as "Certificate.cert-manager.io", the API group
+func ParseKindDir(dir string) (kind, group string) {
+	kind, group, _ = strings.Cut(dir, ".")
+	return kind, group
+}
+
+// kindDirectories maps every discovered API group and Kind to its directory name. A Kind
+// served by several API groups (e.g. Event in core and events.k8s.io, or two CRDs named
+// Certificate) is qualified with its group, except in the core group, so their objects
+// never overwrite each other. The mapping only depends on discovery, not on filters.
+func kindDirectories(resourceLists []*metav1.APIResourceList) map[schema.GroupKind]string {
+	groupsByKind := make(map[string]map[string]bool)
+	for _, resourceList := range resourceLists {
+		gv, err := schema.ParseGroupVersion(resourceList.GroupVersion)
+		if err != nil {
+			continue
+		}
+		for _, resource := range resourceList.APIResources {
+			if strings.Contains(resource.Name, "/") {
+				continue
+			}
+			if groupsByKind[resource.Kind] == nil {
+				groupsByKind[resource.Kind] = make(map[string]bool)
+			}
+			groupsByKind[resource.Kind][gv.Group] = true
+		}
+	}
+
+	dirs := make(map[schema.GroupKind]string)
+	for kind, groups := range groupsByKind {
+		for group := range groups {
+			dir := kind
+			if len(groups) > 1 && group != "" {
+				dir = kind + "." + group
+			}
+			dirs[schema.GroupKind{Group: group, Kind: kind}] = dir
+		}
+	}
+	return dirs
+}
+
+// kindDir returns the directory name of a group and Kind in the current export
+func (d *Dumper) kindDir(group, kind string) string {
+	if d.run != nil {
+		if dir, ok := d.run.kindDirs[schema.GroupKind{Group: group, Kind: kind}]; ok {
+			return dir
+		}
+	}
+	return kind
+}
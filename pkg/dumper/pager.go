@@ -0,0 +1,65 @@
+package dumper
+
+import (
+	"context"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+)
+
+// DefaultPageSize is the default number of objects requested per List call
+const DefaultPageSize int64 = 500
+
+// maxListRestarts bounds how often a list is restarted after its continue token expired
+const maxListRestarts = 3
+
+// pageHandler processes a single page of listed objects
+type pageHandler func(items []unstructured.Unstructured) error
+
+// listPages lists a resource in chunks of the configured page size and hands each page
+// to handle as soon as it arrives, so large collections are never held in memory at once.
+// If the continue token expires (410 Gone) the list is restarted from the beginning, and
+// objects already handed to handle are skipped so each object is delivered once.
+func (d *Dumper) listPages(ctx context.Context, client dynamic.ResourceInterface, log *taskLog, handle pageHandler) error {
+	opts := metav1.ListOptions{Limit: d.pageSize}
+	restarts := 0
+	delivered := make(map[string]bool)
+
+	for {
+		list, err := client.List(ctx, opts)
+		if err != nil {
+			if ctx.Err() == nil && opts.Continue != "" && (apierrors.IsResourceExpired(err) || apierrors.IsGone(err)) && restarts < maxListRestarts {
+				restarts++
+				log.add("WARNING", "continue token expired, restarting list from the beginning")
+				opts.Continue = ""
+				continue
+			}
+			return err
+		}
+
+		items := list.Items
+		if restarts > 0 {
+			items = items[:0:0]
+			for _, item := range list.Items {
+				if !delivered[item.GetNamespace()+"/"+item.GetName()] {
+					items = append(items, item)
+				}
+			}
+		}
+		if list.GetContinue() != "" || restarts > 0 {
+			for _, item := range items {
+				delivered[item.GetNamespace()+"/"+item.GetName()] = true
+			}
+		}
+		if err := handle(items); err != nil {
+			return err
+		}
+
+		if list.GetContinue() == "" {
+			return nil
+		}
+		opts.Continue = list.GetContinue()
+	}
+}
@@ -0,0 +1,110 @@
+package dumper
+
+import (
+	"context"
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// DefaultConcurrency is the default number of list operations run in parallel
+const DefaultConcurrency = 8
+
+// dumpTask describes a single list operation performed during export
+type dumpTask struct {
+	gvr        schema.GroupVersionResource
+	resource   metav1.APIResource
+	namespaces []string
+}
+
+// logEntry is a single buffered output message
+type logEntry struct {
+	level   string
+	message string
+}
+
+// taskLog buffers the output of a task so it can be replayed in a stable order
+type taskLog struct {
+	entries []logEntry
+}
+
+// add records an output message
+func (l *taskLog) add(level, message string) {
+	l.entries = append(l.entries, logEntry{level: level, message: message})
+}
+
+// flush replays the buffered messages to the output callback
+func (l *taskLog) flush(callback OutputCallback) {
+	if callback == nil {
+		return
+	}
+	for _, entry := range l.entries {
+		callback(entry.level, entry.message)
+	}
+}
+
+// groupTasks batches tasks that write into the same Kind directory so they run
+// sequentially in discovery order. Kinds served by several API groups (e.g. Event)
+// would otherwise race on the same files and make the output nondeterministic.
+func groupTasks(tasks []dumpTask) [][]dumpTask {
+	var jobs [][]dumpTask
+	index := make(map[string]int)
+	for _, task := range tasks {
+		key := task.resource.Kind
+		if i, exists := index[key]; exists {
+			jobs[i] = append(jobs[i], task)
+			continue
+		}
+		index[key] = len(jobs)
+		jobs = append(jobs, []dumpTask{task})
+	}
+	return jobs
+}
+
+// runTasks executes jobs on a bounded worker pool. Output is buffered per job and
+// emitted in job order, so callback ordering does not depend on scheduling. Jobs
+// still queued when ctx is cancelled are skipped.
+func (d *Dumper) runTasks(ctx context.Context, jobs [][]dumpTask, outputDir string) {
+	workers := d.concurrency
+	if workers < 1 {
+		workers = 1
+	}
+
+	results := make([]chan *taskLog, len(jobs))
+	for i := range results {
+		results[i] = make(chan *taskLog, 1)
+	}
+
+	queue := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				log := &taskLog{}
+				for _, task := range jobs[i] {
+					// Stop starting new list operations once the export is cancelled
+					if ctx.Err() != nil {
+						break
+					}
+					d.runTask(ctx, task, outputDir, log)
+				}
+				results[i] <- log
+			}
+		}()
+	}
+
+	go func() {
+		for i := range jobs {
+			queue <- i
+		}
+		close(queue)
+	}()
+
+	for i := range jobs {
+		(<-results[i]).flush(d.outputCallback)
+	}
+	wg.Wait()
+}
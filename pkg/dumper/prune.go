@@ -0,0 +1,222 @@
+package dumper
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// reservedEntries are top-level entries of the output directory that are never pruned
+var reservedEntries = map[string]bool{
+	".git":              true,
+	".gitignore":        true,
+	"kalco-reports":     true,
+	"kalco-config.json": true,
+	ManifestFileName:    true,
+}
+
+// exportRun tracks what a single DumpAllResources call touched
+type exportRun struct {
+	mu        sync.Mutex
+	outputDir string
+	planned   map[string]bool
+	failed    map[string]bool
+	results   *resultCollector
+
+	// exported holds the manifest entry of every object written, documents the buffered
+	// files of aggregated layouts keyed by object
+	exported  map[objectKey]ManifestEntry
+	documents map[string]map[objectKey]document
+
+	// kindDirs maps discovered groups and Kinds to their directory names
+	kindDirs map[schema.GroupKind]string
+
+	// additional holds the group versions exported in addition to the exported version
+	additional map[string]bool
+
+	// clusterVersion is the server version reported by discovery, when available
+	clusterVersion string
+
+	// crds are the CustomResourceDefinitions written to the _crds directory; crdsListed
+	// is set once listing them succeeded
+	crds       []ManifestCRD
+	crdsListed bool
+
+	// discoveryIncomplete is set when some API groups could not be discovered
+	discoveryIncomplete bool
+}
+
+// newExportRun creates the tracking state for an export into outputDir
+func newExportRun(outputDir string) *exportRun {
+	return &exportRun{
+		outputDir:  outputDir,
+		planned:    make(map[string]bool),
+		failed:     make(map[string]bool),
+		results:    newResultCollector(),
+		exported:   make(map[objectKey]ManifestEntry),
+		documents:  make(map[string]map[objectKey]document),
+		kindDirs:   make(map[schema.GroupKind]string),
+		additional: make(map[string]bool),
+	}
+}
+
+// recordPlanned marks a Kind directory as exported by the current run
+func (r *exportRun) recordPlanned(kind string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.planned[kind] = true
+}
+
+// recordFailure marks a Kind directory whose listing failed, so its files are left untouched
+func (r *exportRun) recordFailure(kind string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failed[kind] = true
+}
+
+// recordDiscoveryFailure marks the run as missing some API groups, so files of kinds
+// that were not exported are kept: they may belong to an undiscovered group
+func (r *exportRun) recordDiscoveryFailure() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.discoveryIncomplete = true
+}
+
+// staleFile is a file of a previous export that the current export did not refresh.
+// Files checked object by object carry their documents instead of a prunable flag.
+type staleFile struct {
+	path      string
+	prunable  bool
+	documents []document
+}
+
+// isResourceEntry reports whether a top-level entry of the output directory holds exported
+// resources: a directory, or a file written by a layout without directories
+func isResourceEntry(entry fs.DirEntry) bool {
+	name := entry.Name()
+	if reservedEntries[name] || strings.HasPrefix(name, ".") {
+		return false
+	}
+	return entry.IsDir() || isExportedFileName(name)
+}
+
+// IsResourceFile reports whether a path relative to the output directory is a file
+// written by an export, as opposed to CRD definitions, reports, configuration or Git metadata
+func IsResourceFile(rel string) bool {
+	rel = filepath.ToSlash(rel)
+	top := strings.SplitN(rel, "/", 2)[0]
+	if reservedEntries[top] || strings.HasPrefix(top, ".") || top == CRDDir {
+		return false
+	}
+	return isExportedFileName(rel)
+}
+
+// walkResourceFiles calls fn with the path, relative to dir, of every exported file in dir
+func walkResourceFiles(dir string, fn func(rel string) error) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	for _, entry := range entries {
+		// CRD definitions are replaced as a whole, see carryCRDs
+		if !isResourceEntry(entry) || entry.Name() == CRDDir {
+			continue
+		}
+		if !entry.IsDir() {
+			if err := fn(entry.Name()); err != nil {
+				return err
+			}
+			continue
+		}
+
+		err := filepath.WalkDir(filepath.Join(dir, entry.Name()), func(path string, info fs.DirEntry, err error) error {
+			if err != nil || info.IsDir() {
+				return err
+			}
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			return fn(rel)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findStaleFiles walks the resource files of a previous export and returns every file
+// the current export did not refresh, relative to dir. A stale file of a per-object layout
+// is prunable when it belongs to a namespace and Kind covered by the export whose listing
+// did not fail. Files of aggregated layouts, and files written by another layout or in
+// another format, are returned with their documents so they can be checked object by object.
+func (d *Dumper) findStaleFiles(dir string, refreshed func(rel string) bool) ([]staleFile, error) {
+	var stale []staleFile
+	err := walkResourceFiles(dir, func(rel string) error {
+		isRefreshed := refreshed(rel)
+		if !d.layout.Aggregated() && filepath.Ext(rel) == d.format.Extension() {
+			if isRefreshed {
+				return nil
+			}
+			if namespace, kind, ok := parseLayoutPath(d.layout, rel); ok {
+				stale = append(stale, staleFile{path: rel, prunable: d.canPruneObject(namespace, kind)})
+				return nil
+			}
+		}
+
+		// A refreshed aggregated file may still lack objects that must be preserved
+		documents, ok := readDocuments(filepath.Join(dir, rel), d.format)
+		switch {
+		case ok:
+			stale = append(stale, staleFile{path: rel, documents: documents})
+		case !isRefreshed:
+			stale = append(stale, staleFile{path: rel})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stale, nil
+}
+
+// canPruneObject reports whether objects of a namespace and Kind left over from a previous
+// export may be removed. Cluster-scoped objects have an empty namespace.
+func (d *Dumper) canPruneObject(namespace, kind string) bool {
+	if namespace != "" && !d.filter.IncludesNamespace(namespace) {
+		return false
+	}
+	return d.canPruneKind(kind)
+}
+
+// canPruneKind reports whether files of a Kind directory may be pruned. Kinds that were
+// exported are pruned unless their listing failed; kinds that were not exported are only
+// pruned when no resource filter narrows the export and discovery was complete (e.g. a
+// CRD that was removed).
+func (d *Dumper) canPruneKind(kind string) bool {
+	d.run.mu.Lock()
+	defer d.run.mu.Unlock()
+
+	if d.run.failed[kind] {
+		return false
+	}
+	if d.run.planned[kind] {
+		return true
+	}
+	return !d.run.discoveryIncomplete && len(d.filter.Resources) == 0 && len(d.filter.ExcludeResources) == 0
+}
@@ -0,0 +1,215 @@
+package dumper
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Resource statuses reported in an ExportResult
+const (
+	// ResourceExported means every object of the resource was listed and written
+	ResourceExported = "exported"
+	// ResourcePartial means some namespaces or objects of the resource failed
+	ResourcePartial = "partial"
+	// ResourceForbidden means RBAC denied listing the resource
+	ResourceForbidden = "forbidden"
+	// ResourceFailed means listing the resource failed
+	ResourceFailed = "failed"
+)
+
+// ResourceResult holds the outcome of exporting a single group/version/resource
+type ResourceResult struct {
+	Group      string `json:"group"`
+	Version    string `json:"version"`
+	Resource   string `json:"resource"`
+	Kind       string `json:"kind"`
+	Namespaced bool   `json:"namespaced"`
+	Objects    int    `json:"objects"`
+	Errors     int    `json:"errors"`
+	Status     string `json:"status"`
+}
+
+// GVR returns the resource as "resource.group/version", or "resource/version" for the core group
+func (r ResourceResult) GVR() string {
+	return schema.GroupVersionResource{Group: r.Group, Version: r.Version, Resource: r.Resource}.GroupResource().String() + "/" + r.Version
+}
+
+// ResourceError describes a failure to list a resource or write one of its objects
+type ResourceError struct {
+	Resource  string `json:"resource"`
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Error     string `json:"error"`
+}
+
+// SkippedResource is a resource that was discovered but not exported
+type SkippedResource struct {
+	Resource string `json:"resource"`
+	Kind     string `json:"kind"`
+	Reason   string `json:"reason"`
+}
+
+// DiscoveryFailure is an API group version that could not be discovered
+type DiscoveryFailure struct {
+	GroupVersion string `json:"groupVersion"`
+	Error        string `json:"error"`
+}
+
+// ExportTotals summarizes an ExportResult
+type ExportTotals struct {
+	Resources    int `json:"resources"`
+	Objects      int `json:"objects"`
+	Skipped      int `json:"skipped"`
+	Forbidden    int `json:"forbidden"`
+	Failed       int `json:"failed"`
+	Errors       int `json:"errors"`
+	FailedGroups int `json:"failedGroups"`
+}
+
+// ExportResult is the outcome of a DumpAllResources call
+type ExportResult struct {
+	StartTime       time.Time          `json:"startTime"`
+	Duration        time.Duration      `json:"-"`
+	DurationSeconds float64            `json:"durationSeconds"`
+	Namespaces      int                `json:"namespaces"`
+	Totals          ExportTotals       `json:"totals"`
+	Resources       []ResourceResult   `json:"resources"`
+	Skipped         []SkippedResource  `json:"skipped,omitempty"`
+	Forbidden       []ResourceError    `json:"forbidden,omitempty"`
+	Failed          []ResourceError    `json:"failed,omitempty"`
+	FailedGroups    []DiscoveryFailure `json:"failedGroups,omitempty"`
+}
+
+// HasErrors reports whether any resource could not be fully exported
+func (r *ExportResult) HasErrors() bool {
+	return r.Totals.Forbidden > 0 || r.Totals.Failed > 0 || r.Totals.Errors > 0 || r.Totals.FailedGroups > 0
+}
+
+// resultCollector gathers resource outcomes from concurrently running tasks
+type resultCollector struct {
+	mu     sync.Mutex
+	result *ExportResult
+}
+
+// newResultCollector starts collecting the result of an export
+func newResultCollector() *resultCollector {
+	return &resultCollector{result: &ExportResult{StartTime: time.Now()}}
+}
+
+// skip records a discovered resource that is not exported
+func (c *resultCollector) skip(resource, kind, reason string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.result.Skipped = append(c.result.Skipped, SkippedResource{Resource: resource, Kind: kind, Reason: reason})
+}
+
+// failGroup records an API group version that could not be discovered
+func (c *resultCollector) failGroup(groupVersion string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.result.FailedGroups = append(c.result.FailedGroups, DiscoveryFailure{GroupVersion: groupVersion, Error: err.Error()})
+}
+
+// add records the outcome of an exported resource
+func (c *resultCollector) add(res *resourceRecorder) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.result.Resources = append(c.result.Resources, res.result)
+	c.result.Forbidden = append(c.result.Forbidden, res.forbidden...)
+	c.result.Failed = append(c.result.Failed, res.failed...)
+}
+
+// finish computes the totals and returns the result
+func (c *resultCollector) finish() *ExportResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	result := c.result
+	result.Duration = time.Since(result.StartTime)
+	result.DurationSeconds = result.Duration.Seconds()
+
+	sort.Slice(result.Resources, func(i, j int) bool {
+		return result.Resources[i].GVR() < result.Resources[j].GVR()
+	})
+
+	totals := ExportTotals{Resources: len(result.Resources), Skipped: len(result.Skipped), FailedGroups: len(result.FailedGroups)}
+	for _, res := range result.Resources {
+		totals.Objects += res.Objects
+		totals.Errors += res.Errors
+		switch res.Status {
+		case ResourceForbidden:
+			totals.Forbidden++
+		case ResourceFailed:
+			totals.Failed++
+		}
+	}
+	result.Totals = totals
+	return result
+}
+
+// resourceRecorder tracks the outcome of a single task while it runs
+type resourceRecorder struct {
+	result    ResourceResult
+	listed    bool
+	forbidden []ResourceError
+	failed    []ResourceError
+}
+
+// newResourceRecorder starts recording the export of a task's resource
+func newResourceRecorder(task dumpTask) *resourceRecorder {
+	return &resourceRecorder{result: ResourceResult{
+		Group:      task.gvr.Group,
+		Version:    task.gvr.Version,
+		Resource:   task.gvr.Resource,
+		Kind:       task.resource.Kind,
+		Namespaced: task.resource.Namespaced,
+	}}
+}
+
+// exported records an object written to disk
+func (r *resourceRecorder) exported() {
+	r.result.Objects++
+}
+
+// listSucceeded records a successful list (of the whole cluster or one namespace)
+func (r *resourceRecorder) listSucceeded() {
+	r.listed = true
+}
+
+// listFailed records a failed list; namespace is empty for cluster-wide lists
+func (r *resourceRecorder) listFailed(namespace string, err error) {
+	entry := ResourceError{Resource: r.result.GVR(), Kind: r.result.Kind, Namespace: namespace, Error: err.Error()}
+	if apierrors.IsForbidden(err) {
+		r.forbidden = append(r.forbidden, entry)
+	} else {
+		r.failed = append(r.failed, entry)
+	}
+}
+
+// objectFailed records an object that could not be written
+func (r *resourceRecorder) objectFailed(namespace, name string, err error) {
+	r.result.Errors++
+	r.failed = append(r.failed, ResourceError{Resource: r.result.GVR(), Kind: r.result.Kind, Namespace: namespace, Name: name, Error: err.Error()})
+}
+
+// status derives the resource status from the recorded outcome
+func (r *resourceRecorder) status() string {
+	listFailures := len(r.forbidden) + len(r.failed) - r.result.Errors
+	switch {
+	case listFailures == 0 && r.result.Errors == 0:
+		return ResourceExported
+	case r.listed:
+		return ResourcePartial
+	case len(r.failed) > r.result.Errors:
+		return ResourceFailed
+	case len(r.forbidden) > 0:
+		return ResourceForbidden
+	default:
+		return ResourcePartial
+	}
+}
@@ -0,0 +1,301 @@
+package dumper
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// SecretMode controls how the data of Secret objects is written to the export
+type SecretMode string
+
+const (
+	// SecretsInclude writes Secret values verbatim
+	SecretsInclude SecretMode = "include"
+	// SecretsRedact replaces Secret values with a stable keyed hash
+	SecretsRedact SecretMode = "redact"
+	// SecretsOmit skips Secret objects entirely
+	SecretsOmit SecretMode = "omit"
+	// SecretsEncrypt encrypts Secret values for one or more age recipients
+	SecretsEncrypt SecretMode = "encrypt"
+)
+
+const (
+	// RedactedPrefix marks a Secret value that was replaced by its hash
+	RedactedPrefix = "kalco-redacted:sha256:"
+
+	// SecretChecksumsAnnotation records the per-key hashes of encrypted Secret values,
+	// so unchanged values keep their ciphertext and changes stay visible in diffs
+	SecretChecksumsAnnotation = "kalco.io/secret-checksums"
+
+	// SecretHashKeyEnv names the environment variable that can provide the key Secret
+	// value hashes are computed with, e.g. to share it between machines
+	SecretHashKeyEnv = "KALCO_SECRET_HASH_KEY"
+)
+
+// secretFields lists the Secret fields holding sensitive values
+var secretFields = []string{"data", "stringData"}
+
+// ParseSecretMode validates a secret mode name
+func ParseSecretMode(value string) (SecretMode, error) {
+	switch mode := SecretMode(strings.ToLower(strings.TrimSpace(value))); mode {
+	case SecretsInclude, SecretsRedact, SecretsOmit, SecretsEncrypt:
+		return mode, nil
+	case "":
+		return SecretsInclude, nil
+	default:
+		return "", fmt.Errorf("invalid secret mode '%s' (expected include, redact, omit or encrypt)", value)
+	}
+}
+
+// SetSecretMode sets how Secret data is exported. Encryption requires at least one
+// age recipient (an "age1..." public key or an SSH public key).
+func (d *Dumper) SetSecretMode(mode SecretMode, recipients []string) error {
+	d.secretMode = mode
+	d.secretRecipients = nil
+
+	if mode != SecretsEncrypt {
+		return nil
+	}
+	if len(recipients) == 0 {
+		return fmt.Errorf("secret encryption requires at least one age recipient")
+	}
+
+	parsed, err := age.ParseRecipients(strings.NewReader(strings.Join(recipients, "\n")))
+	if err != nil {
+		return fmt.Errorf("failed to parse age recipients: %w", err)
+	}
+	d.secretRecipients = parsed
+	return nil
+}
+
+// SetSecretHashKey sets the key Secret value hashes are computed with. The key must stay
+// outside the export: anyone holding it can test guesses of redacted or encrypted values.
+// Redaction requires a key; without one, encrypted Secrets carry no checksums.
+func (d *Dumper) SetSecretHashKey(key []byte) {
+	d.secretHashKey = key
+}
+
+// LoadSecretHashKey reads the Secret hash key stored in path, generating a random key
+// there on first use
+func LoadSecretHashKey(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil || len(key) == 0 {
+			return nil, fmt.Errorf("invalid secret hash key in %s", path)
+		}
+		return key, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read secret hash key: %w", err)
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate secret hash key: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create secret hash key directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(hex.EncodeToString(key)+"\n"), 0600); err != nil {
+		return nil, fmt.Errorf("failed to write secret hash key: %w", err)
+	}
+	return key, nil
+}
+
+// isSecretResource reports whether the GVR refers to core Secrets
+func isSecretResource(gvr schema.GroupVersionResource) bool {
+	return gvr.Group == "" && gvr.Resource == "secrets"
+}
+
+// isSecretObject reports whether the object is a core Secret
+func isSecretObject(item *unstructured.Unstructured) bool {
+	return item.GetAPIVersion() == "v1" && item.GetKind() == "Secret"
+}
+
+// protectSecret rewrites the values of a Secret according to the configured mode.
+// previousFile is the file the Secret was written to by an earlier export, used to
+// keep the ciphertext of unchanged values stable.
+func (d *Dumper) protectSecret(item *unstructured.Unstructured, previousFile string) error {
+	switch d.secretMode {
+	case SecretsRedact:
+		if len(d.secretHashKey) == 0 {
+			return fmt.Errorf("redacting Secrets requires a secret hash key")
+		}
+		return forEachSecretValue(item, func(key string, value []byte) (string, error) {
+			return RedactedPrefix + secretValueHash(d.secretHashKey, item, key, value), nil
+		})
+
+	case SecretsEncrypt:
+		if len(d.secretHashKey) == 0 {
+			// Without a key, checksums would be plain hashes next to the ciphertext
+			return forEachSecretValue(item, func(key string, value []byte) (string, error) {
+				return d.encryptSecretValue(value)
+			})
+		}
+
+		previous := readPreviousSecret(previousFile, item.GetNamespace(), item.GetName())
+		checksums := make(map[string]string)
+		err := forEachSecretValue(item, func(key string, value []byte) (string, error) {
+			checksum := secretValueHash(d.secretHashKey, item, key, value)
+			checksums[key] = checksum
+			if ciphertext, ok := previous.unchanged(key, checksum); ok {
+				return ciphertext, nil
+			}
+			return d.encryptSecretValue(value)
+		})
+		if err != nil {
+			return err
+		}
+
+		encoded, err := json.Marshal(checksums)
+		if err != nil {
+			return fmt.Errorf("failed to encode secret checksums: %w", err)
+		}
+		annotations := item.GetAnnotations()
+		if annotations == nil {
+			annotations = make(map[string]string)
+		}
+		annotations[SecretChecksumsAnnotation] = string(encoded)
+		item.SetAnnotations(annotations)
+	}
+	return nil
+}
+
+// forEachSecretValue replaces every data and stringData value with the result of fn.
+// The function receives the decoded value.
+func forEachSecretValue(item *unstructured.Unstructured, fn func(key string, value []byte) (string, error)) error {
+	for _, field := range secretFields {
+		values, exists, err := unstructured.NestedMap(item.Object, field)
+		if !exists || err != nil {
+			continue
+		}
+
+		keys := make([]string, 0, len(values))
+		for key := range values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			raw, _ := values[key].(string)
+			value := []byte(raw)
+			if field == "data" {
+				if decoded, err := base64.StdEncoding.DecodeString(raw); err == nil {
+					value = decoded
+				}
+			}
+
+			replaced, err := fn(key, value)
+			if err != nil {
+				return fmt.Errorf("failed to protect secret key %s: %w", key, err)
+			}
+			values[key] = replaced
+		}
+		item.Object[field] = values
+	}
+	return nil
+}
+
+// secretValueHash returns a stable HMAC of a Secret value under the secret hash key. The
+// Secret's namespace, name and key are mixed in so equal values in different Secrets do
+// not correlate.
+func secretValueHash(hashKey []byte, item *unstructured.Unstructured, key string, value []byte) string {
+	mac := hmac.New(sha256.New, hashKey)
+	mac.Write([]byte(item.GetNamespace() + "/" + item.GetName() + "/" + key + "\x00"))
+	mac.Write(value)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// encryptSecretValue encrypts a value for the configured recipients as armored age text
+func (d *Dumper) encryptSecretValue(value []byte) (string, error) {
+	var out bytes.Buffer
+	armorWriter := armor.NewWriter(&out)
+	encryptWriter, err := age.Encrypt(armorWriter, d.secretRecipients...)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(encryptWriter, bytes.NewReader(value)); err != nil {
+		return "", err
+	}
+	if err := encryptWriter.Close(); err != nil {
+		return "", err
+	}
+	if err := armorWriter.Close(); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// previousSecret holds the encrypted values and checksums of a previously exported Secret
+type previousSecret struct {
+	checksums map[string]string
+	values    map[string]string
+}
+
+// readPreviousSecret loads a previously exported Secret, if any, from a file that may
+// hold several objects
+func readPreviousSecret(file, namespace, name string) previousSecret {
+	var previous previousSecret
+	if file == "" {
+		return previous
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return previous
+	}
+
+	objects, err := ReadDocuments(data)
+	if err != nil {
+		return previous
+	}
+	var item unstructured.Unstructured
+	for _, object := range objects {
+		kind, objectNamespace, objectName, _ := ObjectIdentity(object)
+		if kind == "Secret" && objectNamespace == namespace && objectName == name {
+			item.Object = object
+			break
+		}
+	}
+	if item.Object == nil {
+		return previous
+	}
+
+	if encoded, ok := item.GetAnnotations()[SecretChecksumsAnnotation]; ok {
+		_ = json.Unmarshal([]byte(encoded), &previous.checksums)
+	}
+	previous.values = make(map[string]string)
+	for _, field := range secretFields {
+		values, _, _ := unstructured.NestedStringMap(item.Object, field)
+		for key, value := range values {
+			previous.values[key] = value
+		}
+	}
+	return previous
+}
+
+// unchanged returns the previous ciphertext of a key if its checksum did not change
+func (p previousSecret) unchanged(key, checksum string) (string, bool) {
+	if p.checksums[key] != checksum {
+		return "", false
+	}
+	value, ok := p.values[key]
+	return value, ok && value != ""
+}
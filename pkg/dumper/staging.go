@@ -0,0 +1,248 @@
+package dumper
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// CreateStagingDir creates an empty staging directory next to outputDir. Keeping it on the
+// same filesystem lets PromoteStaging move directories with atomic renames, and keeping it
+// outside outputDir keeps half-written exports out of the Git repository.
+func CreateStagingDir(outputDir string) (string, error) {
+	absOutput, err := filepath.Abs(outputDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve output directory: %w", err)
+	}
+
+	stagingDir, err := os.MkdirTemp(filepath.Dir(absOutput), "."+filepath.Base(absOutput)+"-staging-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	return stagingDir, nil
+}
+
+// SetReferenceDir sets the directory holding the previous export when exporting into a
+// staging directory. It is used to keep encrypted Secret values stable across exports.
+func (d *Dumper) SetReferenceDir(dir string) {
+	d.referenceDir = dir
+}
+
+// previousFile maps a file of the current export to the same file of the previous export
+func (d *Dumper) previousFile(filename string) string {
+	if d.referenceDir == "" || d.run == nil {
+		return filename
+	}
+	rel, err := filepath.Rel(d.run.outputDir, filename)
+	if err != nil {
+		return filename
+	}
+	return filepath.Join(d.referenceDir, rel)
+}
+
+// FailedKinds returns the kinds whose listing failed during the last export, group-qualified
+// when several API groups serve the same Kind
+func (d *Dumper) FailedKinds() []string {
+	if d.run == nil {
+		return nil
+	}
+	d.run.mu.Lock()
+	defer d.run.mu.Unlock()
+
+	kinds := make([]string, 0, len(d.run.failed))
+	for kind := range d.run.failed {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// PromoteStaging replaces the resource tree of outputDir with the tree exported into
+// stagingDir by the last DumpAllResources call. Objects of the previous export that were
+// not refreshed are carried over when they are outside the export's scope, belong to a kind
+// whose listing failed, or when prune is false; all others are dropped. The resource
+// directories are then swapped in with renames. It returns the pruned files relative to
+// outputDir, and the pruned objects of files checked object by object as "<file>: <object>".
+// The manifest of the export, including carried objects, replaces the previous one.
+func (d *Dumper) PromoteStaging(stagingDir, outputDir string, prune bool) ([]string, error) {
+	if d.run == nil || filepath.Clean(d.run.outputDir) != filepath.Clean(stagingDir) {
+		return nil, fmt.Errorf("no export has been run into %s", stagingDir)
+	}
+
+	stale, err := d.findStaleFiles(outputDir, func(rel string) bool {
+		_, err := os.Stat(filepath.Join(stagingDir, rel))
+		return err == nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan previous export: %w", err)
+	}
+
+	// Carry over the files and objects that must survive into the staging tree, keeping
+	// their entries of the previous manifest
+	var pruned []string
+	var carriedEntries []ManifestEntry
+	previous := newManifestIndex(outputDir)
+	carried := make(map[string][]document)
+	seen := make(map[objectKey]bool)
+	for _, file := range stale {
+		if file.documents == nil {
+			if prune && file.prunable {
+				pruned = append(pruned, file.path)
+				continue
+			}
+			if err := copyFile(filepath.Join(outputDir, file.path), filepath.Join(stagingDir, file.path)); err != nil {
+				return nil, fmt.Errorf("failed to preserve %s: %w", file.path, err)
+			}
+			carriedEntries = append(carriedEntries, previous.fileEntries(outputDir, file.path)...)
+			continue
+		}
+
+		for _, doc := range file.documents {
+			if d.run.isExported(doc.key) || seen[doc.key] {
+				continue
+			}
+			seen[doc.key] = true
+			if prune && d.canPruneObject(doc.key.Namespace, d.kindDir(doc.key.Group, doc.key.Kind)) {
+				pruned = append(pruned, file.path+": "+doc.key.String())
+				continue
+			}
+			target := d.objectPath(doc.key)
+			carried[target] = append(carried[target], doc)
+			carriedEntries = append(carriedEntries, previous.documentEntry(doc, target))
+		}
+	}
+	if err := d.writeCarried(stagingDir, carried); err != nil {
+		return nil, err
+	}
+	carriedCRDs, err := d.carryCRDs(stagingDir, outputDir)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.writeManifest(stagingDir, carriedEntries, carriedCRDs); err != nil {
+		return nil, err
+	}
+
+	if err := swapResourceDirs(stagingDir, outputDir); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(filepath.Join(stagingDir, ManifestFileName), filepath.Join(outputDir, ManifestFileName)); err != nil {
+		return nil, fmt.Errorf("failed to promote %s: %w", ManifestFileName, err)
+	}
+
+	sort.Strings(pruned)
+	return pruned, nil
+}
+
+// writeCarried adds objects preserved from the previous export to the files the current
+// layout assigns them, merging them into aggregated files of the staging tree
+func (d *Dumper) writeCarried(stagingDir string, carried map[string][]document) error {
+	for target, documents := range carried {
+		path := filepath.Join(stagingDir, target)
+		data := documents[0].data
+		if !d.layout.Aggregated() {
+			if _, err := os.Stat(path); err == nil {
+				continue
+			}
+		} else {
+			if staged, ok := readDocuments(path, d.format); ok {
+				documents = append(staged, documents...)
+			}
+			sortDocuments(documents)
+			joined, err := d.format.join(documents)
+			if err != nil {
+				return err
+			}
+			data = joined
+		}
+
+		if err := writeFile(path, data); err != nil {
+			return fmt.Errorf("failed to preserve %s: %w", target, err)
+		}
+	}
+	return nil
+}
+
+// swapResourceDirs moves the resource entries of outputDir aside, moves the staged
+// entries in and removes the old ones. On failure the previous tree is restored.
+func swapResourceDirs(stagingDir, outputDir string) error {
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	backupDir := stagingDir + "-previous"
+	if err := os.MkdirAll(backupDir, 0755); err != nil {
+		return fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	defer os.RemoveAll(backupDir)
+
+	// Move the current resource directories aside
+	current, err := os.ReadDir(outputDir)
+	if err != nil {
+		return fmt.Errorf("failed to read output directory: %w", err)
+	}
+	var moved []string
+	for _, entry := range current {
+		if !isResourceEntry(entry) {
+			continue
+		}
+		if err := os.Rename(filepath.Join(outputDir, entry.Name()), filepath.Join(backupDir, entry.Name())); err != nil {
+			restoreResourceDirs(backupDir, outputDir, moved, nil)
+			return fmt.Errorf("failed to move %s aside: %w", entry.Name(), err)
+		}
+		moved = append(moved, entry.Name())
+	}
+
+	// Move the staged resource directories in
+	staged, err := os.ReadDir(stagingDir)
+	if err != nil {
+		restoreResourceDirs(backupDir, outputDir, moved, nil)
+		return fmt.Errorf("failed to read staging directory: %w", err)
+	}
+	var promoted []string
+	for _, entry := range staged {
+		if !isResourceEntry(entry) {
+			continue
+		}
+		if err := os.Rename(filepath.Join(stagingDir, entry.Name()), filepath.Join(outputDir, entry.Name())); err != nil {
+			restoreResourceDirs(backupDir, outputDir, moved, promoted)
+			return fmt.Errorf("failed to promote %s: %w", entry.Name(), err)
+		}
+		promoted = append(promoted, entry.Name())
+	}
+	return nil
+}
+
+// restoreResourceDirs undoes a partially applied swap
+func restoreResourceDirs(backupDir, outputDir string, moved, promoted []string) {
+	for _, name := range promoted {
+		os.RemoveAll(filepath.Join(outputDir, name))
+	}
+	for _, name := range moved {
+		os.Rename(filepath.Join(backupDir, name), filepath.Join(outputDir, name))
+	}
+}
+
+// copyFile copies a file, creating the destination directory if needed
+func copyFile(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,75 @@
+package dumper
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// StatusMode controls whether object status is captured in the export
+type StatusMode string
+
+const (
+	// StatusNone drops status as configured by the cleanup profile
+	StatusNone StatusMode = "none"
+	// StatusInline keeps status inside the exported object
+	StatusInline StatusMode = "inline"
+	// StatusSeparate writes status to a sibling <name>.status.yaml file
+	StatusSeparate StatusMode = "separate"
+)
+
+// Suffixes of files holding status captured separately, per output format
+const (
+	StatusFileSuffix     = ".status.yaml"
+	StatusFileSuffixJSON = ".status.json"
+)
+
+// ParseStatusMode validates a status mode name
+func ParseStatusMode(value string) (StatusMode, error) {
+	switch mode := StatusMode(strings.ToLower(strings.TrimSpace(value))); mode {
+	case StatusNone, StatusInline, StatusSeparate:
+		return mode, nil
+	case "":
+		return StatusNone, nil
+	default:
+		return "", fmt.Errorf("invalid status mode '%s' (expected none, inline or separate)", value)
+	}
+}
+
+// SetStatusMode sets how object status is captured
+func (d *Dumper) SetStatusMode(mode StatusMode) {
+	d.statusMode = mode
+}
+
+// statusFilename returns the sibling status file for an exported object file
+func statusFilename(filename string) string {
+	ext := filepath.Ext(filename)
+	return strings.TrimSuffix(filename, ext) + ".status" + ext
+}
+
+// IsStatusFile reports whether a file holds status captured separately from its object
+func IsStatusFile(filename string) bool {
+	return strings.HasSuffix(filename, StatusFileSuffix) || strings.HasSuffix(filename, StatusFileSuffixJSON)
+}
+
+// marshalStatus renders the status of an object for its status file. The document keeps
+// enough identity (apiVersion, kind, name, namespace) to be read on its own.
+func marshalStatus(item *unstructured.Unstructured, status interface{}, format Format) ([]byte, error) {
+	metadata := map[string]interface{}{"name": item.GetName()}
+	if item.GetNamespace() != "" {
+		metadata["namespace"] = item.GetNamespace()
+	}
+
+	data, err := format.Encode(map[string]interface{}{
+		"apiVersion": item.GetAPIVersion(),
+		"kind":       item.GetKind(),
+		"metadata":   metadata,
+		"status":     status,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal status: %w", err)
+	}
+	return data, nil
+}
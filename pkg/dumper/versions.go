@@ -0,0 +1,151 @@
+package dumper
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+)
+
+// ParseVersionPins parses "group=version" pins such as "autoscaling=v2". The core group
+// is written as "core".
+func ParseVersionPins(values []string) (map[string]string, error) {
+	pins := make(map[string]string, len(values))
+	for _, value := range values {
+		group, version, ok := strings.Cut(strings.TrimSpace(value), "=")
+		if !ok || group == "" || version == "" {
+			return nil, fmt.Errorf("invalid API version pin '%s' (expected group=version)", value)
+		}
+		if group == "core" {
+			group = ""
+		}
+		pins[group] = version
+	}
+	return pins, nil
+}
+
+// ParseGroupVersions parses API versions such as "autoscaling/v2", or "v1" for the core group
+func ParseGroupVersions(values []string) ([]schema.GroupVersion, error) {
+	versions := make([]schema.GroupVersion, 0, len(values))
+	for _, value := range values {
+		gv, err := schema.ParseGroupVersion(strings.TrimSpace(value))
+		if err != nil || gv.Version == "" {
+			return nil, fmt.Errorf("invalid API version '%s' (expected group/version)", value)
+		}
+		versions = append(versions, gv)
+	}
+	return versions, nil
+}
+
+// SetVersionPins sets the API version exported per group instead of the server's
+// preferred version, so diffs do not churn when the preference changes after an upgrade
+func (d *Dumper) SetVersionPins(pins map[string]string) {
+	d.versionPins = pins
+}
+
+// SetAdditionalVersions sets API versions whose objects are exported in addition to the
+// exported version, to <name>@<version> files next to the object
+func (d *Dumper) SetAdditionalVersions(versions []schema.GroupVersion) {
+	d.additionalVersions = versions
+}
+
+// resolveVersions applies the version pins to the preferred resources and returns the
+// resource lists of the additional versions. Pins and additional versions the server
+// does not serve are reported as warnings and ignored.
+func (d *Dumper) resolveVersions(preferred []*metav1.APIResourceList) ([]*metav1.APIResourceList, []*metav1.APIResourceList, error) {
+	if len(d.versionPins) == 0 && len(d.additionalVersions) == 0 {
+		return preferred, nil, nil
+	}
+
+	// Failed groups were already recorded while discovering the preferred versions
+	_, all, err := d.discoveryClient.ServerGroupsAndResources()
+	if err != nil {
+		var groupErr *discovery.ErrGroupDiscoveryFailed
+		if !errors.As(err, &groupErr) {
+			return nil, nil, fmt.Errorf("failed to get server resources: %w", err)
+		}
+	}
+	served := make(map[string]*metav1.APIResourceList, len(all))
+	for _, resourceList := range all {
+		served[resourceList.GroupVersion] = resourceList
+	}
+
+	groups := make([]string, 0, len(d.versionPins))
+	for group := range d.versionPins {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+
+	resolved := preferred
+	for _, group := range groups {
+		gv := schema.GroupVersion{Group: group, Version: d.versionPins[group]}
+		pinned, ok := served[gv.String()]
+		if !ok {
+			d.warn(fmt.Sprintf("%s - pinned API version is not served, exporting the preferred version", gv))
+			continue
+		}
+		resolved = pinVersion(resolved, group, pinned)
+	}
+
+	exported := make(map[string]bool, len(resolved))
+	for _, resourceList := range resolved {
+		exported[resourceList.GroupVersion] = true
+	}
+	var additional []*metav1.APIResourceList
+	for _, gv := range d.additionalVersions {
+		resourceList, ok := served[gv.String()]
+		switch {
+		case !ok:
+			d.warn(fmt.Sprintf("%s - additional API version is not served, skipping it", gv))
+		case !exported[gv.String()]:
+			additional = append(additional, resourceList)
+		}
+	}
+	return resolved, additional, nil
+}
+
+// pinVersion replaces the resources of a group by those of the pinned version. Kinds the
+// pinned version does not serve stay in their preferred version.
+func pinVersion(resourceLists []*metav1.APIResourceList, group string, pinned *metav1.APIResourceList) []*metav1.APIResourceList {
+	pinnedKinds := make(map[string]bool, len(pinned.APIResources))
+	for _, resource := range pinned.APIResources {
+		pinnedKinds[resource.Kind] = true
+	}
+
+	resolved := make([]*metav1.APIResourceList, 0, len(resourceLists)+1)
+	for _, resourceList := range resourceLists {
+		gv, err := schema.ParseGroupVersion(resourceList.GroupVersion)
+		if err != nil || gv.Group != group {
+			resolved = append(resolved, resourceList)
+			continue
+		}
+		remaining := &metav1.APIResourceList{GroupVersion: resourceList.GroupVersion}
+		for _, resource := range resourceList.APIResources {
+			if !pinnedKinds[resource.Kind] {
+				remaining.APIResources = append(remaining.APIResources, resource)
+			}
+		}
+		if len(remaining.APIResources) > 0 {
+			resolved = append(resolved, remaining)
+		}
+	}
+	return append(resolved, pinned)
+}
+
+// warn reports a problem that does not stop the export
+func (d *Dumper) warn(message string) {
+	if d.outputCallback != nil {
+		d.outputCallback("WARNING", message)
+	}
+}
+
+// IsAdditionalVersionFile reports whether a file holds the copy of an object exported in
+// an additional API version. Escaped names never contain '@', see objectPath.
+func IsAdditionalVersionFile(path string) bool {
+	return strings.Contains(filepath.Base(path), "@")
+}
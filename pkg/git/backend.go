@@ -0,0 +1,182 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Backend names
+const (
+	BackendGoGit = "go-git"
+	BackendExec  = "exec"
+)
+
+// BackendNames lists the available backends, the default first
+var BackendNames = []string{BackendGoGit, BackendExec}
+
+// DefaultRemote is the remote pushed to when none is configured
+const DefaultRemote = "origin"
+
+var (
+	// ErrNotFound is returned when a file does not exist at a commit
+	ErrNotFound = errors.New("file not found")
+	// ErrPushRejected is returned when the remote refuses a push that is not a fast-forward
+	ErrPushRejected = errors.New("push rejected: the remote branch has commits that are not in the local branch")
+	// ErrRebaseConflict is returned when local commits cannot be replayed onto the remote branch
+	ErrRebaseConflict = errors.New("rebase conflict")
+)
+
+// Backend runs Git operations on the repository in one directory. The go-git backend
+// needs no git binary; the exec backend runs the git binary and so honours the user's
+// Git configuration (hooks, credential helpers, signing).
+type Backend interface {
+	// Name returns the name the backend is selected by
+	Name() string
+	// Init creates an empty repository
+	Init() error
+	// InitBare creates an empty repository without a work tree, to be shared by work trees
+	InitBare() error
+	// AddWorktree checks out branch into a new work tree at path linked to the repository,
+	// creating the branch from an empty commit if it does not exist
+	AddWorktree(path, branch string) error
+	// AddAll stages every change in the work tree except paths matching the exclude patterns
+	AddAll(exclude []string) error
+	// Status returns the files whose staged or work tree state differs from HEAD
+	Status() ([]FileStatus, error)
+	// Commit records the staged changes and returns the new commit hash
+	Commit(message string) (string, error)
+	// ResolveRevision returns the commit hash of a revision such as HEAD or HEAD~1
+	ResolveRevision(revision string) (string, error)
+	// DiffTree returns the files changed between two commits, without rename detection
+	DiffTree(from, to string) ([]Change, error)
+	// ReadBlob returns the content of a file at a commit, or ErrNotFound
+	ReadBlob(commit, path string) ([]byte, error)
+	// Diff returns the unified diff of a file between two commits
+	Diff(from, to, path string) (string, error)
+	// Log returns up to limit commits reachable from a revision, newest first
+	Log(revision string, limit int) ([]Commit, error)
+	// CurrentBranch returns the name of the checked out branch
+	CurrentBranch() (string, error)
+	// RemoteURL returns the URL of a remote
+	RemoteURL(remote string) (string, error)
+	// SetRemoteURL adds a remote, or changes its URL if it exists
+	SetRemoteURL(remote, url string) error
+	// Push pushes HEAD to a branch of a remote, and makes that branch the upstream of
+	// the current branch if it has none. A non-fast-forward push returns ErrPushRejected.
+	Push(opts PushOptions) error
+	// Fetch updates the remote-tracking branch of one branch of a remote
+	Fetch(remote, branch string, auth Auth) error
+	// Rebase replays the commits of the current branch that are not in upstream on top
+	// of it. Files in keepLocal that changed on both sides keep the local version; other
+	// conflicts return ErrRebaseConflict and leave the branch unchanged.
+	Rebase(upstream string, keepLocal []string) error
+}
+
+// Auth holds the credentials used to talk to a remote. The SSH key is used for SSH
+// remotes and the token for HTTP(S) remotes; without either, Git's defaults apply.
+type Auth struct {
+	SSHKeyPath string
+	Username   string
+	Token      string
+}
+
+// DefaultTokenUsername is the HTTP username sent with a token when none is configured;
+// most Git hosts ignore it for personal access tokens
+const DefaultTokenUsername = "kalco"
+
+// username returns the HTTP username sent with the token
+func (a Auth) username() string {
+	if a.Username != "" {
+		return a.Username
+	}
+	return DefaultTokenUsername
+}
+
+// PushOptions selects what is pushed where
+type PushOptions struct {
+	Remote string
+	Branch string
+	Auth   Auth
+}
+
+// NewBackend returns the named backend for the repository in path (go-git when empty)
+func NewBackend(name, path string) (Backend, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case BackendGoGit, "":
+		return NewGoGitBackend(path), nil
+	case BackendExec:
+		return NewExecBackend(path), nil
+	default:
+		return nil, fmt.Errorf("invalid Git backend '%s' (expected %s)", name, strings.Join(BackendNames, ", "))
+	}
+}
+
+// StatusCode is the state of a file in the index or the work tree, as in git status --short
+type StatusCode byte
+
+// Status codes
+const (
+	Unmodified StatusCode = ' '
+	Untracked  StatusCode = '?'
+	Modified   StatusCode = 'M'
+	Added      StatusCode = 'A'
+	Deleted    StatusCode = 'D'
+)
+
+// FileStatus is the staged and work tree state of a file
+type FileStatus struct {
+	Path     string
+	Staging  StatusCode
+	Worktree StatusCode
+}
+
+// IsStaged reports whether the file has a change staged for the next commit
+func (s FileStatus) IsStaged() bool {
+	return s.Staging != Unmodified && s.Staging != Untracked
+}
+
+// ChangeType is how a file changed between two commits
+type ChangeType string
+
+// Change types
+const (
+	ChangeAdded    ChangeType = "A"
+	ChangeModified ChangeType = "M"
+	ChangeDeleted  ChangeType = "D"
+)
+
+// Change is a file changed between two commits
+type Change struct {
+	Path string
+	Type ChangeType
+}
+
+// Commit describes a commit
+type Commit struct {
+	Hash    string
+	Author  string
+	Email   string
+	Time    time.Time
+	Message string
+}
+
+// hasStagedChanges reports whether any file has a change staged for the next commit,
+// not counting modifications of the files in ignore
+func hasStagedChanges(backend Backend, ignore []string) (bool, error) {
+	status, err := backend.Status()
+	if err != nil {
+		return false, err
+	}
+	ignored := make(map[string]bool, len(ignore))
+	for _, path := range ignore {
+		ignored[path] = true
+	}
+	for _, file := range status {
+		if file.IsStaged() && !(ignored[file.Path] && file.Staging == Modified) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,188 @@
+package git
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeFile writes a file below dir, creating its parent directories
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write %s: %v", name, err)
+	}
+}
+
+func TestBackends(t *testing.T) {
+	// Identity for the exec backend; go-git falls back to its default identity
+	t.Setenv("GIT_AUTHOR_NAME", "Test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "Test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+
+	for _, name := range BackendNames {
+		t.Run(name, func(t *testing.T) {
+			if name == BackendExec {
+				if _, err := exec.LookPath("git"); err != nil {
+					t.Skip("git not available")
+				}
+			}
+
+			dir := t.TempDir()
+			backend, err := NewBackend(name, dir)
+			if err != nil {
+				t.Fatalf("NewBackend failed: %v", err)
+			}
+			if backend.Name() != name {
+				t.Errorf("expected backend %s, got %s", name, backend.Name())
+			}
+			if err := backend.Init(); err != nil {
+				t.Fatalf("Init failed: %v", err)
+			}
+
+			writeFile(t, dir, "default/Pod/web.yaml", "kind: Pod\nimage: nginx:1.24\n")
+			writeFile(t, dir, "default/Pod/web.status.yaml", "phase: Running\n")
+			writeFile(t, dir, "default/Pod/db.yaml", "kind: Pod\n")
+			if err := backend.AddAll([]string{"*.status.yaml"}); err != nil {
+				t.Fatalf("AddAll failed: %v", err)
+			}
+
+			status, err := backend.Status()
+			if err != nil {
+				t.Fatalf("Status failed: %v", err)
+			}
+			staged := map[string]bool{}
+			for _, file := range status {
+				staged[file.Path] = file.IsStaged()
+			}
+			if !staged["default/Pod/web.yaml"] || !staged["default/Pod/db.yaml"] {
+				t.Errorf("expected spec files to be staged, got %+v", status)
+			}
+			if staged["default/Pod/web.status.yaml"] {
+				t.Errorf("expected the excluded status file not to be staged")
+			}
+
+			first, err := backend.Commit("first")
+			if err != nil {
+				t.Fatalf("Commit failed: %v", err)
+			}
+
+			writeFile(t, dir, "default/Pod/web.yaml", "kind: Pod\nimage: nginx:1.25\n")
+			writeFile(t, dir, "default/Service/web.yaml", "kind: Service\n")
+			if err := os.Remove(filepath.Join(dir, "default", "Pod", "db.yaml")); err != nil {
+				t.Fatalf("failed to remove file: %v", err)
+			}
+			if err := backend.AddAll(nil); err != nil {
+				t.Fatalf("AddAll failed: %v", err)
+			}
+			second, err := backend.Commit("second")
+			if err != nil {
+				t.Fatalf("Commit failed: %v", err)
+			}
+
+			head, err := backend.ResolveRevision("HEAD")
+			if err != nil || head != second {
+				t.Errorf("expected HEAD %s, got %s (%v)", second, head, err)
+			}
+			parent, err := backend.ResolveRevision("HEAD~1")
+			if err != nil || parent != first {
+				t.Errorf("expected HEAD~1 %s, got %s (%v)", first, parent, err)
+			}
+
+			changes, err := backend.DiffTree(first, second)
+			if err != nil {
+				t.Fatalf("DiffTree failed: %v", err)
+			}
+			expected := []Change{
+				{Path: "default/Pod/db.yaml", Type: ChangeDeleted},
+				{Path: "default/Pod/web.status.yaml", Type: ChangeAdded},
+				{Path: "default/Pod/web.yaml", Type: ChangeModified},
+				{Path: "default/Service/web.yaml", Type: ChangeAdded},
+			}
+			if len(changes) != len(expected) {
+				t.Fatalf("expected changes %v, got %v", expected, changes)
+			}
+			for i := range expected {
+				if changes[i] != expected[i] {
+					t.Errorf("expected change %v, got %v", expected[i], changes[i])
+				}
+			}
+
+			content, err := backend.ReadBlob(first, "default/Pod/web.yaml")
+			if err != nil || string(content) != "kind: Pod\nimage: nginx:1.24\n" {
+				t.Errorf("unexpected blob %q (%v)", content, err)
+			}
+			if _, err := backend.ReadBlob(second, "default/Pod/db.yaml"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("expected ErrNotFound for a deleted file, got %v", err)
+			}
+
+			diff, err := backend.Diff(first, second, "default/Pod/web.yaml")
+			if err != nil {
+				t.Fatalf("Diff failed: %v", err)
+			}
+			if !strings.Contains(diff, "-image: nginx:1.24\n+image: nginx:1.25\n") {
+				t.Errorf("unexpected diff:\n%s", diff)
+			}
+
+			commits, err := backend.Log("HEAD", 10)
+			if err != nil {
+				t.Fatalf("Log failed: %v", err)
+			}
+			if len(commits) != 2 || commits[0].Hash != second || strings.TrimSpace(commits[1].Message) != "first" {
+				t.Errorf("unexpected log %+v", commits)
+			}
+
+			if _, err := backend.RemoteURL("origin"); err == nil {
+				t.Error("expected an error for a missing remote")
+			}
+		})
+	}
+}
+
+func TestNewBackendInvalid(t *testing.T) {
+	if _, err := NewBackend("libgit2", ""); err == nil {
+		t.Error("expected an error for an unknown backend")
+	}
+}
+
+func TestCommandErrorStderr(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	backend := NewExecBackend(t.TempDir())
+	_, err := backend.ResolveRevision("HEAD")
+	var commandErr *CommandError
+	if !errors.As(err, &commandErr) {
+		t.Fatalf("expected a CommandError, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "not a git repository") {
+		t.Errorf("expected git's stderr in the error, got %q", err.Error())
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	diff := UnifiedDiff("a.yaml", []byte("a: 1\nb: 2\n"), []byte("a: 1\nb: 3\n"))
+	for _, line := range []string{"--- a/a.yaml", "+++ b/a.yaml", "-b: 2", "+b: 3", " a: 1"} {
+		if !strings.Contains(diff, line+"\n") {
+			t.Errorf("expected %q in diff:\n%s", line, diff)
+		}
+	}
+
+	added := UnifiedDiff("new.yaml", nil, []byte("a: 1\n"))
+	if !strings.Contains(added, "--- /dev/null") || !strings.Contains(added, "+a: 1") {
+		t.Errorf("unexpected diff for an added file:\n%s", added)
+	}
+
+	if diff := UnifiedDiff("a.yaml", []byte("a: 1\n"), []byte("a: 1\n")); diff != "" {
+		t.Errorf("expected no diff for identical content, got:\n%s", diff)
+	}
+}
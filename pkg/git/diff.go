@@ -0,0 +1,84 @@
+package git
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	fdiff "github.com/go-git/go-git/v5/plumbing/format/diff"
+	"github.com/go-git/go-git/v5/utils/diff"
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// UnifiedDiff renders the change of a file as a unified diff like git diff, without
+// running git. A nil before or after means the file did not exist on that side.
+func UnifiedDiff(path string, before, after []byte) string {
+	if bytes.Equal(before, after) && (before == nil) == (after == nil) {
+		return ""
+	}
+
+	patch := filePatch{}
+	if before != nil {
+		patch.from = &diffFile{path: path, hash: plumbing.ComputeHash(plumbing.BlobObject, before)}
+	}
+	if after != nil {
+		patch.to = &diffFile{path: path, hash: plumbing.ComputeHash(plumbing.BlobObject, after)}
+	}
+	for _, d := range diff.Do(string(before), string(after)) {
+		op := fdiff.Equal
+		switch d.Type {
+		case diffmatchpatch.DiffDelete:
+			op = fdiff.Delete
+		case diffmatchpatch.DiffInsert:
+			op = fdiff.Add
+		}
+		patch.chunks = append(patch.chunks, diffChunk{content: d.Text, op: op})
+	}
+
+	var out strings.Builder
+	if err := fdiff.NewUnifiedEncoder(&out, fdiff.DefaultContextLines).Encode(patch); err != nil {
+		return ""
+	}
+	return out.String()
+}
+
+// filePatch, diffFile and diffChunk adapt a diff of two contents to go-git's patch encoder
+type filePatch struct {
+	from, to *diffFile
+	chunks   []fdiff.Chunk
+}
+
+func (p filePatch) FilePatches() []fdiff.FilePatch { return []fdiff.FilePatch{p} }
+func (p filePatch) Message() string                { return "" }
+func (p filePatch) IsBinary() bool                 { return false }
+func (p filePatch) Chunks() []fdiff.Chunk          { return p.chunks }
+
+func (p filePatch) Files() (fdiff.File, fdiff.File) {
+	// Nil pointers must become nil interfaces for new and deleted files
+	var from, to fdiff.File
+	if p.from != nil {
+		from = p.from
+	}
+	if p.to != nil {
+		to = p.to
+	}
+	return from, to
+}
+
+type diffFile struct {
+	path string
+	hash plumbing.Hash
+}
+
+func (f *diffFile) Hash() plumbing.Hash     { return f.hash }
+func (f *diffFile) Mode() filemode.FileMode { return filemode.Regular }
+func (f *diffFile) Path() string            { return f.path }
+
+type diffChunk struct {
+	content string
+	op      fdiff.Operation
+}
+
+func (c diffChunk) Content() string       { return c.content }
+func (c diffChunk) Type() fdiff.Operation { return c.op }
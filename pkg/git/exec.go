@@ -0,0 +1,355 @@
+package git
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CommandError is a failed git command, with what git wrote to stderr
+type CommandError struct {
+	Args   []string
+	Stderr string
+	Err    error
+}
+
+func (e *CommandError) Error() string {
+	message := strings.TrimSpace(e.Stderr)
+	if message == "" {
+		message = e.Err.Error()
+	}
+	return fmt.Sprintf("git %s: %s", e.Args[0], message)
+}
+
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+// execBackend runs the git binary
+type execBackend struct {
+	path string
+}
+
+// NewExecBackend returns a backend running the git binary in path
+func NewExecBackend(path string) Backend {
+	return &execBackend{path: path}
+}
+
+func (b *execBackend) Name() string { return BackendExec }
+
+// run runs git with the given arguments and returns its standard output
+func (b *execBackend) run(args ...string) ([]byte, error) {
+	return b.runWithEnv(nil, args...)
+}
+
+// runWithEnv runs git with additional environment variables
+func (b *execBackend) runWithEnv(env []string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = b.path
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, &CommandError{Args: args, Stderr: stderr.String(), Err: err}
+	}
+	return stdout.Bytes(), nil
+}
+
+func (b *execBackend) Init() error {
+	if _, err := b.run("init", "-q"); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (b *execBackend) InitBare() error {
+	if _, err := b.run("init", "-q", "--bare"); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (b *execBackend) AddWorktree(path, branch string) error {
+	ref := "refs/heads/" + branch
+	if _, err := b.run("rev-parse", "--verify", ref); err != nil {
+		// Start the branch from an empty commit so it holds no files of other branches
+		tree, err := b.run("mktree")
+		if err != nil {
+			return err
+		}
+		root, err := b.run("commit-tree", strings.TrimSpace(string(tree)), "-m", rootCommitMessage(branch))
+		if err != nil {
+			return err
+		}
+		if _, err := b.run("update-ref", ref, strings.TrimSpace(string(root))); err != nil {
+			return err
+		}
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("failed to resolve work tree path: %w", err)
+	}
+	if _, err := b.run("worktree", "add", "-q", absPath, branch); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (b *execBackend) AddAll(exclude []string) error {
+	args := []string{"add", "."}
+	for _, pattern := range exclude {
+		args = append(args, ":(exclude)"+pattern)
+	}
+	if _, err := b.run(args...); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (b *execBackend) Status() ([]FileStatus, error) {
+	output, err := b.run("status", "--porcelain", "-z", "--no-renames", "--untracked-files=all")
+	if err != nil {
+		return nil, err
+	}
+
+	var status []FileStatus
+	for _, entry := range strings.Split(string(output), "\x00") {
+		if len(entry) < 4 {
+			continue
+		}
+		status = append(status, FileStatus{
+			Path:     entry[3:],
+			Staging:  StatusCode(entry[0]),
+			Worktree: StatusCode(entry[1]),
+		})
+	}
+	return status, nil
+}
+
+func (b *execBackend) Commit(message string) (string, error) {
+	if _, err := b.run("commit", "-q", "-m", message); err != nil {
+		return "", err
+	}
+	return b.ResolveRevision("HEAD")
+}
+
+func (b *execBackend) ResolveRevision(revision string) (string, error) {
+	output, err := b.run("rev-parse", "--verify", revision+"^{commit}")
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", revision, err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+func (b *execBackend) DiffTree(from, to string) ([]Change, error) {
+	output, err := b.run("diff", "--name-status", "--no-renames", "-z", from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []Change
+	fields := strings.Split(strings.TrimSuffix(string(output), "\x00"), "\x00")
+	for i := 0; i+1 < len(fields); i += 2 {
+		change := Change{Path: fields[i+1], Type: ChangeModified}
+		switch fields[i] {
+		case "A":
+			change.Type = ChangeAdded
+		case "D":
+			change.Type = ChangeDeleted
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+func (b *execBackend) ReadBlob(commit, path string) ([]byte, error) {
+	// ls-tree tells a missing file apart from a failing command
+	listing, err := b.run("ls-tree", "--name-only", commit, "--", path)
+	if err != nil {
+		return nil, err
+	}
+	if len(bytes.TrimSpace(listing)) == 0 {
+		return nil, fmt.Errorf("%s at %s: %w", path, commit, ErrNotFound)
+	}
+	return b.run("cat-file", "blob", commit+":"+path)
+}
+
+func (b *execBackend) Diff(from, to, path string) (string, error) {
+	output, err := b.run("diff", from, to, "--", path)
+	if err != nil {
+		return "", err
+	}
+	return string(output), nil
+}
+
+func (b *execBackend) Log(revision string, limit int) ([]Commit, error) {
+	output, err := b.run("log", "-n", strconv.Itoa(limit), "--format=%H%x00%an%x00%ae%x00%at%x00%B%x1e", revision)
+	if err != nil {
+		return nil, err
+	}
+
+	var commits []Commit
+	for _, record := range strings.Split(string(output), "\x1e") {
+		fields := strings.SplitN(strings.TrimLeft(record, "\n"), "\x00", 5)
+		if len(fields) != 5 {
+			continue
+		}
+		seconds, err := strconv.ParseInt(fields[3], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse commit time '%s': %w", fields[3], err)
+		}
+		commits = append(commits, Commit{
+			Hash:    fields[0],
+			Author:  fields[1],
+			Email:   fields[2],
+			Time:    time.Unix(seconds, 0),
+			Message: strings.TrimRight(fields[4], "\n"),
+		})
+	}
+	return commits, nil
+}
+
+func (b *execBackend) RemoteURL(remote string) (string, error) {
+	output, err := b.run("remote", "get-url", remote)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+func (b *execBackend) CurrentBranch() (string, error) {
+	output, err := b.run("symbolic-ref", "--short", "HEAD")
+	if err != nil {
+		return "", fmt.Errorf("HEAD is not on a branch: %w", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+func (b *execBackend) SetRemoteURL(remote, url string) error {
+	command := "add"
+	if _, err := b.RemoteURL(remote); err == nil {
+		command = "set-url"
+	}
+	if _, err := b.run("remote", command, remote, url); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (b *execBackend) Push(opts PushOptions) error {
+	args := []string{"push"}
+	if current, err := b.CurrentBranch(); err == nil {
+		// Only a missing upstream is set, one configured by the user is kept
+		if _, err := b.run("config", "--get", "branch."+current+".remote"); err != nil {
+			args = append(args, "--set-upstream")
+		}
+	}
+	args = append(args, opts.Remote, "HEAD:refs/heads/"+opts.Branch)
+
+	_, err := b.runWithEnv(authEnv(opts.Auth), args...)
+	var commandErr *CommandError
+	if errors.As(err, &commandErr) && isRejected(commandErr.Stderr) {
+		return fmt.Errorf("%w: %w", ErrPushRejected, err)
+	}
+	return err
+}
+
+func (b *execBackend) Fetch(remote, branch string, auth Auth) error {
+	refSpec := fmt.Sprintf("+refs/heads/%s:refs/remotes/%s/%s", branch, remote, branch)
+	if _, err := b.runWithEnv(authEnv(auth), "fetch", "-q", remote, refSpec); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (b *execBackend) Rebase(upstream string, keepLocal []string) error {
+	_, err := b.run("rebase", "-q", upstream)
+	for err != nil && b.resolveLocal(keepLocal) {
+		// An editor that exits at once keeps the replayed commit's message
+		_, err = b.runWithEnv([]string{"GIT_EDITOR=true"}, "rebase", "--continue")
+	}
+	var commandErr *CommandError
+	if !errors.As(err, &commandErr) {
+		return err
+	}
+	if _, abortErr := b.run("rebase", "--abort"); abortErr != nil {
+		// Nothing to abort: git refused to start the rebase
+		return err
+	}
+	return fmt.Errorf("%w: %w", ErrRebaseConflict, err)
+}
+
+// resolveLocal resolves a stopped rebase whose conflicts are all in keepLocal by taking
+// the version of the commit being replayed, and reports whether it did
+func (b *execBackend) resolveLocal(keepLocal []string) bool {
+	output, err := b.run("diff", "--name-only", "--diff-filter=U")
+	if err != nil {
+		return false
+	}
+	if strings.TrimSpace(string(output)) == "" {
+		return false
+	}
+	conflicts := strings.Split(strings.TrimSpace(string(output)), "\n")
+	keep := make(map[string]bool, len(keepLocal))
+	for _, path := range keepLocal {
+		keep[path] = true
+	}
+	for _, path := range conflicts {
+		if !keep[path] {
+			return false
+		}
+	}
+	for _, path := range conflicts {
+		// During a rebase, "theirs" is the local commit being replayed
+		if _, err := b.run("checkout", "--theirs", "--", path); err != nil {
+			if _, err := b.run("rm", "-q", "--", path); err != nil {
+				return false
+			}
+			continue
+		}
+		if _, err := b.run("add", "--", path); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// isRejected reports whether git's output describes a push refused as non-fast-forward
+func isRejected(stderr string) bool {
+	for _, marker := range []string{"[rejected]", "non-fast-forward", "fetch first"} {
+		if strings.Contains(stderr, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// authEnv returns the environment passing credentials to git without exposing them
+// on its command line
+func authEnv(auth Auth) []string {
+	var env []string
+	if auth.SSHKeyPath != "" {
+		key := "'" + strings.ReplaceAll(auth.SSHKeyPath, "'", `'\''`) + "'"
+		env = append(env, "GIT_SSH_COMMAND=ssh -i "+key+" -o IdentitiesOnly=yes")
+	}
+	if auth.Token != "" {
+		credentials := base64.StdEncoding.EncodeToString([]byte(auth.username() + ":" + auth.Token))
+		env = append(env,
+			"GIT_CONFIG_COUNT=1",
+			"GIT_CONFIG_KEY_0=http.extraHeader",
+			"GIT_CONFIG_VALUE_0=Authorization: Basic "+credentials,
+		)
+	}
+	return env
+}
@@ -1,9 +1,9 @@
 package git
 
 import (
+	"errors"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 	"time"
@@ -11,16 +11,90 @@ import (
 
 // GitRepo handles Git repository operations
 type GitRepo struct {
-	path string
+	path            string
+	backend         Backend
+	excludePatterns []string
+	volatileFiles   []string
+	remote          RemoteConfig
+
+	// sharedRepository is the repository the work tree at path belongs to, committing
+	// to sharedBranch, when several contexts share one repository
+	sharedRepository string
+	sharedBranch     string
 }
 
-// NewGitRepo creates a new GitRepo instance
+// RemoteConfig selects where and how changes are pushed
+type RemoteConfig struct {
+	// Name is the remote pushed to (origin when empty)
+	Name string
+	// URL is added as the remote, or replaces its URL, before pushing
+	URL string
+	// Branch is the remote branch HEAD is pushed to (the current branch when empty)
+	Branch string
+	// Auth holds the SSH key or token used to push
+	Auth Auth
+	// Rebase fetches the remote branch, rebases onto it and retries a rejected push
+	Rebase bool
+}
+
+// NewGitRepo creates a new GitRepo instance using the go-git backend
 func NewGitRepo(path string) *GitRepo {
-	return &GitRepo{path: path}
+	return &GitRepo{path: path, backend: NewGoGitBackend(path)}
+}
+
+// SetBackend sets the backend running Git operations
+func (g *GitRepo) SetBackend(backend Backend) {
+	g.backend = backend
+}
+
+// Backend returns the backend running Git operations
+func (g *GitRepo) Backend() Backend {
+	return g.backend
+}
+
+// SetRemote sets where and how changes are pushed
+func (g *GitRepo) SetRemote(remote RemoteConfig) {
+	g.remote = remote
+}
+
+// RemoteName returns the name of the remote changes are pushed to
+func (g *GitRepo) RemoteName() string {
+	if g.remote.Name != "" {
+		return g.remote.Name
+	}
+	return DefaultRemote
+}
+
+// SetSharedRepository makes the repository a work tree of a shared repository checked out
+// on its own branch, so several contexts can export into one repository side by side
+func (g *GitRepo) SetSharedRepository(repository, branch string) {
+	g.sharedRepository = repository
+	g.sharedBranch = branch
+}
+
+// IsShared reports whether the repository is a work tree of a shared repository
+func (g *GitRepo) IsShared() bool {
+	return g.sharedRepository != ""
+}
+
+// SetExcludePatterns sets pathspec patterns that are never staged (e.g. "*.status.yaml")
+func (g *GitRepo) SetExcludePatterns(patterns []string) {
+	g.excludePatterns = patterns
+}
+
+// SetVolatileFiles sets files, relative to the repository root, that change on every
+// export (e.g. an index with timestamps). They are committed along with other changes,
+// but a change to them alone does not make a commit, and a rebase keeps the local version.
+func (g *GitRepo) SetVolatileFiles(files []string) {
+	g.volatileFiles = files
 }
 
 // Init initializes a new Git repository if it doesn't exist
 func (g *GitRepo) Init() error {
+	if g.IsShared() {
+		return g.initWorktree()
+	}
+
 	// Check if .git directory already exists
 	if g.IsGitRepo() {
 		fmt.Println("  Using existing Git repository")
@@ -35,9 +109,10 @@ func (g *GitRepo) Init() error {
 	}
 
 	// Initialize new Git repository
-	cmd := exec.Command("git", "init")
-	cmd.Dir = g.path
-	if err := cmd.Run(); err != nil {
+	if err := os.MkdirAll(g.path, 0755); err != nil {
+		return fmt.Errorf("failed to create repository directory: %w", err)
+	}
+	if err := g.backend.Init(); err != nil {
 		return fmt.Errorf("failed to initialize Git repository: %w", err)
 	}
 
@@ -50,11 +125,57 @@ func (g *GitRepo) Init() error {
 	return nil
 }
 
+// initWorktree adds the work tree of the shared repository if it doesn't exist, creating
+// the shared repository first if needed
+func (g *GitRepo) initWorktree() error {
+	dotGit, err := os.Stat(filepath.Join(g.path, ".git"))
+	if err == nil {
+		if dotGit.IsDir() {
+			return fmt.Errorf("%s is a standalone Git repository, not a work tree of %s; use another output directory", g.path, g.sharedRepository)
+		}
+		branch, err := g.backend.CurrentBranch()
+		if err != nil {
+			return fmt.Errorf("failed to read the branch of %s: %w", g.path, err)
+		}
+		if branch != g.sharedBranch {
+			return fmt.Errorf("%s is checked out on branch %s, expected %s", g.path, branch, g.sharedBranch)
+		}
+		fmt.Printf("  Using existing work tree on branch %s\n", branch)
+		return nil
+	}
+	if !g.isNewDirectory() {
+		return fmt.Errorf("%s must be empty to become a work tree of %s", g.path, g.sharedRepository)
+	}
+
+	shared, err := NewBackend(g.backend.Name(), g.sharedRepository)
+	if err != nil {
+		return err
+	}
+	if !IsRepository(g.sharedRepository) {
+		fmt.Printf("  Initializing shared Git repository %s...\n", g.sharedRepository)
+		if err := os.MkdirAll(g.sharedRepository, 0755); err != nil {
+			return fmt.Errorf("failed to create shared repository directory: %w", err)
+		}
+		if err := shared.InitBare(); err != nil {
+			return fmt.Errorf("failed to initialize shared repository: %w", err)
+		}
+	}
+
+	fmt.Printf("  Adding work tree for branch %s of %s...\n", g.sharedBranch, g.sharedRepository)
+	if err := shared.AddWorktree(g.path, g.sharedBranch); err != nil {
+		return fmt.Errorf("failed to add work tree: %w", err)
+	}
+	if _, err := os.Stat(filepath.Join(g.path, ".gitignore")); os.IsNotExist(err) {
+		if err := g.createGitignore(); err != nil {
+			return fmt.Errorf("failed to create .gitignore: %w", err)
+		}
+	}
+	return nil
+}
+
 // AddAll adds all files to the Git staging area
 func (g *GitRepo) AddAll() error {
-	cmd := exec.Command("git", "add", ".")
-	cmd.Dir = g.path
-	if err := cmd.Run(); err != nil {
+	if err := g.backend.AddAll(g.excludePatterns); err != nil {
 		return fmt.Errorf("failed to add files to Git: %w", err)
 	}
 	return nil
@@ -67,9 +188,7 @@ func (g *GitRepo) Commit(customMessage string) error {
 		message = "Cluster snapshot: " + time.Now().Format("2006-01-02 15:04:05")
 	}
 
-	cmd := exec.Command("git", "commit", "-m", message)
-	cmd.Dir = g.path
-	if err := cmd.Run(); err != nil {
+	if _, err := g.backend.Commit(message); err != nil {
 		return fmt.Errorf("failed to commit changes: %w", err)
 	}
 
@@ -77,27 +196,47 @@ func (g *GitRepo) Commit(customMessage string) error {
 	return nil
 }
 
-// Push pushes changes to remote origin if available
+// Push pushes HEAD to the configured branch of the remote if available
 func (g *GitRepo) Push() error {
-	// Check if remote origin exists
-	if !g.HasRemoteOrigin() {
-		fmt.Println("  No remote origin found, skipping push")
+	remote := g.RemoteName()
+	if !g.HasRemote() {
+		fmt.Printf("  No remote %s found, skipping push\n", remote)
 		return nil
 	}
 
-	// Push to remote origin
-	cmd := exec.Command("git", "push", "origin", "main")
-	cmd.Dir = g.path
-	if err := cmd.Run(); err != nil {
-		// Try master branch if main doesn't exist
-		cmd = exec.Command("git", "push", "origin", "master")
-		cmd.Dir = g.path
-		if err := cmd.Run(); err != nil {
-			return fmt.Errorf("failed to push to remote origin: %w", err)
+	branch := g.remote.Branch
+	if branch == "" {
+		current, err := g.backend.CurrentBranch()
+		if err != nil {
+			return fmt.Errorf("failed to determine the branch to push: %w", err)
 		}
+		branch = current
 	}
 
-	fmt.Println("  Pushed changes to remote origin")
+	opts := PushOptions{Remote: remote, Branch: branch, Auth: g.remote.Auth}
+	err := g.backend.Push(opts)
+	if errors.Is(err, ErrPushRejected) && g.remote.Rebase {
+		fmt.Printf("  Push rejected, rebasing onto %s/%s and retrying...\n", remote, branch)
+		if err := g.backend.Fetch(remote, branch, g.remote.Auth); err != nil {
+			return err
+		}
+		// Volatile files such as the manifest change in every export, so the local one wins
+		if err := g.backend.Rebase(remote+"/"+branch, g.volatileFiles); err != nil {
+			return fmt.Errorf("failed to rebase onto %s/%s, resolve it manually with git pull --rebase %s %s in %s: %w",
+				remote, branch, remote, branch, g.path, err)
+		}
+		err = g.backend.Push(opts)
+	}
+	if errors.Is(err, ErrPushRejected) {
+		return fmt.Errorf("push to %s/%s was rejected because the remote has commits this repository does not have; "+
+			"run git pull --rebase %s %s in %s, or enable rebase-and-retry with kalco context set --git-rebase: %w",
+			remote, branch, remote, branch, g.path, err)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to push to %s: %w", remote, err)
+	}
+
+	fmt.Printf("  Pushed changes to %s/%s\n", remote, branch)
 	return nil
 }
 
@@ -108,13 +247,27 @@ func (g *GitRepo) SetupAndCommit(customMessage string, shouldPush bool) error {
 		return err
 	}
 
+	// Add or update the configured remote
+	if g.remote.URL != "" {
+		if err := g.backend.SetRemoteURL(g.RemoteName(), g.remote.URL); err != nil {
+			return fmt.Errorf("failed to configure remote %s: %w", g.RemoteName(), err)
+		}
+	}
+
 	// Add all files
 	if err := g.AddAll(); err != nil {
 		return err
 	}
 
 	// Check if there are changes to commit
-	if !g.hasChanges() {
+	changed, err := hasStagedChanges(g.backend, g.volatileFiles)
+	if err != nil {
+		return fmt.Errorf("failed to get Git status: %w", err)
+	}
+	if !changed {
+		if err := g.restoreVolatileFiles(); err != nil {
+			return err
+		}
 		fmt.Println("  No changes detected, skipping commit")
 		return nil
 	}
@@ -124,36 +277,62 @@ func (g *GitRepo) SetupAndCommit(customMessage string, shouldPush bool) error {
 		return err
 	}
 
-	// Check for remote origin and ask user if they want to push
-	if g.HasRemoteOrigin() {
-		fmt.Println("  Remote origin detected!")
+	// Check for the remote and ask user if they want to push
+	remote := g.RemoteName()
+	if g.HasRemote() {
+		fmt.Printf("  Remote %s detected!\n", remote)
 		if shouldPush {
-			fmt.Println("  Auto-push enabled, pushing to remote origin...")
+			fmt.Printf("  Auto-push enabled, pushing to %s...\n", remote)
 			if err := g.Push(); err != nil {
 				return err
 			}
 		} else {
-			fmt.Println("  Use --git-push flag to automatically push changes to remote origin")
+			fmt.Printf("  Use --git-push flag to automatically push changes to %s\n", remote)
 		}
 	} else {
-		fmt.Println("  No remote origin found")
+		fmt.Printf("  No remote %s found\n", remote)
 	}
 
 	return nil
 }
 
-// IsGitRepo checks if the directory is already a Git repository
+// restoreVolatileFiles puts volatile files back to their committed content when no commit
+// is made for their changes alone, so the work tree and index stay clean
+func (g *GitRepo) restoreVolatileFiles() error {
+	restored := false
+	for _, file := range g.volatileFiles {
+		committed, err := g.backend.ReadBlob("HEAD", file)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read committed %s: %w", file, err)
+		}
+		path := filepath.Join(g.path, filepath.FromSlash(file))
+		if current, err := os.ReadFile(path); err == nil && string(current) == string(committed) {
+			continue
+		}
+		if err := os.WriteFile(path, committed, 0644); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", file, err)
+		}
+		restored = true
+	}
+	if !restored {
+		return nil
+	}
+	return g.AddAll()
+}
+
+// IsGitRepo checks if the directory is already a Git repository or a linked work tree
 func (g *GitRepo) IsGitRepo() bool {
-	gitDir := filepath.Join(g.path, ".git")
-	info, err := os.Stat(gitDir)
-	return err == nil && info.IsDir()
+	_, err := os.Stat(filepath.Join(g.path, ".git"))
+	return err == nil
 }
 
-// HasRemoteOrigin checks if the repository has a remote origin
-func (g *GitRepo) HasRemoteOrigin() bool {
-	cmd := exec.Command("git", "remote", "get-url", "origin")
-	cmd.Dir = g.path
-	return cmd.Run() == nil
+// HasRemote checks if the repository has the remote changes are pushed to
+func (g *GitRepo) HasRemote() bool {
+	_, err := g.backend.RemoteURL(g.RemoteName())
+	return err == nil
 }
 
 // isNewDirectory checks if the directory is new (empty or doesn't exist)
@@ -179,14 +358,6 @@ func (g *GitRepo) isNewDirectory() bool {
 	return true
 }
 
-// hasChanges checks if there are uncommitted changes
-func (g *GitRepo) hasChanges() bool {
-	// Check if there are staged changes
-	cmd := exec.Command("git", "diff", "--cached", "--quiet")
-	cmd.Dir = g.path
-	return cmd.Run() != nil
-}
-
 // createGitignore creates a .gitignore file for the repository
 func (g *GitRepo) createGitignore() error {
 	gitignoreContent := `# Kubernetes cluster dumps
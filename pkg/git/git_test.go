@@ -2,7 +2,9 @@ package git
 
 import (
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -53,3 +55,105 @@ func TestCreateGitignore(t *testing.T) {
 		t.Error(".gitignore file was not created")
 	}
 }
+
+func TestAddAllExcludePatterns(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	tempDir := t.TempDir()
+	repo := NewGitRepo(tempDir)
+	repo.SetExcludePatterns([]string{"*.status.yaml"})
+
+	cmd := exec.Command("git", "init")
+	cmd.Dir = tempDir
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("failed to init repository: %v", err)
+	}
+
+	podDir := filepath.Join(tempDir, "default", "Pod")
+	if err := os.MkdirAll(podDir, 0755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	for _, name := range []string{"web.yaml", "web.status.yaml"} {
+		if err := os.WriteFile(filepath.Join(podDir, name), []byte("kind: Pod\n"), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+
+	if err := repo.AddAll(); err != nil {
+		t.Fatalf("AddAll failed: %v", err)
+	}
+
+	cmd = exec.Command("git", "diff", "--cached", "--name-only")
+	cmd.Dir = tempDir
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("failed to list staged files: %v", err)
+	}
+	staged := strings.TrimSpace(string(output))
+	if staged != "default/Pod/web.yaml" {
+		t.Errorf("expected only the spec file to be staged, got %q", staged)
+	}
+}
+
+func TestSetupAndCommitVolatileFiles(t *testing.T) {
+	t.Setenv("GIT_AUTHOR_NAME", "Test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "Test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+
+	for _, name := range BackendNames {
+		t.Run(name, func(t *testing.T) {
+			if _, err := exec.LookPath("git"); err != nil {
+				t.Skip("git not available")
+			}
+
+			dir := t.TempDir()
+			backend, err := NewBackend(name, dir)
+			if err != nil {
+				t.Fatalf("NewBackend failed: %v", err)
+			}
+			repo := NewGitRepo(dir)
+			repo.SetBackend(backend)
+			repo.SetVolatileFiles([]string{"kalco-manifest.json"})
+
+			writeFile(t, dir, "default/Pod/web.yaml", "kind: Pod\n")
+			writeFile(t, dir, "kalco-manifest.json", `{"exportTime":"1"}`)
+			if err := repo.SetupAndCommit("first", false); err != nil {
+				t.Fatalf("SetupAndCommit failed: %v", err)
+			}
+			first, err := backend.ResolveRevision("HEAD")
+			if err != nil {
+				t.Fatalf("failed to resolve HEAD: %v", err)
+			}
+
+			// Only the volatile file changed: no commit, and it is restored
+			writeFile(t, dir, "kalco-manifest.json", `{"exportTime":"2"}`)
+			if err := repo.SetupAndCommit("second", false); err != nil {
+				t.Fatalf("SetupAndCommit failed: %v", err)
+			}
+			if head, _ := backend.ResolveRevision("HEAD"); head != first {
+				t.Errorf("expected no commit for a volatile file change, HEAD moved to %s", head)
+			}
+			if status := runGit(t, dir, "status", "--porcelain"); status != "" {
+				t.Errorf("expected a clean work tree, got:\n%s", status)
+			}
+
+			// Along with another change, the volatile file is committed too
+			writeFile(t, dir, "default/Pod/web.yaml", "kind: Pod\nimage: nginx\n")
+			writeFile(t, dir, "kalco-manifest.json", `{"exportTime":"3"}`)
+			if err := repo.SetupAndCommit("third", false); err != nil {
+				t.Fatalf("SetupAndCommit failed: %v", err)
+			}
+			head, err := backend.ResolveRevision("HEAD")
+			if err != nil || head == first {
+				t.Fatalf("expected a new commit, got %s (%v)", head, err)
+			}
+			content, err := backend.ReadBlob(head, "kalco-manifest.json")
+			if err != nil || string(content) != `{"exportTime":"3"}` {
+				t.Errorf("expected the latest manifest to be committed, got %q (%v)", content, err)
+			}
+		})
+	}
+}
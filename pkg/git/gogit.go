@@ -0,0 +1,423 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/go-git/go-git/v5/utils/merkletrie"
+)
+
+// Identity used by the go-git backend when no user is configured in Git, so commits
+// also work in minimal containers
+const (
+	DefaultAuthorName  = "kalco"
+	DefaultAuthorEmail = "kalco@localhost"
+)
+
+// goGitBackend implements Git natively with go-git
+type goGitBackend struct {
+	path string
+	repo *gogit.Repository
+}
+
+// NewGoGitBackend returns a backend operating natively on the repository in path
+func NewGoGitBackend(path string) Backend {
+	return &goGitBackend{path: path}
+}
+
+func (b *goGitBackend) Name() string { return BackendGoGit }
+
+// open opens the repository once it exists
+func (b *goGitBackend) open() (*gogit.Repository, error) {
+	if b.repo != nil {
+		return b.repo, nil
+	}
+	// Linked work trees keep objects and refs in the common directory of their repository
+	repo, err := gogit.PlainOpenWithOptions(b.path, &gogit.PlainOpenOptions{EnableDotGitCommonDir: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open Git repository: %w", err)
+	}
+	b.repo = repo
+	return repo, nil
+}
+
+func (b *goGitBackend) Init() error {
+	repo, err := gogit.PlainInit(b.path, false)
+	if err != nil {
+		return fmt.Errorf("failed to initialize Git repository: %w", err)
+	}
+	b.repo = repo
+	return nil
+}
+
+func (b *goGitBackend) InitBare() error {
+	repo, err := gogit.PlainInit(b.path, true)
+	if err != nil {
+		return fmt.Errorf("failed to initialize Git repository: %w", err)
+	}
+	b.repo = repo
+	return nil
+}
+
+func (b *goGitBackend) AddAll(exclude []string) error {
+	repo, err := b.open()
+	if err != nil {
+		return err
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+	// Excluded paths are treated as ignored while staging, like :(exclude) pathspecs
+	for _, pattern := range exclude {
+		worktree.Excludes = append(worktree.Excludes, gitignore.ParsePattern(pattern, nil))
+	}
+	if err := worktree.AddWithOptions(&gogit.AddOptions{All: true}); err != nil {
+		return fmt.Errorf("failed to add files: %w", err)
+	}
+	return nil
+}
+
+func (b *goGitBackend) Status() ([]FileStatus, error) {
+	repo, err := b.open()
+	if err != nil {
+		return nil, err
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return nil, err
+	}
+	status, err := worktree.Status()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get status: %w", err)
+	}
+
+	files := make([]FileStatus, 0, len(status))
+	for path, file := range status {
+		if file.Staging == gogit.Unmodified && file.Worktree == gogit.Unmodified {
+			continue
+		}
+		files = append(files, FileStatus{Path: path, Staging: StatusCode(file.Staging), Worktree: StatusCode(file.Worktree)})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, nil
+}
+
+func (b *goGitBackend) Commit(message string) (string, error) {
+	repo, err := b.open()
+	if err != nil {
+		return "", err
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return "", err
+	}
+
+	opts, err := commitOptions(repo)
+	if err != nil {
+		return "", fmt.Errorf("failed to commit: %w", err)
+	}
+	hash, err := worktree.Commit(message, opts)
+	if err != nil {
+		return "", fmt.Errorf("failed to commit: %w", err)
+	}
+	return hash.String(), nil
+}
+
+// commitOptions returns commit options with the identity configured in Git, or kalco's
+// default identity when none is configured
+func commitOptions(repo *gogit.Repository) (*gogit.CommitOptions, error) {
+	opts := &gogit.CommitOptions{}
+	err := opts.Validate(repo)
+	if errors.Is(err, gogit.ErrMissingAuthor) {
+		opts.Author = &object.Signature{Name: DefaultAuthorName, Email: DefaultAuthorEmail, When: time.Now()}
+		err = opts.Validate(repo)
+	}
+	return opts, err
+}
+
+func (b *goGitBackend) ResolveRevision(revision string) (string, error) {
+	repo, err := b.open()
+	if err != nil {
+		return "", err
+	}
+	hash, err := repo.ResolveRevision(plumbing.Revision(revision))
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", revision, err)
+	}
+	return hash.String(), nil
+}
+
+// commit returns the commit object of a revision
+func (b *goGitBackend) commit(revision string) (*object.Commit, error) {
+	hash, err := b.ResolveRevision(revision)
+	if err != nil {
+		return nil, err
+	}
+	commit, err := b.repo.CommitObject(plumbing.NewHash(hash))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read commit %s: %w", revision, err)
+	}
+	return commit, nil
+}
+
+// tree returns the tree of a revision
+func (b *goGitBackend) tree(revision string) (*object.Tree, error) {
+	commit, err := b.commit(revision)
+	if err != nil {
+		return nil, err
+	}
+	return commit.Tree()
+}
+
+func (b *goGitBackend) DiffTree(from, to string) ([]Change, error) {
+	fromTree, err := b.tree(from)
+	if err != nil {
+		return nil, err
+	}
+	toTree, err := b.tree(to)
+	if err != nil {
+		return nil, err
+	}
+	diff, err := object.DiffTree(fromTree, toTree)
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff %s and %s: %w", from, to, err)
+	}
+
+	changes := make([]Change, 0, len(diff))
+	for _, entry := range diff {
+		action, err := entry.Action()
+		if err != nil {
+			return nil, err
+		}
+		switch action {
+		case merkletrie.Insert:
+			changes = append(changes, Change{Path: entry.To.Name, Type: ChangeAdded})
+		case merkletrie.Delete:
+			changes = append(changes, Change{Path: entry.From.Name, Type: ChangeDeleted})
+		default:
+			changes = append(changes, Change{Path: entry.To.Name, Type: ChangeModified})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes, nil
+}
+
+func (b *goGitBackend) ReadBlob(commit, path string) ([]byte, error) {
+	tree, err := b.tree(commit)
+	if err != nil {
+		return nil, err
+	}
+	file, err := tree.File(path)
+	if errors.Is(err, object.ErrFileNotFound) || errors.Is(err, object.ErrDirectoryNotFound) {
+		return nil, fmt.Errorf("%s at %s: %w", path, commit, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s at %s: %w", path, commit, err)
+	}
+
+	reader, err := file.Reader()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s at %s: %w", path, commit, err)
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+func (b *goGitBackend) Diff(from, to, path string) (string, error) {
+	before, err := b.ReadBlob(from, path)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return "", err
+	}
+	after, err := b.ReadBlob(to, path)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return "", err
+	}
+	return UnifiedDiff(path, before, after), nil
+}
+
+func (b *goGitBackend) Log(revision string, limit int) ([]Commit, error) {
+	hash, err := b.ResolveRevision(revision)
+	if err != nil {
+		return nil, err
+	}
+	iter, err := b.repo.Log(&gogit.LogOptions{From: plumbing.NewHash(hash)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read log: %w", err)
+	}
+	defer iter.Close()
+
+	var commits []Commit
+	for len(commits) < limit {
+		commit, err := iter.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read log: %w", err)
+		}
+		commits = append(commits, Commit{
+			Hash:    commit.Hash.String(),
+			Author:  commit.Author.Name,
+			Email:   commit.Author.Email,
+			Time:    commit.Author.When,
+			Message: commit.Message,
+		})
+	}
+	return commits, nil
+}
+
+func (b *goGitBackend) RemoteURL(remote string) (string, error) {
+	repo, err := b.open()
+	if err != nil {
+		return "", err
+	}
+	r, err := repo.Remote(remote)
+	if err != nil {
+		return "", fmt.Errorf("failed to get remote %s: %w", remote, err)
+	}
+	if urls := r.Config().URLs; len(urls) > 0 {
+		return urls[0], nil
+	}
+	return "", fmt.Errorf("remote %s has no URL", remote)
+}
+
+func (b *goGitBackend) CurrentBranch() (string, error) {
+	repo, err := b.open()
+	if err != nil {
+		return "", err
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve HEAD: %w", err)
+	}
+	if !head.Name().IsBranch() {
+		return "", fmt.Errorf("HEAD is not on a branch")
+	}
+	return head.Name().Short(), nil
+}
+
+func (b *goGitBackend) SetRemoteURL(remote, url string) error {
+	repo, err := b.open()
+	if err != nil {
+		return err
+	}
+	cfg, err := repo.Config()
+	if err != nil {
+		return fmt.Errorf("failed to read Git config: %w", err)
+	}
+	if existing, ok := cfg.Remotes[remote]; ok {
+		existing.URLs = []string{url}
+	} else {
+		cfg.Remotes[remote] = &config.RemoteConfig{
+			Name:  remote,
+			URLs:  []string{url},
+			Fetch: []config.RefSpec{config.RefSpec(fmt.Sprintf(config.DefaultFetchRefSpec, remote))},
+		}
+	}
+	if err := repo.SetConfig(cfg); err != nil {
+		return fmt.Errorf("failed to set remote %s: %w", remote, err)
+	}
+	return nil
+}
+
+func (b *goGitBackend) Push(opts PushOptions) error {
+	repo, err := b.open()
+	if err != nil {
+		return err
+	}
+	current, err := b.CurrentBranch()
+	if err != nil {
+		return err
+	}
+	auth, err := b.auth(opts.Remote, opts.Auth)
+	if err != nil {
+		return err
+	}
+
+	target := plumbing.NewBranchReferenceName(opts.Branch)
+	refSpec := config.RefSpec(plumbing.NewBranchReferenceName(current).String() + ":" + target.String())
+	err = repo.Push(&gogit.PushOptions{RemoteName: opts.Remote, RefSpecs: []config.RefSpec{refSpec}, Auth: auth})
+	if err != nil && !errors.Is(err, gogit.NoErrAlreadyUpToDate) {
+		if isRejected(err.Error()) || errors.Is(err, gogit.ErrForceNeeded) {
+			return fmt.Errorf("%w: %w", ErrPushRejected, err)
+		}
+		return fmt.Errorf("failed to push to %s: %w", opts.Remote, err)
+	}
+
+	// Only a missing upstream is set, one configured by the user is kept
+	cfg, err := repo.Config()
+	if err != nil {
+		return fmt.Errorf("failed to read Git config: %w", err)
+	}
+	if _, ok := cfg.Branches[current]; !ok {
+		cfg.Branches[current] = &config.Branch{Name: current, Remote: opts.Remote, Merge: target}
+		if err := repo.SetConfig(cfg); err != nil {
+			return fmt.Errorf("failed to set upstream of %s: %w", current, err)
+		}
+	}
+	return nil
+}
+
+func (b *goGitBackend) Fetch(remote, branch string, auth Auth) error {
+	repo, err := b.open()
+	if err != nil {
+		return err
+	}
+	method, err := b.auth(remote, auth)
+	if err != nil {
+		return err
+	}
+	refSpec := config.RefSpec(fmt.Sprintf("+refs/heads/%s:refs/remotes/%s/%s", branch, remote, branch))
+	err = repo.Fetch(&gogit.FetchOptions{RemoteName: remote, RefSpecs: []config.RefSpec{refSpec}, Auth: method})
+	if err != nil && !errors.Is(err, gogit.NoErrAlreadyUpToDate) {
+		return fmt.Errorf("failed to fetch %s from %s: %w", branch, remote, err)
+	}
+	return nil
+}
+
+// auth returns the go-git auth method for a remote: the SSH key for SSH URLs, the
+// token for HTTP(S) URLs, nil (ssh-agent or anonymous) otherwise
+func (b *goGitBackend) auth(remote string, auth Auth) (transport.AuthMethod, error) {
+	url, err := b.RemoteURL(remote)
+	if err != nil {
+		return nil, err
+	}
+	endpoint, err := transport.NewEndpoint(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL of remote %s: %w", remote, err)
+	}
+
+	switch endpoint.Protocol {
+	case "ssh":
+		if auth.SSHKeyPath == "" {
+			return nil, nil
+		}
+		user := endpoint.User
+		if user == "" {
+			user = "git"
+		}
+		keys, err := ssh.NewPublicKeysFromFile(user, auth.SSHKeyPath, "")
+		if err != nil {
+			return nil, fmt.Errorf("failed to load SSH key %s: %w", auth.SSHKeyPath, err)
+		}
+		return keys, nil
+	case "http", "https":
+		if auth.Token == "" {
+			return nil, nil
+		}
+		return &http.BasicAuth{Username: auth.username(), Password: auth.Token}, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,226 @@
+package git
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runGit runs the git binary in dir and returns its trimmed output
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s failed: %v\n%s", strings.Join(args, " "), err, output)
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// setupRemote creates a bare remote and a repository of the given backend with one
+// commit, configured to push to the remote's snapshots branch
+func setupRemote(t *testing.T, name string) (*GitRepo, string) {
+	t.Helper()
+	// Pushing to a local path runs git's pack programs, also with go-git
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	t.Setenv("GIT_AUTHOR_NAME", "Test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "Test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+
+	remote := filepath.Join(t.TempDir(), "remote.git")
+	runGit(t, t.TempDir(), "init", "-q", "--bare", remote)
+
+	dir := t.TempDir()
+	backend, err := NewBackend(name, dir)
+	if err != nil {
+		t.Fatalf("NewBackend failed: %v", err)
+	}
+	repo := NewGitRepo(dir)
+	repo.SetBackend(backend)
+	repo.SetRemote(RemoteConfig{URL: remote, Branch: "snapshots"})
+
+	writeFile(t, dir, "default/Pod/web.yaml", "kind: Pod\n")
+	if err := repo.SetupAndCommit("first", true); err != nil {
+		t.Fatalf("SetupAndCommit failed: %v", err)
+	}
+	return repo, remote
+}
+
+func TestPushConfiguredBranch(t *testing.T) {
+	for _, name := range BackendNames {
+		t.Run(name, func(t *testing.T) {
+			repo, remote := setupRemote(t, name)
+
+			head, err := repo.Backend().ResolveRevision("HEAD")
+			if err != nil {
+				t.Fatalf("failed to resolve HEAD: %v", err)
+			}
+			if pushed := runGit(t, remote, "rev-parse", "refs/heads/snapshots"); pushed != head {
+				t.Errorf("expected snapshots at %s, got %s", head, pushed)
+			}
+
+			branch, err := repo.Backend().CurrentBranch()
+			if err != nil {
+				t.Fatalf("CurrentBranch failed: %v", err)
+			}
+			if upstream := runGit(t, repo.path, "config", "branch."+branch+".merge"); upstream != "refs/heads/snapshots" {
+				t.Errorf("expected upstream refs/heads/snapshots, got %s", upstream)
+			}
+		})
+	}
+}
+
+func TestPushRejectedRebase(t *testing.T) {
+	for _, name := range BackendNames {
+		t.Run(name, func(t *testing.T) {
+			repo, remote := setupRemote(t, name)
+
+			// Another writer pushes a commit the repository does not have
+			other := t.TempDir()
+			runGit(t, other, "clone", "-q", "--branch", "snapshots", remote, ".")
+			writeFile(t, other, "README.md", "# Snapshots\n")
+			runGit(t, other, "add", ".")
+			runGit(t, other, "commit", "-q", "-m", "Add README")
+			runGit(t, other, "push", "-q", "origin", "snapshots")
+
+			writeFile(t, repo.path, "default/Pod/web.yaml", "kind: Pod\nimage: nginx\n")
+			if err := repo.AddAll(); err != nil {
+				t.Fatalf("AddAll failed: %v", err)
+			}
+			if err := repo.Commit("second"); err != nil {
+				t.Fatalf("Commit failed: %v", err)
+			}
+
+			err := repo.Push()
+			if !errors.Is(err, ErrPushRejected) {
+				t.Fatalf("expected ErrPushRejected, got %v", err)
+			}
+			if !strings.Contains(err.Error(), "git pull --rebase origin snapshots") {
+				t.Errorf("expected a remedy in the error, got %q", err.Error())
+			}
+
+			repo.SetRemote(RemoteConfig{Branch: "snapshots", Rebase: true})
+			if err := repo.Push(); err != nil {
+				t.Fatalf("Push with rebase failed: %v", err)
+			}
+
+			head, err := repo.Backend().ResolveRevision("HEAD")
+			if err != nil {
+				t.Fatalf("failed to resolve HEAD: %v", err)
+			}
+			if pushed := runGit(t, remote, "rev-parse", "refs/heads/snapshots"); pushed != head {
+				t.Errorf("expected snapshots at %s, got %s", head, pushed)
+			}
+			if log := runGit(t, repo.path, "log", "--format=%s"); log != "second\nAdd README\nfirst" {
+				t.Errorf("unexpected history:\n%s", log)
+			}
+			if _, err := os.Stat(filepath.Join(repo.path, "README.md")); err != nil {
+				t.Errorf("expected the remote's file in the work tree: %v", err)
+			}
+			if status := runGit(t, repo.path, "status", "--porcelain"); status != "" {
+				t.Errorf("expected a clean work tree, got:\n%s", status)
+			}
+		})
+	}
+}
+
+func TestRebaseKeepsLocalVolatileFiles(t *testing.T) {
+	for _, name := range BackendNames {
+		t.Run(name, func(t *testing.T) {
+			repo, remote := setupRemote(t, name)
+			repo.SetVolatileFiles([]string{"kalco-manifest.json"})
+
+			// Both writers export, each rewriting the manifest
+			other := t.TempDir()
+			runGit(t, other, "clone", "-q", "--branch", "snapshots", remote, ".")
+			writeFile(t, other, "default/Service/web.yaml", "kind: Service\n")
+			writeFile(t, other, "kalco-manifest.json", `{"exportTime":"remote"}`)
+			runGit(t, other, "add", ".")
+			runGit(t, other, "commit", "-q", "-m", "Remote export")
+			runGit(t, other, "push", "-q", "origin", "snapshots")
+
+			writeFile(t, repo.path, "default/Pod/web.yaml", "kind: Pod\nimage: nginx\n")
+			writeFile(t, repo.path, "kalco-manifest.json", `{"exportTime":"local"}`)
+			if err := repo.AddAll(); err != nil {
+				t.Fatalf("AddAll failed: %v", err)
+			}
+			if err := repo.Commit("Local export"); err != nil {
+				t.Fatalf("Commit failed: %v", err)
+			}
+
+			repo.SetRemote(RemoteConfig{Branch: "snapshots", Rebase: true})
+			if err := repo.Push(); err != nil {
+				t.Fatalf("Push with rebase failed: %v", err)
+			}
+
+			if log := runGit(t, repo.path, "log", "--format=%s"); log != "Local export\nRemote export\nfirst" {
+				t.Errorf("unexpected history:\n%s", log)
+			}
+			if manifest := runGit(t, repo.path, "show", "HEAD:kalco-manifest.json"); manifest != `{"exportTime":"local"}` {
+				t.Errorf("expected the local manifest to be kept, got %s", manifest)
+			}
+			if _, err := os.Stat(filepath.Join(repo.path, "default", "Service", "web.yaml")); err != nil {
+				t.Errorf("expected the remote's file in the work tree: %v", err)
+			}
+			if status := runGit(t, repo.path, "status", "--porcelain"); status != "" {
+				t.Errorf("expected a clean work tree, got:\n%s", status)
+			}
+		})
+	}
+}
+
+func TestRebaseConflict(t *testing.T) {
+	for _, name := range BackendNames {
+		t.Run(name, func(t *testing.T) {
+			repo, remote := setupRemote(t, name)
+
+			other := t.TempDir()
+			runGit(t, other, "clone", "-q", "--branch", "snapshots", remote, ".")
+			writeFile(t, other, "default/Pod/web.yaml", "kind: Pod\nimage: httpd\n")
+			runGit(t, other, "commit", "-q", "-am", "Edit web")
+			runGit(t, other, "push", "-q", "origin", "snapshots")
+
+			writeFile(t, repo.path, "default/Pod/web.yaml", "kind: Pod\nimage: nginx\n")
+			if err := repo.AddAll(); err != nil {
+				t.Fatalf("AddAll failed: %v", err)
+			}
+			if err := repo.Commit("second"); err != nil {
+				t.Fatalf("Commit failed: %v", err)
+			}
+			before, err := repo.Backend().ResolveRevision("HEAD")
+			if err != nil {
+				t.Fatalf("failed to resolve HEAD: %v", err)
+			}
+
+			repo.SetRemote(RemoteConfig{Branch: "snapshots", Rebase: true})
+			if err := repo.Push(); !errors.Is(err, ErrRebaseConflict) {
+				t.Fatalf("expected ErrRebaseConflict, got %v", err)
+			}
+			if after, _ := repo.Backend().ResolveRevision("HEAD"); after != before {
+				t.Errorf("expected the branch to stay at %s, got %s", before, after)
+			}
+		})
+	}
+}
+
+func TestAuthEnv(t *testing.T) {
+	if env := authEnv(Auth{}); len(env) != 0 {
+		t.Errorf("expected no environment without credentials, got %v", env)
+	}
+
+	env := strings.Join(authEnv(Auth{SSHKeyPath: "/keys/it's", Token: "secret"}), "\n")
+	if !strings.Contains(env, `GIT_SSH_COMMAND=ssh -i '/keys/it'\''s' -o IdentitiesOnly=yes`) {
+		t.Errorf("expected a quoted SSH key in GIT_SSH_COMMAND, got:\n%s", env)
+	}
+	// base64("kalco:secret")
+	if !strings.Contains(env, "GIT_CONFIG_VALUE_0=Authorization: Basic a2FsY286c2VjcmV0") {
+		t.Errorf("expected the token in an HTTP header, got:\n%s", env)
+	}
+}
@@ -0,0 +1,282 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/go-git/go-git/v5/utils/merkletrie"
+)
+
+// Rebase replays the local commits onto upstream. go-git has no merge machinery, so
+// only commits touching files upstream did not change, or files in keepLocal, can be
+// replayed; any other overlap is reported as a conflict before anything is changed.
+func (b *goGitBackend) Rebase(upstream string, keepLocal []string) error {
+	repo, err := b.open()
+	if err != nil {
+		return err
+	}
+	head, err := b.commit("HEAD")
+	if err != nil {
+		return err
+	}
+	onto, err := b.commit(upstream)
+	if err != nil {
+		return err
+	}
+
+	bases, err := head.MergeBase(onto)
+	if err != nil {
+		return fmt.Errorf("failed to find the merge base with %s: %w", upstream, err)
+	}
+	if len(bases) == 0 {
+		return fmt.Errorf("%w: no common history with %s", ErrRebaseConflict, upstream)
+	}
+	base := bases[0]
+	if base.Hash == onto.Hash {
+		return nil
+	}
+
+	// Local commits, oldest first
+	var local []*object.Commit
+	for commit := head; commit.Hash != base.Hash; {
+		if commit.NumParents() != 1 {
+			return fmt.Errorf("%w: cannot replay merge commit %s", ErrRebaseConflict, commit.Hash)
+		}
+		local = append([]*object.Commit{commit}, local...)
+		if commit, err = commit.Parent(0); err != nil {
+			return err
+		}
+	}
+
+	upstreamChanges, err := commitChanges(base, onto)
+	if err != nil {
+		return err
+	}
+	keep := make(map[string]bool, len(keepLocal))
+	for _, path := range keepLocal {
+		keep[path] = true
+	}
+	var conflicts []string
+	localChanged := make(map[string]bool)
+	for _, commit := range local {
+		parent, err := commit.Parent(0)
+		if err != nil {
+			return err
+		}
+		changes, err := commitChanges(parent, commit)
+		if err != nil {
+			return err
+		}
+		for path := range changes {
+			localChanged[path] = true
+			if _, ok := upstreamChanges[path]; ok && !keep[path] {
+				conflicts = append(conflicts, path)
+			}
+		}
+	}
+	if len(conflicts) > 0 {
+		sort.Strings(conflicts)
+		return fmt.Errorf("%w: %s changed both locally and in %s", ErrRebaseConflict, strings.Join(conflicts, ", "), upstream)
+	}
+
+	// Replay each local commit's changes on top of upstream
+	entries, err := treeEntries(onto)
+	if err != nil {
+		return err
+	}
+	parent := onto.Hash
+	for _, commit := range local {
+		previous, err := commit.Parent(0)
+		if err != nil {
+			return err
+		}
+		changes, err := commitChanges(previous, commit)
+		if err != nil {
+			return err
+		}
+		for path, entry := range changes {
+			if entry == nil {
+				delete(entries, path)
+			} else {
+				entries[path] = *entry
+			}
+		}
+
+		tree, err := writeTree(repo.Storer, entries)
+		if err != nil {
+			return fmt.Errorf("failed to write tree: %w", err)
+		}
+		committer := commit.Committer
+		committer.When = time.Now()
+		replayed := &object.Commit{
+			Author:       commit.Author,
+			Committer:    committer,
+			Message:      commit.Message,
+			TreeHash:     tree,
+			ParentHashes: []plumbing.Hash{parent},
+		}
+		if parent, err = writeObject(repo.Storer, replayed); err != nil {
+			return fmt.Errorf("failed to write commit: %w", err)
+		}
+	}
+
+	// Bring upstream's files into the work tree, then move the branch and index. Files
+	// kept local were changed by a local commit, so the work tree already holds them.
+	for path, entry := range upstreamChanges {
+		if keep[path] && localChanged[path] {
+			continue
+		}
+		if err := b.checkoutFile(onto, path, entry); err != nil {
+			return err
+		}
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+	if err := worktree.Reset(&gogit.ResetOptions{Commit: parent, Mode: gogit.MixedReset}); err != nil {
+		return fmt.Errorf("failed to update the branch: %w", err)
+	}
+	return nil
+}
+
+// commitChanges returns the files changed from one commit to another, with their new
+// tree entry or nil when deleted
+func commitChanges(from, to *object.Commit) (map[string]*object.TreeEntry, error) {
+	fromTree, err := from.Tree()
+	if err != nil {
+		return nil, err
+	}
+	toTree, err := to.Tree()
+	if err != nil {
+		return nil, err
+	}
+	diff, err := object.DiffTree(fromTree, toTree)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make(map[string]*object.TreeEntry, len(diff))
+	for _, change := range diff {
+		action, err := change.Action()
+		if err != nil {
+			return nil, err
+		}
+		if action == merkletrie.Delete {
+			changes[change.From.Name] = nil
+			continue
+		}
+		entry := change.To.TreeEntry
+		changes[change.To.Name] = &entry
+	}
+	return changes, nil
+}
+
+// treeEntries returns every non-directory entry of a commit's tree by path
+func treeEntries(commit *object.Commit) (map[string]object.TreeEntry, error) {
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+	walker := object.NewTreeWalker(tree, true, nil)
+	defer walker.Close()
+
+	entries := make(map[string]object.TreeEntry)
+	for {
+		path, entry, err := walker.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if entry.Mode != filemode.Dir {
+			entries[path] = entry
+		}
+	}
+	return entries, nil
+}
+
+// writeTree stores the trees holding entries and returns the root tree's hash
+func writeTree(s storer.EncodedObjectStorer, entries map[string]object.TreeEntry) (plumbing.Hash, error) {
+	tree := &object.Tree{}
+	dirs := make(map[string]map[string]object.TreeEntry)
+	for path, entry := range entries {
+		dir, rest, nested := strings.Cut(path, "/")
+		if !nested {
+			entry.Name = path
+			tree.Entries = append(tree.Entries, entry)
+			continue
+		}
+		if dirs[dir] == nil {
+			dirs[dir] = make(map[string]object.TreeEntry)
+		}
+		dirs[dir][rest] = entry
+	}
+	for dir, children := range dirs {
+		hash, err := writeTree(s, children)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		tree.Entries = append(tree.Entries, object.TreeEntry{Name: dir, Mode: filemode.Dir, Hash: hash})
+	}
+
+	// Git sorts directories as if their name ended with a slash
+	sortName := func(entry object.TreeEntry) string {
+		if entry.Mode == filemode.Dir {
+			return entry.Name + "/"
+		}
+		return entry.Name
+	}
+	sort.Slice(tree.Entries, func(i, j int) bool { return sortName(tree.Entries[i]) < sortName(tree.Entries[j]) })
+	return writeObject(s, tree)
+}
+
+// writeObject stores a tree or commit and returns its hash
+func writeObject(s storer.EncodedObjectStorer, o interface {
+	Encode(plumbing.EncodedObject) error
+}) (plumbing.Hash, error) {
+	obj := s.NewEncodedObject()
+	if err := o.Encode(obj); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return s.SetEncodedObject(obj)
+}
+
+// checkoutFile writes a file of a commit to the work tree, or removes it when entry is nil
+func (b *goGitBackend) checkoutFile(commit *object.Commit, path string, entry *object.TreeEntry) error {
+	target := filepath.Join(b.path, filepath.FromSlash(path))
+	if entry == nil {
+		if err := os.Remove(target); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+		return nil
+	}
+
+	content, err := b.ReadBlob(commit.Hash.String(), path)
+	if err != nil {
+		return err
+	}
+	mode := os.FileMode(0644)
+	if entry.Mode == filemode.Executable {
+		mode = 0755
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", path, err)
+	}
+	if err := os.WriteFile(target, content, mode); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// ClusterBranchPrefix prefixes the branch of each context in a shared repository
+const ClusterBranchPrefix = "clusters/"
+
+// IsRepository reports whether path holds a Git repository, bare or not
+func IsRepository(path string) bool {
+	if _, err := os.Stat(filepath.Join(path, ".git")); err == nil {
+		return true
+	}
+	_, headErr := os.Stat(filepath.Join(path, "HEAD"))
+	_, objectsErr := os.Stat(filepath.Join(path, "objects"))
+	return headErr == nil && objectsErr == nil
+}
+
+// gitDir returns the Git directory of a repository: .git, or the repository itself when bare
+func gitDir(path string) string {
+	if info, err := os.Stat(filepath.Join(path, ".git")); err == nil && info.IsDir() {
+		return filepath.Join(path, ".git")
+	}
+	return path
+}
+
+// AddWorktree links a new work tree at path to the repository, the way git worktree
+// add does, since go-git cannot create work trees itself
+func (b *goGitBackend) AddWorktree(path, branch string) error {
+	repo, err := b.open()
+	if err != nil {
+		return err
+	}
+	if err := b.ensureBranch(repo, branch); err != nil {
+		return err
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("failed to resolve work tree path: %w", err)
+	}
+	common, err := filepath.Abs(gitDir(b.path))
+	if err != nil {
+		return fmt.Errorf("failed to resolve repository path: %w", err)
+	}
+
+	// Administrative files of the work tree, named after its directory like git does
+	name := filepath.Base(absPath)
+	admin := filepath.Join(common, "worktrees", name)
+	for i := 1; ; i++ {
+		if _, err := os.Stat(admin); os.IsNotExist(err) {
+			break
+		}
+		admin = filepath.Join(common, "worktrees", name+strconv.Itoa(i))
+	}
+	if err := os.MkdirAll(admin, 0755); err != nil {
+		return fmt.Errorf("failed to create work tree metadata: %w", err)
+	}
+	files := map[string]string{
+		"HEAD":      "ref: " + plumbing.NewBranchReferenceName(branch).String() + "\n",
+		"commondir": "../..\n",
+		"gitdir":    filepath.Join(absPath, ".git") + "\n",
+	}
+	for file, content := range files {
+		if err := os.WriteFile(filepath.Join(admin, file), []byte(content), 0644); err != nil {
+			return fmt.Errorf("failed to write work tree metadata: %w", err)
+		}
+	}
+	if err := os.MkdirAll(absPath, 0755); err != nil {
+		return fmt.Errorf("failed to create work tree: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(absPath, ".git"), []byte("gitdir: "+admin+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to link work tree: %w", err)
+	}
+
+	// Check out the branch's files
+	linked := &goGitBackend{path: absPath}
+	worktreeRepo, err := linked.open()
+	if err != nil {
+		return err
+	}
+	head, err := linked.ResolveRevision("HEAD")
+	if err != nil {
+		return err
+	}
+	worktree, err := worktreeRepo.Worktree()
+	if err != nil {
+		return err
+	}
+	if err := worktree.Reset(&gogit.ResetOptions{Commit: plumbing.NewHash(head), Mode: gogit.HardReset}); err != nil {
+		return fmt.Errorf("failed to check out %s: %w", branch, err)
+	}
+	return nil
+}
+
+// ensureBranch creates branch from an empty root commit unless it exists, so the branch
+// of each context starts with no files
+func (b *goGitBackend) ensureBranch(repo *gogit.Repository, branch string) error {
+	name := plumbing.NewBranchReferenceName(branch)
+	if _, err := repo.Reference(name, false); err == nil {
+		return nil
+	} else if !errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return fmt.Errorf("failed to look up branch %s: %w", branch, err)
+	}
+
+	opts, err := commitOptions(repo)
+	if err != nil {
+		return fmt.Errorf("failed to create branch %s: %w", branch, err)
+	}
+	tree, err := writeTree(repo.Storer, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create branch %s: %w", branch, err)
+	}
+	root, err := writeObject(repo.Storer, &object.Commit{
+		Author:    *opts.Author,
+		Committer: *opts.Committer,
+		Message:   rootCommitMessage(branch),
+		TreeHash:  tree,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create branch %s: %w", branch, err)
+	}
+	if err := repo.Storer.SetReference(plumbing.NewHashReference(name, root)); err != nil {
+		return fmt.Errorf("failed to create branch %s: %w", branch, err)
+	}
+	return nil
+}
+
+// rootCommitMessage is the message of the empty commit a new branch starts from
+func rootCommitMessage(branch string) string {
+	return "Start snapshots on " + branch
+}
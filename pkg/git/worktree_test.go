@@ -0,0 +1,108 @@
+package git
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSharedRepository(t *testing.T) {
+	t.Setenv("GIT_AUTHOR_NAME", "Test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "Test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+
+	for _, name := range BackendNames {
+		t.Run(name, func(t *testing.T) {
+			if _, err := exec.LookPath("git"); err != nil {
+				t.Skip("git not available")
+			}
+
+			root := t.TempDir()
+			shared := filepath.Join(root, "snapshots.git")
+			export := func(context, image string) *GitRepo {
+				t.Helper()
+				dir := filepath.Join(root, context)
+				backend, err := NewBackend(name, dir)
+				if err != nil {
+					t.Fatalf("NewBackend failed: %v", err)
+				}
+				repo := NewGitRepo(dir)
+				repo.SetBackend(backend)
+				repo.SetSharedRepository(shared, ClusterBranchPrefix+context)
+				if err := repo.Init(); err != nil {
+					t.Fatalf("Init of %s failed: %v", context, err)
+				}
+				writeFile(t, dir, "default/Pod/web.yaml", "kind: Pod\nimage: "+image+"\n")
+				if err := repo.SetupAndCommit("Export "+context, false); err != nil {
+					t.Fatalf("SetupAndCommit of %s failed: %v", context, err)
+				}
+				return repo
+			}
+
+			prod := export("prod", "nginx:1.25")
+			export("staging", "nginx:1.26")
+			// A second export reuses the work tree
+			export("prod", "nginx:1.27")
+
+			if !IsRepository(shared) {
+				t.Fatal("expected the shared repository to be created")
+			}
+			if branch, err := prod.Backend().CurrentBranch(); err != nil || branch != "clusters/prod" {
+				t.Errorf("expected branch clusters/prod, got %s (%v)", branch, err)
+			}
+
+			diff := runGit(t, shared, "diff", "clusters/staging", "clusters/prod", "--", "default/Pod/web.yaml")
+			if !strings.Contains(diff, "-image: nginx:1.26") || !strings.Contains(diff, "+image: nginx:1.27") {
+				t.Errorf("unexpected diff between the cluster branches:\n%s", diff)
+			}
+			log := runGit(t, shared, "log", "--format=%s", "clusters/prod")
+			if log != "Export prod\nExport prod\n"+rootCommitMessage("clusters/prod") {
+				t.Errorf("unexpected history of clusters/prod:\n%s", log)
+			}
+			if status := runGit(t, prod.path, "status", "--porcelain"); status != "" {
+				t.Errorf("expected a clean work tree, got:\n%s", status)
+			}
+
+			// git itself sees valid work trees and can prune them
+			worktrees := runGit(t, shared, "worktree", "list", "--porcelain")
+			for _, context := range []string{"prod", "staging"} {
+				entry := "worktree " + filepath.Join(root, context) + "\nHEAD "
+				if !strings.Contains(worktrees, entry) || !strings.Contains(worktrees, "branch refs/heads/clusters/"+context) {
+					t.Errorf("expected work tree %s in git worktree list:\n%s", context, worktrees)
+				}
+			}
+			if strings.Contains(worktrees, "prunable") {
+				t.Errorf("expected no broken work trees:\n%s", worktrees)
+			}
+			runGit(t, shared, "fsck", "--no-progress")
+			if err := os.RemoveAll(filepath.Join(root, "staging")); err != nil {
+				t.Fatalf("failed to remove work tree: %v", err)
+			}
+			runGit(t, shared, "worktree", "prune")
+			worktrees = runGit(t, shared, "worktree", "list", "--porcelain")
+			if strings.Contains(worktrees, filepath.Join(root, "staging")) || !strings.Contains(worktrees, filepath.Join(root, "prod")) {
+				t.Errorf("expected only the removed work tree to be pruned:\n%s", worktrees)
+			}
+			if status := runGit(t, prod.path, "status", "--porcelain"); status != "" {
+				t.Errorf("expected a clean work tree after pruning, got:\n%s", status)
+			}
+		})
+	}
+}
+
+func TestSharedRepositoryRejectsStandalone(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "prod")
+	if err := os.MkdirAll(filepath.Join(dir, ".git"), 0755); err != nil {
+		t.Fatalf("failed to create .git directory: %v", err)
+	}
+
+	repo := NewGitRepo(dir)
+	repo.SetSharedRepository(filepath.Join(root, "shared"), "clusters/prod")
+	if err := repo.Init(); err == nil || !strings.Contains(err.Error(), "standalone") {
+		t.Errorf("expected an error for a standalone repository, got %v", err)
+	}
+}
@@ -1,6 +1,9 @@
 package kube
 
 import (
+	"net/http"
+	"time"
+
 	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
@@ -8,27 +11,48 @@ import (
 	"k8s.io/client-go/tools/clientcmd"
 )
 
+// Default client tuning used by kalco
+const (
+	DefaultQPS            float32 = 50
+	DefaultBurst                  = 100
+	DefaultRequestTimeout         = 60 * time.Second
+	DefaultMaxRetries             = 3
+)
+
+// ClientOptions selects the kubeconfig, context, cluster and identity used to reach a cluster,
+// and how requests are throttled and retried. Empty selection fields fall back to the
+// kubeconfig's current context; zero tuning fields keep the client-go defaults.
+type ClientOptions struct {
+	KubeConfig        string
+	Context           string
+	Cluster           string
+	User              string
+	Namespace         string
+	Impersonate       string
+	ImpersonateGroups []string
+
+	QPS            float32
+	Burst          int
+	RequestTimeout time.Duration
+	MaxRetries     int
+}
+
+// hasOverrides reports whether any kubeconfig selection beyond the file itself is configured
+func (o ClientOptions) hasOverrides() bool {
+	return o.Context != "" || o.Cluster != "" || o.User != "" || o.Namespace != "" ||
+		o.Impersonate != "" || len(o.ImpersonateGroups) > 0
+}
+
 // NewClients creates Kubernetes clients for both in-cluster and out-of-cluster execution
 func NewClients(kubeconfigPath string) (kubernetes.Interface, discovery.DiscoveryInterface, dynamic.Interface, error) {
-	var config *rest.Config
-	var err error
-
-	if kubeconfigPath != "" {
-		// Use provided kubeconfig file
-		config, err = clientcmd.BuildConfigFromFlags("", kubeconfigPath)
-		if err != nil {
-			return nil, nil, nil, err
-		}
-	} else {
-		// Try in-cluster config first, fallback to default kubeconfig
-		config, err = rest.InClusterConfig()
-		if err != nil {
-			// Fallback to default kubeconfig location
-			config, err = clientcmd.BuildConfigFromFlags("", clientcmd.RecommendedHomeFile)
-			if err != nil {
-				return nil, nil, nil, err
-			}
-		}
+	return NewClientsWithOptions(ClientOptions{KubeConfig: kubeconfigPath})
+}
+
+// NewClientsWithOptions creates Kubernetes clients for the cluster selected by opts
+func NewClientsWithOptions(opts ClientOptions) (kubernetes.Interface, discovery.DiscoveryInterface, dynamic.Interface, error) {
+	config, err := RESTConfig(opts)
+	if err != nil {
+		return nil, nil, nil, err
 	}
 
 	// Create clientset
@@ -51,3 +75,52 @@ func NewClients(kubeconfigPath string) (kubernetes.Interface, discovery.Discover
 
 	return clientset, discoveryClient, dynamicClient, nil
 }
+
+// RESTConfig builds the client configuration for opts. Without a kubeconfig path or
+// overrides the in-cluster configuration is tried first.
+func RESTConfig(opts ClientOptions) (*rest.Config, error) {
+	config, err := loadConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.QPS > 0 {
+		config.QPS = opts.QPS
+	}
+	if opts.Burst > 0 {
+		config.Burst = opts.Burst
+	}
+	if opts.RequestTimeout > 0 {
+		config.Timeout = opts.RequestTimeout
+	}
+	if opts.MaxRetries > 0 {
+		config.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+			return newRetryTransport(rt, opts.MaxRetries)
+		})
+	}
+	return config, nil
+}
+
+// loadConfig resolves the cluster and identity selected by opts
+func loadConfig(opts ClientOptions) (*rest.Config, error) {
+	if opts.KubeConfig == "" && !opts.hasOverrides() {
+		if config, err := rest.InClusterConfig(); err == nil {
+			return config, nil
+		}
+	}
+
+	// Use the provided kubeconfig file, else the default loading rules ($KUBECONFIG, ~/.kube/config)
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	if opts.KubeConfig != "" {
+		loadingRules.ExplicitPath = opts.KubeConfig
+	}
+
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: opts.Context}
+	overrides.Context.Cluster = opts.Cluster
+	overrides.Context.AuthInfo = opts.User
+	overrides.Context.Namespace = opts.Namespace
+	overrides.AuthInfo.Impersonate = opts.Impersonate
+	overrides.AuthInfo.ImpersonateGroups = opts.ImpersonateGroups
+
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides).ClientConfig()
+}
@@ -1,7 +1,13 @@
 package kube
 
 import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestNewClients(t *testing.T) {
@@ -25,3 +31,157 @@ func TestNewClients(t *testing.T) {
 		t.Error("dynamicClient should not be nil")
 	}
 }
+
+const testKubeconfig = `apiVersion: v1
+kind: Config
+current-context: staging
+clusters:
+- name: staging
+  cluster:
+    server: https://staging.example.com
+- name: production
+  cluster:
+    server: https://production.example.com
+users:
+- name: admin
+  user:
+    token: admin-token
+- name: reader
+  user:
+    token: reader-token
+contexts:
+- name: staging
+  context:
+    cluster: staging
+    user: admin
+- name: production
+  context:
+    cluster: production
+    user: admin
+`
+
+func TestRESTConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(path, []byte(testKubeconfig), 0600); err != nil {
+		t.Fatalf("failed to write kubeconfig: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		opts   ClientOptions
+		host   string
+		token  string
+		asUser string
+	}{
+		{"current context", ClientOptions{KubeConfig: path}, "https://staging.example.com", "admin-token", ""},
+		{"named context", ClientOptions{KubeConfig: path, Context: "production"}, "https://production.example.com", "admin-token", ""},
+		{"cluster and user override", ClientOptions{KubeConfig: path, Cluster: "production", User: "reader"}, "https://production.example.com", "reader-token", ""},
+		{"impersonation", ClientOptions{KubeConfig: path, Impersonate: "system:serviceaccount:kalco:kalco"}, "https://staging.example.com", "admin-token", "system:serviceaccount:kalco:kalco"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := RESTConfig(tt.opts)
+			if err != nil {
+				t.Fatalf("RESTConfig failed: %v", err)
+			}
+			if config.Host != tt.host {
+				t.Errorf("expected host %s, got %s", tt.host, config.Host)
+			}
+			if config.BearerToken != tt.token {
+				t.Errorf("expected token %s, got %s", tt.token, config.BearerToken)
+			}
+			if config.Impersonate.UserName != tt.asUser {
+				t.Errorf("expected impersonated user %q, got %q", tt.asUser, config.Impersonate.UserName)
+			}
+		})
+	}
+
+	if _, err := RESTConfig(ClientOptions{KubeConfig: path, Context: "missing"}); err == nil {
+		t.Error("expected error for an unknown kubeconfig context")
+	}
+}
+
+func TestRetryTransport(t *testing.T) {
+	var requests, failures int
+	var retryAfter string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests <= failures {
+			if retryAfter != "" {
+				w.Header().Set("Retry-After", retryAfter)
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	transport := &retryTransport{next: http.DefaultTransport, maxRetries: 3, baseDelay: time.Millisecond}
+	client := &http.Client{Transport: transport}
+
+	failures = 2
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || requests != 3 {
+		t.Errorf("expected success after 2 retries, got status %d after %d requests", resp.StatusCode, requests)
+	}
+
+	// Retries are bounded
+	requests, failures = 0, 10
+	transport.maxRetries = 2
+	resp, err = client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || requests != 3 {
+		t.Errorf("expected the last 503 after 3 attempts, got status %d after %d requests", resp.StatusCode, requests)
+	}
+
+	// Requests with a body are never replayed
+	requests, failures = 0, 10
+	resp, err = client.Post(server.URL, "application/json", strings.NewReader("{}"))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if requests != 1 {
+		t.Errorf("expected POST not to be retried, got %d requests", requests)
+	}
+
+	// Responses with Retry-After are left to client-go's own retries
+	requests, failures, retryAfter = 0, 10, "1"
+	resp, err = client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests || requests != 1 {
+		t.Errorf("expected a 429 with Retry-After not to be retried, got status %d after %d requests", resp.StatusCode, requests)
+	}
+}
+
+func TestRESTConfigTuning(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(path, []byte(testKubeconfig), 0600); err != nil {
+		t.Fatalf("failed to write kubeconfig: %v", err)
+	}
+
+	config, err := RESTConfig(ClientOptions{KubeConfig: path, QPS: 20, Burst: 40, RequestTimeout: 5 * time.Second, MaxRetries: 2})
+	if err != nil {
+		t.Fatalf("RESTConfig failed: %v", err)
+	}
+	if config.QPS != 20 || config.Burst != 40 || config.Timeout != 5*time.Second {
+		t.Errorf("unexpected tuning: qps=%v burst=%d timeout=%s", config.QPS, config.Burst, config.Timeout)
+	}
+	if config.WrapTransport == nil {
+		t.Error("expected retries to wrap the transport")
+	}
+}
@@ -0,0 +1,79 @@
+package kube
+
+import (
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	// retryBaseDelay is the delay before the first retry; it doubles on every attempt
+	retryBaseDelay = 500 * time.Millisecond
+	// retryMaxDelay caps the backoff
+	retryMaxDelay = 30 * time.Second
+)
+
+// retryTransport retries idempotent requests that failed with a transient status (429,
+// 5xx) using exponential backoff. client-go already retries responses carrying a
+// Retry-After header and connection resets of GET requests, so those are left to it and
+// attempts never multiply.
+type retryTransport struct {
+	next       http.RoundTripper
+	maxRetries int
+	baseDelay  time.Duration
+}
+
+// newRetryTransport wraps next with retries
+func newRetryTransport(next http.RoundTripper, maxRetries int) http.RoundTripper {
+	return &retryTransport{next: next, maxRetries: maxRetries, baseDelay: retryBaseDelay}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Only requests without a body can be replayed safely
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return t.next.RoundTrip(req)
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, err := t.next.RoundTrip(req)
+		if attempt >= t.maxRetries || req.Context().Err() != nil || !isTransient(resp, err) {
+			return resp, err
+		}
+
+		delay := t.backoff(attempt)
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff returns the delay before the next attempt
+func (t *retryTransport) backoff(attempt int) time.Duration {
+	delay := t.baseDelay << attempt
+	if delay > retryMaxDelay || delay <= 0 {
+		delay = retryMaxDelay
+	}
+	return delay
+}
+
+// isTransient reports whether a response is worth retrying and not retried by client-go,
+// which handles errors and any 429 or 5xx response with a Retry-After header itself
+func isTransient(resp *http.Response, err error) bool {
+	if err != nil || resp.Header.Get("Retry-After") != "" {
+		return false
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
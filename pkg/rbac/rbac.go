@@ -0,0 +1,142 @@
+package rbac
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// DefaultName is the default name of the generated ServiceAccount, ClusterRole and ClusterRoleBinding
+const DefaultName = "kalco-reader"
+
+// DefaultNamespace is the default namespace of the generated ServiceAccount
+const DefaultNamespace = "kalco"
+
+// discoveryURLs are the non-resource endpoints kalco reads during API discovery,
+// including the OpenAPI v3 schemas captured with CRDs
+var discoveryURLs = []string{"/api", "/api/*", "/apis", "/apis/*", "/openapi/v3", "/openapi/v3/*", "/version"}
+
+// Options configures the generated manifest
+type Options struct {
+	Name      string
+	Namespace string
+}
+
+// Rules returns the least-privilege policy rules for listing the given resources.
+// Namespaces are always included since the export lists them to plan its work, and
+// CustomResourceDefinitions since the export captures those of custom resources.
+func Rules(resources []schema.GroupVersionResource) []rbacv1.PolicyRule {
+	byGroup := map[string]map[string]bool{
+		"":                     {"namespaces": true},
+		"apiextensions.k8s.io": {"customresourcedefinitions": true},
+	}
+	for _, gvr := range resources {
+		if byGroup[gvr.Group] == nil {
+			byGroup[gvr.Group] = make(map[string]bool)
+		}
+		byGroup[gvr.Group][gvr.Resource] = true
+	}
+
+	groups := make([]string, 0, len(byGroup))
+	for group := range byGroup {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+
+	rules := make([]rbacv1.PolicyRule, 0, len(groups)+1)
+	for _, group := range groups {
+		names := make([]string, 0, len(byGroup[group]))
+		for name := range byGroup[group] {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		rules = append(rules, rbacv1.PolicyRule{
+			APIGroups: []string{group},
+			Resources: names,
+			Verbs:     []string{"list"},
+		})
+	}
+	rules = append(rules, rbacv1.PolicyRule{
+		NonResourceURLs: discoveryURLs,
+		Verbs:           []string{"get"},
+	})
+	return rules
+}
+
+// GenerateManifest renders a ServiceAccount, ClusterRole and ClusterRoleBinding granting
+// exactly the access an export of the given resources needs, as multi-document YAML
+func GenerateManifest(resources []schema.GroupVersionResource, opts Options) ([]byte, error) {
+	if opts.Name == "" {
+		opts.Name = DefaultName
+	}
+	if opts.Namespace == "" {
+		opts.Namespace = DefaultNamespace
+	}
+	labels := map[string]string{"app.kubernetes.io/managed-by": "kalco"}
+
+	objects := []runtime.Object{
+		&corev1.ServiceAccount{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ServiceAccount"},
+			ObjectMeta: metav1.ObjectMeta{Name: opts.Name, Namespace: opts.Namespace, Labels: labels},
+		},
+		&rbacv1.ClusterRole{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRole"},
+			ObjectMeta: metav1.ObjectMeta{Name: opts.Name, Labels: labels},
+			Rules:      Rules(resources),
+		},
+		&rbacv1.ClusterRoleBinding{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRoleBinding"},
+			ObjectMeta: metav1.ObjectMeta{Name: opts.Name, Labels: labels},
+			RoleRef: rbacv1.RoleRef{
+				APIGroup: "rbac.authorization.k8s.io",
+				Kind:     "ClusterRole",
+				Name:     opts.Name,
+			},
+			Subjects: []rbacv1.Subject{{
+				Kind:      "ServiceAccount",
+				Name:      opts.Name,
+				Namespace: opts.Namespace,
+			}},
+		},
+	}
+
+	var out bytes.Buffer
+	for i, object := range objects {
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(object)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert %s: %w", object.GetObjectKind().GroupVersionKind().Kind, err)
+		}
+		// Drop fields the converter emits for unset metadata
+		if metadata, ok := content["metadata"].(map[string]interface{}); ok {
+			delete(metadata, "creationTimestamp")
+		}
+
+		yamlData, err := yaml.Marshal(content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal manifest to YAML: %w", err)
+		}
+		if i > 0 {
+			out.WriteString("---\n")
+		}
+		out.Write(yamlData)
+	}
+	return out.Bytes(), nil
+}
+
+// ServiceAccountUser returns the username a ServiceAccount authenticates as, for impersonation
+func ServiceAccountUser(opts Options) string {
+	if opts.Name == "" {
+		opts.Name = DefaultName
+	}
+	if opts.Namespace == "" {
+		opts.Namespace = DefaultNamespace
+	}
+	return fmt.Sprintf("system:serviceaccount:%s:%s", opts.Namespace, opts.Name)
+}
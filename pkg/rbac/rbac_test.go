@@ -0,0 +1,80 @@
+package rbac
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestRules(t *testing.T) {
+	rules := Rules([]schema.GroupVersionResource{
+		{Group: "apps", Version: "v1", Resource: "deployments"},
+		{Group: "", Version: "v1", Resource: "configmaps"},
+		{Group: "apps", Version: "v1", Resource: "statefulsets"},
+	})
+
+	if len(rules) != 4 {
+		t.Fatalf("expected core, apiextensions, apps and discovery rules, got %d", len(rules))
+	}
+	if !reflect.DeepEqual(rules[0].Resources, []string{"configmaps", "namespaces"}) {
+		t.Errorf("expected core rule to include namespaces, got %v", rules[0].Resources)
+	}
+	if rules[1].APIGroups[0] != "apiextensions.k8s.io" || !reflect.DeepEqual(rules[1].Resources, []string{"customresourcedefinitions"}) {
+		t.Errorf("expected a rule for CustomResourceDefinitions, got %+v", rules[1])
+	}
+	if rules[2].APIGroups[0] != "apps" || !reflect.DeepEqual(rules[2].Resources, []string{"deployments", "statefulsets"}) {
+		t.Errorf("unexpected apps rule: %+v", rules[2])
+	}
+	for _, rule := range rules[:3] {
+		if !reflect.DeepEqual(rule.Verbs, []string{"list"}) {
+			t.Errorf("expected only the list verb, got %v", rule.Verbs)
+		}
+	}
+	if len(rules[3].NonResourceURLs) == 0 || !reflect.DeepEqual(rules[3].Verbs, []string{"get"}) {
+		t.Errorf("expected a discovery rule, got %+v", rules[3])
+	}
+}
+
+func TestGenerateManifest(t *testing.T) {
+	manifest, err := GenerateManifest([]schema.GroupVersionResource{{Version: "v1", Resource: "configmaps"}}, Options{Namespace: "backup"})
+	if err != nil {
+		t.Fatalf("GenerateManifest failed: %v", err)
+	}
+
+	var kinds []string
+	for _, doc := range strings.Split(string(manifest), "---\n") {
+		var object struct {
+			Kind     string `yaml:"kind"`
+			Metadata struct {
+				Name      string `yaml:"name"`
+				Namespace string `yaml:"namespace"`
+			} `yaml:"metadata"`
+			Subjects []struct {
+				Namespace string `yaml:"namespace"`
+			} `yaml:"subjects"`
+		}
+		if err := yaml.Unmarshal([]byte(doc), &object); err != nil {
+			t.Fatalf("failed to parse manifest document: %v", err)
+		}
+		if object.Metadata.Name != DefaultName {
+			t.Errorf("expected %s to be named %s, got %s", object.Kind, DefaultName, object.Metadata.Name)
+		}
+		if object.Kind == "ClusterRoleBinding" && object.Subjects[0].Namespace != "backup" {
+			t.Errorf("expected binding subject in namespace backup, got %s", object.Subjects[0].Namespace)
+		}
+		kinds = append(kinds, object.Kind)
+	}
+
+	if !reflect.DeepEqual(kinds, []string{"ServiceAccount", "ClusterRole", "ClusterRoleBinding"}) {
+		t.Errorf("unexpected manifest documents: %v", kinds)
+	}
+	if strings.Contains(string(manifest), "creationTimestamp") {
+		t.Error("manifest should not contain creationTimestamp")
+	}
+	if user := ServiceAccountUser(Options{Namespace: "backup"}); user != "system:serviceaccount:backup:kalco-reader" {
+		t.Errorf("unexpected service account user %s", user)
+	}
+}
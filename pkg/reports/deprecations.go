@@ -0,0 +1,48 @@
+package reports
+
+import (
+	"fmt"
+	"strings"
+
+	"kalco/pkg/deprecations"
+)
+
+// SetDeprecations adds the deprecated APIs found in the export, checked against the target
+// Kubernetes release, to the report
+func (r *ReportGenerator) SetDeprecations(target deprecations.Version, findings []deprecations.Finding) {
+	r.deprecationTarget = target
+	r.deprecations = findings
+	r.checkDeprecations = true
+}
+
+// deprecationsSection renders the objects using deprecated or removed API versions
+func (r *ReportGenerator) deprecationsSection() string {
+	var content strings.Builder
+	content.WriteString("## API Deprecations\n\n")
+	if r.deprecationTarget.IsZero() {
+		content.WriteString("**Target Version**: all known deprecations\n\n")
+	} else {
+		content.WriteString("**Target Version**: " + r.deprecationTarget.String() + "\n\n")
+	}
+
+	if len(r.deprecations) == 0 {
+		content.WriteString("No deprecated API versions are in use.\n\n")
+		return content.String()
+	}
+
+	removed := deprecations.CountRemoved(r.deprecations)
+	content.WriteString(fmt.Sprintf("- **Removed APIs**: %d\n", removed))
+	content.WriteString(fmt.Sprintf("- **Deprecated APIs**: %d\n\n", len(r.deprecations)-removed))
+	if removed > 0 {
+		content.WriteString("**Warning**: objects using removed API versions cannot be applied to the target version. Objects found through their `" + deprecations.LastAppliedAnnotation + "` annotation break the next `kubectl apply` of their manifest.\n\n")
+	}
+
+	content.WriteString("| Status | API Version | Kind | Object | Source | Replacement | File |\n")
+	content.WriteString("|--------|-------------|------|--------|--------|-------------|------|\n")
+	for _, finding := range r.deprecations {
+		content.WriteString(fmt.Sprintf("| %s | `%s` | %s | %s | %s | %s | %s |\n",
+			finding.Status(), finding.APIVersion, finding.Kind, finding.Object(), finding.Source, finding.Replacement, finding.File))
+	}
+	content.WriteString("\n")
+	return content.String()
+}
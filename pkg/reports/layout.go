@@ -0,0 +1,129 @@
+package reports
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"kalco/pkg/dumper"
+	"kalco/pkg/git"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// objectDocument is an object extracted from the files of a layout that does not keep
+// one object per <namespace>/<Kind>/<name>.yaml file
+type objectDocument struct {
+	file    string
+	group   string
+	content map[string]string
+}
+
+// SetLayout sets the layout the export was written with, so changes can be reported per object
+func (r *ReportGenerator) SetLayout(layout dumper.Layout) {
+	r.layout = layout
+}
+
+// expandDocuments replaces changed files of non-tree layouts by the objects that changed
+// inside them. Objects are addressed by their tree path (<namespace>/<Kind>/<name>.yaml)
+// so they are categorized exactly like files of the tree layout; objects moving between
+// files, e.g. after switching layouts, are matched by identity and only reported when
+// their content changed.
+func (r *ReportGenerator) expandDocuments(changedFiles []string, prevCommit, currentCommit string) []string {
+	r.documents = make(map[string]*objectDocument)
+	if r.objects == nil {
+		r.objects = make(map[string]objectRef)
+	}
+
+	var expanded []string
+	for _, file := range changedFiles {
+		if !dumper.IsResourceFile(file) {
+			expanded = append(expanded, file)
+		}
+	}
+
+	// The current commit is read last so objects point at the file now holding them
+	for _, commit := range []string{prevCommit, currentCommit} {
+		for _, file := range changedFiles {
+			if !dumper.IsResourceFile(file) {
+				continue
+			}
+			if content, err := r.getFileContent(file, commit); err == nil {
+				r.addDocuments(file, commit, content)
+			}
+		}
+	}
+
+	for path, doc := range r.documents {
+		if doc.content[prevCommit] != doc.content[currentCommit] {
+			expanded = append(expanded, path)
+		}
+	}
+	sort.Strings(expanded)
+	return expanded
+}
+
+// addDocuments records the objects of a file at a commit under their tree paths
+func (r *ReportGenerator) addDocuments(file, commit, content string) {
+	objects, err := dumper.ReadDocuments([]byte(content))
+	if err != nil {
+		return
+	}
+
+	tree, _ := dumper.ParseLayout(dumper.LayoutTree)
+	for _, object := range objects {
+		kind, namespace, name, ok := dumper.ObjectIdentity(object)
+		if !ok {
+			continue
+		}
+		data, err := dumper.FormatYAML.Encode(object)
+		if err != nil {
+			continue
+		}
+
+		// Kinds shared by several API groups are told apart by a group-qualified directory
+		apiVersion, _ := object["apiVersion"].(string)
+		gv, _ := schema.ParseGroupVersion(apiVersion)
+		objectPath := func(kindDir string) string {
+			path := tree.Path(namespace, kindDir, dumper.EscapeName(name))
+			if isStatusFile(file) {
+				path = strings.TrimSuffix(path, ".yaml") + dumper.StatusFileSuffix
+			}
+			return path
+		}
+		path := objectPath(kind)
+		if existing, ok := r.documents[path]; ok && existing.group != gv.Group {
+			if gv.Group == "" {
+				// The core group keeps the bare Kind, as in exports
+				moved := objectPath(kind + "." + existing.group)
+				r.documents[moved] = existing
+				r.objects[moved] = r.objects[path]
+				delete(r.documents, path)
+			} else {
+				path = objectPath(kind + "." + gv.Group)
+			}
+		}
+
+		doc := r.documents[path]
+		if doc == nil {
+			doc = &objectDocument{group: gv.Group, content: make(map[string]string)}
+			r.documents[path] = doc
+		}
+		doc.file = file
+		doc.content[commit] = string(data)
+		r.objects[path] = objectRef{group: doc.group, kind: kind, namespace: namespace, name: name}
+	}
+}
+
+// sourceFile returns the file a changed entry was read from
+func (r *ReportGenerator) sourceFile(file string) string {
+	if doc, ok := r.documents[file]; ok {
+		return doc.file
+	}
+	return file
+}
+
+// diffDocument diffs two versions of an object extracted from a multi-object file
+func diffDocument(file, before, after string) (string, error) {
+	return git.UnifiedDiff(filepath.ToSlash(file), []byte(before), []byte(after)), nil
+}
@@ -0,0 +1,87 @@
+package reports
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"kalco/pkg/dumper"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// objectRef identifies the object a changed file, or an object extracted from a
+// multi-object file, holds
+type objectRef struct {
+	group     string
+	kind      string
+	namespace string
+	name      string
+}
+
+// namespaceDir returns the namespace the object is reported under, with cluster-scoped
+// objects grouped like in exports
+func (o objectRef) namespaceDir() string {
+	if o.namespace == "" {
+		return dumper.ClusterScopeDir
+	}
+	return o.namespace
+}
+
+// loadManifests indexes the objects of per-object files from the manifests of both
+// commits, the current one last so it wins. Files holding several objects are left out;
+// their objects are indexed when they are expanded.
+func (r *ReportGenerator) loadManifests(prevCommit, currentCommit string) {
+	r.objects = make(map[string]objectRef)
+	for _, commit := range []string{prevCommit, currentCommit} {
+		data, err := r.repo.ReadBlob(commit, dumper.ManifestFileName)
+		if err != nil {
+			continue
+		}
+		manifest, err := dumper.ParseManifest(data)
+		if err != nil {
+			continue
+		}
+
+		byFile := make(map[string][]dumper.ManifestEntry)
+		for _, entry := range manifest.Objects {
+			byFile[entry.File] = append(byFile[entry.File], entry)
+		}
+		for file, entries := range byFile {
+			if len(entries) != 1 {
+				continue
+			}
+			gv, _ := schema.ParseGroupVersion(entries[0].APIVersion)
+			r.objects[filepath.FromSlash(file)] = objectRef{
+				group:     gv.Group,
+				kind:      entries[0].Kind,
+				namespace: entries[0].Namespace,
+				name:      entries[0].Name,
+			}
+		}
+	}
+}
+
+// objectOf returns the object a changed file holds: from the manifest or the expanded
+// documents, else, for exports without a manifest, from the file's tree layout path
+func (r *ReportGenerator) objectOf(file string) (objectRef, bool) {
+	if object, ok := r.objects[file]; ok {
+		return object, true
+	}
+
+	parts := strings.Split(file, string(os.PathSeparator))
+	if len(parts) != 3 {
+		return objectRef{}, false
+	}
+	name := strings.TrimSuffix(parts[2], filepath.Ext(parts[2]))
+	object := objectRef{
+		namespace: parts[0],
+		name:      dumper.UnescapeName(strings.TrimSuffix(name, ".status")),
+	}
+	if object.namespace == dumper.ClusterScopeDir {
+		object.namespace = ""
+	}
+	// Kinds shared by several API groups have a group-qualified directory
+	object.kind, object.group, _ = strings.Cut(parts[1], ".")
+	return object, true
+}
@@ -1,19 +1,34 @@
 package reports
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
+
+	"kalco/pkg/deprecations"
+	"kalco/pkg/dumper"
+	"kalco/pkg/git"
 )
 
 // ReportGenerator handles the creation of cluster change reports
 type ReportGenerator struct {
-	outputDir string
-	repoPath  string
+	outputDir   string
+	repoPath    string
+	repo        git.Backend
+	changeTypes map[string]git.ChangeType
+	failedKinds []string
+	result      *dumper.ExportResult
+	layout      dumper.Layout
+	documents   map[string]*objectDocument
+	objects     map[string]objectRef
+
+	checkDeprecations bool
+	deprecationTarget deprecations.Version
+	deprecations      []deprecations.Finding
 }
 
 // NewReportGenerator creates a new ReportGenerator instance
@@ -21,9 +36,60 @@ func NewReportGenerator(outputDir string) *ReportGenerator {
 	return &ReportGenerator{
 		outputDir: outputDir,
 		repoPath:  outputDir,
+		repo:      git.NewGoGitBackend(outputDir),
 	}
 }
 
+// SetGitBackend sets the backend reading the repository's history
+func (r *ReportGenerator) SetGitBackend(backend git.Backend) {
+	r.repo = backend
+}
+
+// SetPartial marks the report as covering a partial export in which listing the given kinds failed
+func (r *ReportGenerator) SetPartial(failedKinds []string) {
+	r.failedKinds = failedKinds
+}
+
+// SetExportResult attaches the outcome of the export to the report
+func (r *ReportGenerator) SetExportResult(result *dumper.ExportResult) {
+	r.result = result
+}
+
+// exportSummary renders the totals and failures of the export result
+func (r *ReportGenerator) exportSummary() string {
+	var content strings.Builder
+	totals := r.result.Totals
+
+	content.WriteString("## Export Summary\n\n")
+	content.WriteString(fmt.Sprintf("- **Objects Exported**: %d\n", totals.Objects))
+	content.WriteString(fmt.Sprintf("- **Resources Listed**: %d\n", totals.Resources))
+	content.WriteString(fmt.Sprintf("- **Resources Skipped**: %d\n", totals.Skipped))
+	content.WriteString(fmt.Sprintf("- **Forbidden**: %d\n", totals.Forbidden))
+	content.WriteString(fmt.Sprintf("- **Failed**: %d\n", totals.Failed))
+	content.WriteString(fmt.Sprintf("- **Object Errors**: %d\n", totals.Errors))
+	content.WriteString(fmt.Sprintf("- **API Groups Not Discovered**: %d\n", totals.FailedGroups))
+	content.WriteString(fmt.Sprintf("- **Duration**: %s\n\n", r.result.Duration.Round(time.Millisecond)))
+
+	if len(r.result.FailedGroups) > 0 {
+		content.WriteString("**Warning**: the following API groups could not be discovered, so their resources were not exported and their files were kept from the previous export:\n\n")
+		for _, failure := range r.result.FailedGroups {
+			content.WriteString(fmt.Sprintf("- `%s`: %s\n", failure.GroupVersion, failure.Error))
+		}
+		content.WriteString("\n")
+	}
+
+	failures := append(append([]dumper.ResourceError{}, r.result.Forbidden...), r.result.Failed...)
+	if len(failures) > 0 {
+		content.WriteString("| Resource | Namespace | Name | Error |\n")
+		content.WriteString("|----------|-----------|------|-------|\n")
+		for _, failure := range failures {
+			content.WriteString(fmt.Sprintf("| %s | %s | %s | %s |\n", failure.Resource, failure.Namespace, failure.Name, strings.ReplaceAll(failure.Error, "|", "\\|")))
+		}
+		content.WriteString("\n")
+	}
+	return content.String()
+}
+
 // GenerateReport creates a comprehensive markdown report of cluster changes
 func (r *ReportGenerator) GenerateReport(commitMessage string) error {
 	// Create reports directory
@@ -48,6 +114,19 @@ func (r *ReportGenerator) GenerateReport(commitMessage string) error {
 	}
 
 	fmt.Printf("  Generated change report: %s\n", filename)
+
+	// Write the export result next to the report for tooling
+	if r.result != nil {
+		data, err := json.MarshalIndent(r.result, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal export result: %w", err)
+		}
+		resultPath := strings.TrimSuffix(reportPath, ".md") + ".json"
+		if err := os.WriteFile(resultPath, data, 0644); err != nil {
+			return fmt.Errorf("failed to write export result: %w", err)
+		}
+		fmt.Printf("  Generated export result: %s\n", filepath.Base(resultPath))
+	}
 	return nil
 }
 
@@ -87,6 +166,17 @@ func (r *ReportGenerator) generateReportContent(commitMessage string) (string, e
 	content.WriteString("**Generated**: " + time.Now().Format("2006-01-02 15:04:05 UTC") + "\n")
 	content.WriteString("**Commit Message**: " + commitMessage + "\n\n")
 
+	if len(r.failedKinds) > 0 {
+		content.WriteString("> **Warning: partial export.** Listing the following kinds failed, so their files were kept from the previous export and may be out of date: " + strings.Join(r.failedKinds, ", ") + "\n\n")
+	}
+
+	if r.result != nil {
+		content.WriteString(r.exportSummary())
+	}
+	if r.checkDeprecations {
+		content.WriteString(r.deprecationsSection())
+	}
+
 	// Check if this is a Git repository
 	if !r.IsGitRepo() {
 		content.WriteString("## Initial Snapshot\n\n")
@@ -136,8 +226,17 @@ func (r *ReportGenerator) generateReportContent(commitMessage string) (string, e
 		return content.String(), nil
 	}
 
-	// Categorize changes
+	// Objects are identified through the manifests; multi-object files are reported per
+	// object, addressed like files of the tree layout
+	r.loadManifests(prevCommit, commitHash)
+	fileCount := len(changedFiles)
+	if r.layout != nil && r.layout.Name() != dumper.LayoutTree {
+		changedFiles = r.expandDocuments(changedFiles, prevCommit, commitHash)
+	}
+
+	// Categorize changes and separate spec changes from status changes
 	changes := r.categorizeChanges(changedFiles)
+	r.classifyChanges(changes, prevCommit, commitHash)
 
 	// Write resource type summary
 	content.WriteString("## Resource Type Summary\n\n")
@@ -152,20 +251,23 @@ func (r *ReportGenerator) generateReportContent(commitMessage string) (string, e
 
 	// Write change summary
 	content.WriteString("### Change Summary\n\n")
-	content.WriteString("- **Total Files Changed**: " + strconv.Itoa(len(changedFiles)) + "\n")
+	content.WriteString("- **Total Files Changed**: " + strconv.Itoa(fileCount) + "\n")
 	content.WriteString("- **Namespaces Affected**: " + strconv.Itoa(len(changes.Namespaces)) + "\n")
 	content.WriteString("- **Resource Types Changed**: " + strconv.Itoa(len(changes.ResourceTypes)) + "\n")
 	content.WriteString("- **New Resources**: " + strconv.Itoa(changes.NewResources) + "\n")
 	content.WriteString("- **Modified Resources**: " + strconv.Itoa(changes.ModifiedResources) + "\n")
 	content.WriteString("- **Deleted Resources**: " + strconv.Itoa(changes.DeletedResources) + "\n")
+	content.WriteString("- **Spec Changes**: " + strconv.Itoa(changes.SpecChanges) + "\n")
+	content.WriteString("- **Status Changes**: " + strconv.Itoa(changes.StatusChanges) + "\n")
 	content.WriteString("\n")
+	content.WriteString(versionChangesSection(changes))
 
 	// Write detailed changes with diff information
 	content.WriteString("### Detailed Changes\n\n")
 
 	// Group by namespace and show detailed changes directly
 	for namespace, resources := range changes.ByNamespace {
-		if namespace == "_cluster" {
+		if namespace == dumper.ClusterScopeDir {
 			content.WriteString("#### Cluster-Scoped Resources\n\n")
 		} else {
 			content.WriteString("#### Namespace: `" + namespace + "`\n\n")
@@ -175,11 +277,23 @@ func (r *ReportGenerator) generateReportContent(commitMessage string) (string, e
 			content.WriteString("#### " + resourceType + "\n\n")
 
 			for _, file := range files {
-				status := r.getFileStatus(file, prevCommit, commitHash)
+				status, classified := changes.Statuses[file]
+				if !classified {
+					status = r.getFileStatus(file, prevCommit, commitHash)
+				}
 				filename := filepath.Base(file)
-				resourceName := strings.TrimSuffix(filename, ".yaml")
+				object, _ := r.objectOf(file)
+				resourceName := object.name
+
+				scope := ""
+				switch changes.Scopes[file] {
+				case scopeStatus:
+					scope = " - status only"
+				case scopeBoth:
+					scope = " - spec and status"
+				}
 
-				content.WriteString("**" + status + "** `" + resourceName + "` (" + filename + ")\n\n")
+				content.WriteString("**" + status + "** `" + resourceName + "` (" + filename + ")" + scope + "\n\n")
 
 				// Get detailed diff information directly here
 				diffInfo, err := r.getDetailedDiff(file, prevCommit, commitHash, status)
@@ -216,13 +330,18 @@ func (r *ReportGenerator) generateReportContent(commitMessage string) (string, e
 
 // getDetailedDiff gets detailed diff information for a specific file
 func (r *ReportGenerator) getDetailedDiff(file, prevCommit, currentCommit, status string) (string, error) {
+	// Secrets are summarized per key so their values never end up in a report
+	if r.isSecretFile(file) {
+		return r.getSecretDiff(file, prevCommit, currentCommit, status)
+	}
+
 	var content strings.Builder
 
 	switch status {
 	case "New":
 		// New file - show the complete content
 		content.WriteString("**New Resource Created**\n\n")
-		content.WriteString("```yaml\n")
+		content.WriteString("```" + codeLanguage(file) + "\n")
 		currentContent, err := r.getFileContent(file, currentCommit)
 		if err != nil {
 			return "Warning: Error reading file content: " + err.Error(), nil
@@ -234,12 +353,12 @@ func (r *ReportGenerator) getDetailedDiff(file, prevCommit, currentCommit, statu
 		content.WriteString("**Resource Details**:\n")
 		content.WriteString("- Type: New resource\n")
 		content.WriteString("- Status: Created in this snapshot\n")
-		content.WriteString("- File: `" + file + "`\n\n")
+		content.WriteString("- File: `" + r.sourceFile(file) + "`\n\n")
 
 	case "Deleted":
 		// Deleted file - show what was removed
 		content.WriteString("**Resource Deleted**\n\n")
-		content.WriteString("```yaml\n")
+		content.WriteString("```" + codeLanguage(file) + "\n")
 		previousContent, err := r.getFileContent(file, prevCommit)
 		if err != nil {
 			return "Warning: Error reading previous file content: " + err.Error(), nil
@@ -251,7 +370,7 @@ func (r *ReportGenerator) getDetailedDiff(file, prevCommit, currentCommit, statu
 		content.WriteString("**Resource Details**:\n")
 		content.WriteString("- Type: Deleted resource\n")
 		content.WriteString("- Status: Removed in this snapshot\n")
-		content.WriteString("- File: `" + file + "`\n\n")
+		content.WriteString("- File: `" + r.sourceFile(file) + "`\n\n")
 
 	case "Modified":
 		// Modified file - show the diff
@@ -263,7 +382,7 @@ func (r *ReportGenerator) getDetailedDiff(file, prevCommit, currentCommit, statu
 			content.WriteString("Warning: Error getting diff: " + err.Error() + "\n\n")
 			// Fallback to showing before/after
 			content.WriteString("**Before (Previous Snapshot):**\n")
-			content.WriteString("```yaml\n")
+			content.WriteString("```" + codeLanguage(file) + "\n")
 			previousContent, err := r.getFileContent(file, prevCommit)
 			if err != nil {
 				content.WriteString("Error reading previous content: " + err.Error())
@@ -273,7 +392,7 @@ func (r *ReportGenerator) getDetailedDiff(file, prevCommit, currentCommit, statu
 			content.WriteString("\n```\n\n")
 
 			content.WriteString("**After (Current Snapshot):**\n")
-			content.WriteString("```yaml\n")
+			content.WriteString("```" + codeLanguage(file) + "\n")
 			currentContent, err := r.getFileContent(file, currentCommit)
 			if err != nil {
 				content.WriteString("Error reading current content: " + err.Error())
@@ -292,7 +411,7 @@ func (r *ReportGenerator) getDetailedDiff(file, prevCommit, currentCommit, statu
 		content.WriteString("**Resource Details**:\n")
 		content.WriteString("- Type: Modified resource\n")
 		content.WriteString("- Status: Updated in this snapshot\n")
-		content.WriteString("- File: `" + file + "`\n\n")
+		content.WriteString("- File: `" + r.sourceFile(file) + "`\n\n")
 
 		// Add change summary
 		changeSummary, err := r.getChangeSummary(file, prevCommit, currentCommit)
@@ -306,26 +425,42 @@ func (r *ReportGenerator) getDetailedDiff(file, prevCommit, currentCommit, statu
 	return content.String(), nil
 }
 
+// codeLanguage returns the Markdown code block language for a file's content
+func codeLanguage(file string) string {
+	if filepath.Ext(file) == ".json" {
+		return "json"
+	}
+	return "yaml"
+}
+
 // getFileContent gets the content of a file at a specific commit
 func (r *ReportGenerator) getFileContent(file, commit string) (string, error) {
-	cmd := exec.Command("git", "show", commit+":"+file)
-	cmd.Dir = r.repoPath
-	output, err := cmd.Output()
+	if doc, ok := r.documents[file]; ok {
+		content, exists := doc.content[commit]
+		if !exists {
+			return "", fmt.Errorf("failed to get file content: %s does not exist in %s", file, commit)
+		}
+		return content, nil
+	}
+
+	content, err := r.repo.ReadBlob(commit, filepath.ToSlash(file))
 	if err != nil {
 		return "", fmt.Errorf("failed to get file content: %w", err)
 	}
-	return string(output), nil
+	return string(content), nil
 }
 
 // getGitDiff gets the git diff output for a file between two commits
 func (r *ReportGenerator) getGitDiff(file, prevCommit, currentCommit string) (string, error) {
-	cmd := exec.Command("git", "diff", prevCommit, currentCommit, "--", file)
-	cmd.Dir = r.repoPath
-	output, err := cmd.Output()
+	if doc, ok := r.documents[file]; ok {
+		return diffDocument(file, doc.content[prevCommit], doc.content[currentCommit])
+	}
+
+	diff, err := r.repo.Diff(prevCommit, currentCommit, filepath.ToSlash(file))
 	if err != nil {
 		return "", fmt.Errorf("failed to get git diff: %w", err)
 	}
-	return string(output), nil
+	return diff, nil
 }
 
 // getChangeSummary provides a human-readable summary of what changed
@@ -381,33 +516,40 @@ type ChangeSummary struct {
 	Namespaces        map[string]bool
 	ResourceTypes     map[string]int
 	ByNamespace       map[string]map[string][]string
+	Scopes            map[string]string
+	Statuses          map[string]string
+	VersionChanges    map[string]map[string]int
+	Resources         []string
 	NewResources      int
 	ModifiedResources int
 	DeletedResources  int
+	SpecChanges       int
+	StatusChanges     int
 }
 
 // categorizeChanges organizes changed files into meaningful categories
 func (r *ReportGenerator) categorizeChanges(changedFiles []string) *ChangeSummary {
 	summary := &ChangeSummary{
-		Namespaces:    make(map[string]bool),
-		ResourceTypes: make(map[string]int),
-		ByNamespace:   make(map[string]map[string][]string),
+		Namespaces:     make(map[string]bool),
+		ResourceTypes:  make(map[string]int),
+		ByNamespace:    make(map[string]map[string][]string),
+		Scopes:         make(map[string]string),
+		Statuses:       make(map[string]string),
+		VersionChanges: make(map[string]map[string]int),
 	}
 
 	for _, file := range changedFiles {
-		// Skip .git files
-		if strings.Contains(file, ".git/") {
+		// Skip .git files, CRD definitions and anything else that is not an exported object
+		if strings.Contains(file, ".git/") || !dumper.IsResourceFile(file) {
 			continue
 		}
 
-		parts := strings.Split(file, string(os.PathSeparator))
-		if len(parts) < 3 {
+		object, ok := r.objectOf(file)
+		if !ok {
 			continue
 		}
-
-		namespace := parts[0]
-		resourceType := parts[1]
-		filename := parts[2]
+		namespace := object.namespaceDir()
+		resourceType := object.kind
 
 		// Track namespaces
 		summary.Namespaces[namespace] = true
@@ -421,9 +563,9 @@ func (r *ReportGenerator) categorizeChanges(changedFiles []string) *ChangeSummar
 		}
 		summary.ByNamespace[namespace][resourceType] = append(summary.ByNamespace[namespace][resourceType], file)
 
-		// Count new/modified/deleted
-		if strings.HasSuffix(filename, ".yaml") {
-			summary.ModifiedResources++
+		// Track resource files; new/modified/deleted counts are filled in by classifyChanges
+		if ext := filepath.Ext(file); ext == ".yaml" || ext == ".json" {
+			summary.Resources = append(summary.Resources, file)
 		}
 	}
 
@@ -432,72 +574,62 @@ func (r *ReportGenerator) categorizeChanges(changedFiles []string) *ChangeSummar
 
 // getFileStatus determines if a file was added, modified, or deleted
 func (r *ReportGenerator) getFileStatus(file, prevCommit, currentCommit string) string {
-	// Check if file exists in previous commit
-	cmd := exec.Command("git", "show", prevCommit+":"+file)
-	cmd.Dir = r.repoPath
-	if err := cmd.Run(); err != nil {
-		return "New" // New file
+	if doc, ok := r.documents[file]; ok {
+		if _, exists := doc.content[prevCommit]; !exists {
+			return "New"
+		}
+		if _, exists := doc.content[currentCommit]; !exists {
+			return "Deleted"
+		}
+		return "Modified"
 	}
 
-	// Check if file exists in current commit
-	cmd = exec.Command("git", "show", currentCommit+":"+file)
-	cmd.Dir = r.repoPath
-	if err := cmd.Run(); err != nil {
-		return "Deleted" // Deleted file
+	// The change type was recorded when diffing the two commits
+	switch r.changeTypes[file] {
+	case git.ChangeAdded:
+		return "New"
+	case git.ChangeDeleted:
+		return "Deleted"
+	default:
+		return "Modified"
 	}
-
-	return "Modified" // Modified file
 }
 
-// IsGitRepo checks if the directory is a Git repository
+// IsGitRepo checks if the directory is a Git repository or a linked work tree, whose
+// .git is a file
 func (r *ReportGenerator) IsGitRepo() bool {
-	gitDir := filepath.Join(r.repoPath, ".git")
-	info, err := os.Stat(gitDir)
-	return err == nil && info.IsDir()
+	_, err := os.Stat(filepath.Join(r.repoPath, ".git"))
+	return err == nil
 }
 
 // getCurrentCommitHash gets the current commit hash
 func (r *ReportGenerator) getCurrentCommitHash() (string, error) {
-	cmd := exec.Command("git", "rev-parse", "HEAD")
-	cmd.Dir = r.repoPath
-	output, err := cmd.Output()
-	if err != nil {
-		return "", err
-	}
-	return strings.TrimSpace(string(output)), nil
+	return r.repo.ResolveRevision("HEAD")
 }
 
 // getPreviousCommitHash gets the previous commit hash
 func (r *ReportGenerator) getPreviousCommitHash() (string, error) {
-	cmd := exec.Command("git", "rev-parse", "HEAD~1")
-	cmd.Dir = r.repoPath
-	output, err := cmd.Output()
-	if err != nil {
-		return "", err
-	}
-
-	return strings.TrimSpace(string(output)), nil
+	return r.repo.ResolveRevision("HEAD~1")
 }
 
-// getChangedFiles gets the list of changed files between two commits
+// getChangedFiles gets the list of changed files between two commits and records how
+// each of them changed
 func (r *ReportGenerator) getChangedFiles(prevCommit, currentCommit string) ([]string, error) {
-	cmd := exec.Command("git", "diff", "--name-only", prevCommit, currentCommit)
-	cmd.Dir = r.repoPath
-	output, err := cmd.Output()
+	changes, err := r.repo.DiffTree(prevCommit, currentCommit)
 	if err != nil {
 		return nil, err
 	}
 
-	if len(output) == 0 {
-		return []string{}, nil
-	}
-
-	files := strings.Split(strings.TrimSpace(string(output)), "\n")
-	var result []string
-	for _, file := range files {
-		if strings.TrimSpace(file) != "" {
-			result = append(result, strings.TrimSpace(file))
+	r.changeTypes = make(map[string]git.ChangeType, len(changes))
+	result := []string{}
+	for _, change := range changes {
+		// The manifest changes with every export; it indexes the changes, it is not one
+		if change.Path == dumper.ManifestFileName {
+			continue
 		}
+		file := filepath.FromSlash(change.Path)
+		r.changeTypes[file] = change.Type
+		result = append(result, file)
 	}
 
 	return result, nil
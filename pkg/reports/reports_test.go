@@ -2,9 +2,15 @@ package reports
 
 import (
 	"os"
+	"os/exec"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+
+	"kalco/pkg/deprecations"
+	"kalco/pkg/dumper"
+	"kalco/pkg/git"
 )
 
 func TestNewReportGenerator(t *testing.T) {
@@ -84,3 +90,378 @@ func TestIsGitRepo(t *testing.T) {
 		t.Error("directory should be a git repo after creating .git")
 	}
 }
+
+func TestReportSharedRepository(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	t.Setenv("GIT_AUTHOR_NAME", "Test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "Test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+
+	for _, name := range git.BackendNames {
+		t.Run(name, func(t *testing.T) {
+			root := t.TempDir()
+			dir := filepath.Join(root, "prod")
+			backend, err := git.NewBackend(name, dir)
+			if err != nil {
+				t.Fatalf("NewBackend failed: %v", err)
+			}
+			repo := git.NewGitRepo(dir)
+			repo.SetBackend(backend)
+			repo.SetSharedRepository(filepath.Join(root, "snapshots.git"), git.ClusterBranchPrefix+"prod")
+
+			for _, image := range []string{"nginx:1.25", "nginx:1.26"} {
+				// The work tree is added before the export writes into it
+				if err := repo.Init(); err != nil {
+					t.Fatalf("Init failed: %v", err)
+				}
+				podFile := filepath.Join(dir, "default", "Pod", "web.yaml")
+				if err := os.MkdirAll(filepath.Dir(podFile), 0755); err != nil {
+					t.Fatalf("failed to create directory: %v", err)
+				}
+				if err := os.WriteFile(podFile, []byte("kind: Pod\nimage: "+image+"\n"), 0644); err != nil {
+					t.Fatalf("failed to write pod: %v", err)
+				}
+				if err := repo.SetupAndCommit("Export "+image, false); err != nil {
+					t.Fatalf("SetupAndCommit failed: %v", err)
+				}
+			}
+
+			gen := NewReportGenerator(dir)
+			gen.SetGitBackend(backend)
+			if !gen.IsGitRepo() {
+				t.Fatal("expected the linked work tree to be a Git repository")
+			}
+			content, err := gen.generateReportContent("Export nginx:1.26")
+			if err != nil {
+				t.Fatalf("generateReportContent failed: %v", err)
+			}
+			if strings.Contains(content, "Initial Snapshot") || !strings.Contains(content, "Changes Since Previous Snapshot") {
+				t.Errorf("expected a diff against the previous export, got:\n%s", content)
+			}
+		})
+	}
+}
+
+func TestSecretFingerprints(t *testing.T) {
+	previous, err := secretFingerprints(`
+data:
+  password: kalco-redacted:sha256:aaaa
+  username: kalco-redacted:sha256:bbbb
+`)
+	if err != nil {
+		t.Fatalf("failed to parse secret: %v", err)
+	}
+	current, err := secretFingerprints(`
+metadata:
+  annotations:
+    kalco.io/secret-checksums: '{"password":"cccc","token":"dddd"}'
+data:
+  password: "-----BEGIN AGE ENCRYPTED FILE-----"
+  token: "-----BEGIN AGE ENCRYPTED FILE-----"
+`)
+	if err != nil {
+		t.Fatalf("failed to parse secret: %v", err)
+	}
+
+	changes := compareSecretKeys(previous, current)
+	expected := []string{
+		"Secret key `password` changed",
+		"Secret key `token` added",
+		"Secret key `username` removed",
+	}
+	if strings.Join(changes, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected changes %v, got %v", expected, changes)
+	}
+
+	gen := NewReportGenerator(t.TempDir())
+	if !gen.isSecretFile(filepath.Join("default", "Secret", "db.yaml")) {
+		t.Error("expected Secret file to be detected")
+	}
+	if gen.isSecretFile(filepath.Join("default", "ConfigMap", "db.yaml")) {
+		t.Error("ConfigMap file should not be detected as a Secret")
+	}
+}
+
+func TestManifestObjects(t *testing.T) {
+	dir := initRepo(t)
+	gen := NewReportGenerator(dir)
+
+	// A flat layout export: paths alone do not tell namespace and Kind apart
+	manifest := func(files ...string) string {
+		entries := map[string]string{
+			"default_Secret_db.yaml":          `{"apiVersion": "v1", "kind": "Secret", "namespace": "default", "name": "db", "file": "default_Secret_db.yaml"}`,
+			"_cluster_Widget_shared.yaml":     `{"apiVersion": "example.com/v1", "kind": "Secret", "name": "shared", "file": "_cluster_Widget_shared.yaml"}`,
+			"default_ConfigMap_settings.yaml": `{"apiVersion": "v1", "kind": "ConfigMap", "namespace": "default", "name": "settings", "file": "default_ConfigMap_settings.yaml"}`,
+		}
+		var objects []string
+		for _, file := range files {
+			objects = append(objects, entries[file])
+		}
+		return `{"layout": "flat", "objects": [` + strings.Join(objects, ", ") + `]}`
+	}
+	prev := commitFiles(t, dir, map[string]string{
+		"default_Secret_db.yaml":      "kind: Secret\ndata:\n  password: a\n",
+		"_cluster_Widget_shared.yaml": "kind: Secret\n",
+		"kalco-manifest.json":         manifest("default_Secret_db.yaml", "_cluster_Widget_shared.yaml"),
+	})
+	current := commitFiles(t, dir, map[string]string{
+		"default_Secret_db.yaml":          "kind: Secret\ndata:\n  password: b\n",
+		"default_ConfigMap_settings.yaml": "kind: ConfigMap\n",
+		"kalco-manifest.json":             manifest("default_Secret_db.yaml", "_cluster_Widget_shared.yaml", "default_ConfigMap_settings.yaml"),
+	})
+	gen.loadManifests(prev, current)
+
+	if !gen.isSecretFile("default_Secret_db.yaml") {
+		t.Error("expected the flat layout Secret file to be detected")
+	}
+	if gen.isSecretFile("_cluster_Widget_shared.yaml") {
+		t.Error("a Secret Kind of another API group should not be detected as a core Secret")
+	}
+
+	changes := gen.categorizeChanges([]string{"default_Secret_db.yaml", "default_ConfigMap_settings.yaml"})
+	expected := map[string]map[string][]string{
+		"default": {
+			"Secret":    {"default_Secret_db.yaml"},
+			"ConfigMap": {"default_ConfigMap_settings.yaml"},
+		},
+	}
+	if !reflect.DeepEqual(changes.ByNamespace, expected) {
+		t.Errorf("expected changes by namespace %v, got %v", expected, changes.ByNamespace)
+	}
+}
+
+// commitFiles writes the given files into dir and commits them, returning the commit hash
+func commitFiles(t *testing.T, dir string, files map[string]string) string {
+	t.Helper()
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+
+	for _, args := range [][]string{
+		{"add", "."},
+		{"-c", "user.name=kalco", "-c", "user.email=kalco@example.com", "commit", "-q", "-m", "snapshot"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, output)
+		}
+	}
+
+	cmd := exec.Command("git", "rev-parse", "HEAD")
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("failed to resolve HEAD: %v", err)
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// initRepo creates a Git repository for report tests
+func initRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	dir := t.TempDir()
+	cmd := exec.Command("git", "init", "-q")
+	cmd.Dir = dir
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("failed to init repository: %v", err)
+	}
+	return dir
+}
+
+func TestChangeScope(t *testing.T) {
+	dir := initRepo(t)
+	gen := NewReportGenerator(dir)
+
+	podFile := filepath.Join("default", "Pod", "web.yaml")
+	deployFile := filepath.Join("default", "Deployment", "web.yaml")
+	prev := commitFiles(t, dir, map[string]string{
+		podFile:    "kind: Pod\nspec:\n  image: nginx:1\nstatus:\n  phase: Pending\n",
+		deployFile: "kind: Deployment\nspec:\n  replicas: 1\n",
+	})
+	current := commitFiles(t, dir, map[string]string{
+		podFile:    "kind: Pod\nspec:\n  image: nginx:1\nstatus:\n  phase: Running\n",
+		deployFile: "kind: Deployment\nspec:\n  replicas: 2\n",
+	})
+
+	if scope := gen.changeScope(podFile, prev, current, "Modified"); scope != scopeStatus {
+		t.Errorf("expected status-only change for pod, got %s", scope)
+	}
+	if scope := gen.changeScope(deployFile, prev, current, "Modified"); scope != scopeSpec {
+		t.Errorf("expected spec change for deployment, got %s", scope)
+	}
+	if scope := gen.changeScope(filepath.Join("default", "Pod", "web.status.yaml"), prev, current, "Modified"); scope != scopeStatus {
+		t.Errorf("expected status change for status file, got %s", scope)
+	}
+}
+
+func TestClassifyChangesCountsDeletions(t *testing.T) {
+	dir := initRepo(t)
+	gen := NewReportGenerator(dir)
+
+	keptFile := filepath.Join("default", "ConfigMap", "kept.yaml")
+	deletedFile := filepath.Join("default", "ConfigMap", "deleted.yaml")
+	prev := commitFiles(t, dir, map[string]string{
+		keptFile:    "data:\n  key: a\n",
+		deletedFile: "data:\n  key: b\n",
+	})
+	if err := os.Remove(filepath.Join(dir, deletedFile)); err != nil {
+		t.Fatalf("failed to remove file: %v", err)
+	}
+	current := commitFiles(t, dir, map[string]string{
+		keptFile: "data:\n  key: c\n",
+		filepath.Join("default", "Pod", "new.yaml"): "kind: Pod\n",
+	})
+
+	changedFiles, err := gen.getChangedFiles(prev, current)
+	if err != nil {
+		t.Fatalf("failed to get changed files: %v", err)
+	}
+	changes := gen.categorizeChanges(changedFiles)
+	gen.classifyChanges(changes, prev, current)
+
+	if changes.NewResources != 1 || changes.ModifiedResources != 1 || changes.DeletedResources != 1 {
+		t.Errorf("expected 1 new, 1 modified and 1 deleted resource, got %d/%d/%d",
+			changes.NewResources, changes.ModifiedResources, changes.DeletedResources)
+	}
+	if changes.Statuses[deletedFile] != "Deleted" {
+		t.Errorf("expected %s to be Deleted, got %s", deletedFile, changes.Statuses[deletedFile])
+	}
+}
+
+func TestExpandDocuments(t *testing.T) {
+	dir := initRepo(t)
+	gen := NewReportGenerator(dir)
+	layout, err := dumper.ParseLayout(dumper.LayoutNamespace)
+	if err != nil {
+		t.Fatalf("ParseLayout failed: %v", err)
+	}
+	gen.SetLayout(layout)
+
+	configMap := func(name, value string) string {
+		return "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: " + name + "\n  namespace: default\ndata:\n  key: " + value + "\n"
+	}
+	prev := commitFiles(t, dir, map[string]string{
+		"default.yaml": configMap("kept", "a") + "---\n" + configMap("deleted", "b") + "---\n" + configMap("unchanged", "c"),
+	})
+	current := commitFiles(t, dir, map[string]string{
+		"default.yaml": configMap("kept", "changed") + "---\n" + configMap("new", "d") + "---\n" + configMap("unchanged", "c"),
+	})
+
+	changedFiles, err := gen.getChangedFiles(prev, current)
+	if err != nil {
+		t.Fatalf("failed to get changed files: %v", err)
+	}
+	changedFiles = gen.expandDocuments(changedFiles, prev, current)
+	if len(changedFiles) != 3 {
+		t.Fatalf("expected 3 changed objects, got %v", changedFiles)
+	}
+	changes := gen.categorizeChanges(changedFiles)
+	gen.classifyChanges(changes, prev, current)
+
+	if changes.NewResources != 1 || changes.ModifiedResources != 1 || changes.DeletedResources != 1 {
+		t.Errorf("expected 1 new, 1 modified and 1 deleted resource, got %d/%d/%d",
+			changes.NewResources, changes.ModifiedResources, changes.DeletedResources)
+	}
+	kept := filepath.Join("default", "ConfigMap", "kept.yaml")
+	if changes.Statuses[kept] != "Modified" {
+		t.Errorf("expected %s to be Modified, got %s", kept, changes.Statuses[kept])
+	}
+	if gen.sourceFile(kept) != "default.yaml" {
+		t.Errorf("expected %s to be read from default.yaml, got %s", kept, gen.sourceFile(kept))
+	}
+
+	diff, err := gen.getGitDiff(kept, prev, current)
+	if err != nil {
+		t.Fatalf("getGitDiff failed: %v", err)
+	}
+	if !strings.Contains(diff, "-    key: a") || !strings.Contains(diff, "+    key: changed") {
+		t.Errorf("expected per-object diff, got:\n%s", diff)
+	}
+}
+
+func TestAPIVersionChanges(t *testing.T) {
+	dir := initRepo(t)
+	gen := NewReportGenerator(dir)
+
+	hpaFile := filepath.Join("default", "HorizontalPodAutoscaler", "web.yaml")
+	cmFile := filepath.Join("default", "ConfigMap", "settings.yaml")
+	prev := commitFiles(t, dir, map[string]string{
+		hpaFile: "apiVersion: autoscaling/v1\nkind: HorizontalPodAutoscaler\n",
+		cmFile:  "apiVersion: v1\nkind: ConfigMap\ndata:\n  key: a\n",
+	})
+	current := commitFiles(t, dir, map[string]string{
+		hpaFile: "apiVersion: autoscaling/v2\nkind: HorizontalPodAutoscaler\n",
+		cmFile:  "apiVersion: v1\nkind: ConfigMap\ndata:\n  key: b\n",
+	})
+
+	changedFiles, err := gen.getChangedFiles(prev, current)
+	if err != nil {
+		t.Fatalf("getChangedFiles failed: %v", err)
+	}
+	changes := gen.categorizeChanges(changedFiles)
+	gen.classifyChanges(changes, prev, current)
+
+	expected := map[string]map[string]int{
+		"HorizontalPodAutoscaler": {"`autoscaling/v1` → `autoscaling/v2`": 1},
+	}
+	if !reflect.DeepEqual(changes.VersionChanges, expected) {
+		t.Errorf("expected version changes %v, got %v", expected, changes.VersionChanges)
+	}
+	if section := versionChangesSection(changes); !strings.Contains(section, "**HorizontalPodAutoscaler**") {
+		t.Errorf("expected the HorizontalPodAutoscaler to be flagged:\n%s", section)
+	}
+}
+
+func TestDeprecationsSection(t *testing.T) {
+	gen := NewReportGenerator(t.TempDir())
+	gen.SetDeprecations(deprecations.Version{Major: 1, Minor: 25}, []deprecations.Finding{{
+		Deprecation: deprecations.Deprecation{
+			APIVersion:   "batch/v1beta1",
+			Kind:         "CronJob",
+			DeprecatedIn: deprecations.Version{Major: 1, Minor: 21},
+			RemovedIn:    deprecations.Version{Major: 1, Minor: 25},
+			Replacement:  "batch/v1",
+		},
+		Namespace: "default",
+		Name:      "backup",
+		File:      "default/CronJob/backup.yaml",
+		Source:    deprecations.SourceLastApplied,
+		Removed:   true,
+	}})
+
+	content, err := gen.generateReportContent("test")
+	if err != nil {
+		t.Fatalf("generateReportContent failed: %v", err)
+	}
+	for _, expected := range []string{
+		"## API Deprecations",
+		"**Target Version**: v1.25",
+		"- **Removed APIs**: 1",
+		"| removed in v1.25 | `batch/v1beta1` | CronJob | default/backup | last-applied | batch/v1 | default/CronJob/backup.yaml |",
+	} {
+		if !strings.Contains(content, expected) {
+			t.Errorf("expected report to contain %q", expected)
+		}
+	}
+
+	gen.SetDeprecations(deprecations.Version{}, nil)
+	content, _ = gen.generateReportContent("test")
+	if !strings.Contains(content, "all known deprecations") || !strings.Contains(content, "No deprecated API versions are in use.") {
+		t.Errorf("expected an empty deprecation section, got:\n%s", content)
+	}
+}
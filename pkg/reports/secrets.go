@@ -0,0 +1,135 @@
+package reports
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"kalco/pkg/dumper"
+
+	"gopkg.in/yaml.v3"
+)
+
+// isSecretFile reports whether an exported file holds a core Secret
+func (r *ReportGenerator) isSecretFile(file string) bool {
+	object, ok := r.objectOf(file)
+	return ok && object.kind == "Secret" && object.group == ""
+}
+
+// getSecretDiff describes a Secret change at key level without revealing any values
+func (r *ReportGenerator) getSecretDiff(file, prevCommit, currentCommit, status string) (string, error) {
+	var content strings.Builder
+
+	var previous, current map[string]string
+	if status != "New" {
+		prevContent, err := r.getFileContent(file, prevCommit)
+		if err != nil {
+			return "", err
+		}
+		if previous, err = secretFingerprints(prevContent); err != nil {
+			return "", err
+		}
+	}
+	if status != "Deleted" {
+		currentContent, err := r.getFileContent(file, currentCommit)
+		if err != nil {
+			return "", err
+		}
+		if current, err = secretFingerprints(currentContent); err != nil {
+			return "", err
+		}
+	}
+
+	switch status {
+	case "New":
+		content.WriteString("**New Secret Created**\n\n")
+	case "Deleted":
+		content.WriteString("**Secret Deleted**\n\n")
+	default:
+		content.WriteString("**Secret Modified**\n\n")
+	}
+
+	changes := compareSecretKeys(previous, current)
+	if len(changes) == 0 {
+		content.WriteString("No Secret keys changed (metadata only).\n\n")
+	} else {
+		for _, change := range changes {
+			content.WriteString("- " + change + "\n")
+		}
+		content.WriteString("\n")
+	}
+
+	content.WriteString("*Secret values are never included in reports.*\n\n")
+	content.WriteString("**Resource Details**:\n")
+	content.WriteString("- File: `" + r.sourceFile(file) + "`\n\n")
+	return content.String(), nil
+}
+
+// compareSecretKeys lists added, removed and changed keys between two fingerprint sets
+func compareSecretKeys(previous, current map[string]string) []string {
+	keys := make(map[string]bool)
+	for key := range previous {
+		keys[key] = true
+	}
+	for key := range current {
+		keys[key] = true
+	}
+
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+
+	var changes []string
+	for _, key := range sorted {
+		before, hadBefore := previous[key]
+		after, hasAfter := current[key]
+		switch {
+		case !hadBefore:
+			changes = append(changes, "Secret key `"+key+"` added")
+		case !hasAfter:
+			changes = append(changes, "Secret key `"+key+"` removed")
+		case before != after:
+			changes = append(changes, "Secret key `"+key+"` changed")
+		}
+	}
+	return changes
+}
+
+// secretFingerprints returns a fingerprint per Secret key. Redacted values are used as-is,
+// encrypted values use the checksums recorded at export time and plain values are hashed.
+func secretFingerprints(content string) (map[string]string, error) {
+	var object struct {
+		Metadata struct {
+			Annotations map[string]string `yaml:"annotations"`
+		} `yaml:"metadata"`
+		Data       map[string]string `yaml:"data"`
+		StringData map[string]string `yaml:"stringData"`
+	}
+	if err := yaml.Unmarshal([]byte(content), &object); err != nil {
+		return nil, fmt.Errorf("failed to parse secret: %w", err)
+	}
+
+	fingerprints := make(map[string]string)
+	if encoded, ok := object.Metadata.Annotations[dumper.SecretChecksumsAnnotation]; ok {
+		if err := json.Unmarshal([]byte(encoded), &fingerprints); err == nil {
+			return fingerprints, nil
+		}
+	}
+
+	for _, values := range []map[string]string{object.Data, object.StringData} {
+		for key, value := range values {
+			if strings.HasPrefix(value, dumper.RedactedPrefix) {
+				fingerprints[key] = strings.TrimPrefix(value, dumper.RedactedPrefix)
+				continue
+			}
+			sum := sha256.Sum256([]byte(value))
+			fingerprints[key] = hex.EncodeToString(sum[:])
+		}
+	}
+	return fingerprints, nil
+}
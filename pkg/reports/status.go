@@ -0,0 +1,94 @@
+package reports
+
+import (
+	"reflect"
+
+	"kalco/pkg/dumper"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Change scopes recorded per changed file
+const (
+	scopeSpec   = "spec"
+	scopeStatus = "status"
+	scopeBoth   = "spec+status"
+)
+
+// isStatusFile reports whether a file holds status captured separately from its object
+func isStatusFile(file string) bool {
+	return dumper.IsStatusFile(file)
+}
+
+// classifyChanges determines for every changed resource file whether it was added,
+// modified or deleted, whether its spec, its status or both changed, and whether its
+// exported apiVersion changed
+func (r *ReportGenerator) classifyChanges(changes *ChangeSummary, prevCommit, currentCommit string) {
+	for _, file := range changes.Resources {
+		status := r.getFileStatus(file, prevCommit, currentCommit)
+		changes.Statuses[file] = status
+		switch status {
+		case "New":
+			changes.NewResources++
+		case "Deleted":
+			changes.DeletedResources++
+		default:
+			changes.ModifiedResources++
+		}
+
+		if status == "Modified" && !isStatusFile(file) {
+			if kind, from, to, changed := r.apiVersionChange(file, prevCommit, currentCommit); changed {
+				changes.recordVersionChange(kind, from, to)
+			}
+		}
+
+		scope := r.changeScope(file, prevCommit, currentCommit, status)
+		changes.Scopes[file] = scope
+		if scope != scopeStatus {
+			changes.SpecChanges++
+		}
+		if scope != scopeSpec {
+			changes.StatusChanges++
+		}
+	}
+}
+
+// changeScope determines whether a change touched an object's spec, its status, or both.
+// Status files always count as status changes; objects exported with inline status are
+// compared with and without their status field.
+func (r *ReportGenerator) changeScope(file, prevCommit, currentCommit, status string) string {
+	if isStatusFile(file) {
+		return scopeStatus
+	}
+	if status != "Modified" {
+		return scopeSpec
+	}
+
+	prevContent, err := r.getFileContent(file, prevCommit)
+	if err != nil {
+		return scopeSpec
+	}
+	currentContent, err := r.getFileContent(file, currentCommit)
+	if err != nil {
+		return scopeSpec
+	}
+
+	var previous, current map[string]interface{}
+	if yaml.Unmarshal([]byte(prevContent), &previous) != nil || yaml.Unmarshal([]byte(currentContent), &current) != nil {
+		return scopeSpec
+	}
+
+	statusChanged := !reflect.DeepEqual(previous["status"], current["status"])
+	delete(previous, "status")
+	delete(current, "status")
+	specChanged := !reflect.DeepEqual(previous, current)
+
+	switch {
+	case statusChanged && specChanged:
+		return scopeBoth
+	case statusChanged:
+		return scopeStatus
+	default:
+		return scopeSpec
+	}
+}
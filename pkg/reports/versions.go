@@ -0,0 +1,73 @@
+package reports
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// apiVersionChange returns the Kind and the previous and current apiVersion of a modified
+// object whose exported apiVersion changed between the two commits
+func (r *ReportGenerator) apiVersionChange(file, prevCommit, currentCommit string) (kind, from, to string, changed bool) {
+	prevContent, err := r.getFileContent(file, prevCommit)
+	if err != nil {
+		return "", "", "", false
+	}
+	currentContent, err := r.getFileContent(file, currentCommit)
+	if err != nil {
+		return "", "", "", false
+	}
+
+	var previous, current struct {
+		APIVersion string `yaml:"apiVersion"`
+		Kind       string `yaml:"kind"`
+	}
+	if yaml.Unmarshal([]byte(prevContent), &previous) != nil || yaml.Unmarshal([]byte(currentContent), &current) != nil {
+		return "", "", "", false
+	}
+	if previous.APIVersion == "" || current.APIVersion == "" || previous.APIVersion == current.APIVersion {
+		return "", "", "", false
+	}
+	return current.Kind, previous.APIVersion, current.APIVersion, true
+}
+
+// recordVersionChange counts an object whose exported apiVersion changed
+func (c *ChangeSummary) recordVersionChange(kind, from, to string) {
+	change := "`" + from + "` → `" + to + "`"
+	if c.VersionChanges[kind] == nil {
+		c.VersionChanges[kind] = make(map[string]int)
+	}
+	c.VersionChanges[kind][change]++
+}
+
+// versionChangesSection warns about kinds whose exported apiVersion changed, since their
+// diffs may only reflect the server's preferred version rather than a real change
+func versionChangesSection(changes *ChangeSummary) string {
+	if len(changes.VersionChanges) == 0 {
+		return ""
+	}
+
+	kinds := make([]string, 0, len(changes.VersionChanges))
+	for kind := range changes.VersionChanges {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+
+	var content strings.Builder
+	content.WriteString("### API Version Changes\n\n")
+	content.WriteString("**Warning**: the exported apiVersion of these kinds changed, so their diffs may only reflect the version change. Pin the group's version on the context (`kalco context set --api-version group=version`) to keep it stable.\n\n")
+	for _, kind := range kinds {
+		versions := make([]string, 0, len(changes.VersionChanges[kind]))
+		for change := range changes.VersionChanges[kind] {
+			versions = append(versions, change)
+		}
+		sort.Strings(versions)
+		for _, change := range versions {
+			content.WriteString(fmt.Sprintf("- **%s**: %s (%d objects)\n", kind, change, changes.VersionChanges[kind][change]))
+		}
+	}
+	content.WriteString("\n")
+	return content.String()
+}